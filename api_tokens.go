@@ -0,0 +1,208 @@
+package kvm
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// apiTokenPrefix makes the tokens easy to recognize, e.g. in secret scanners.
+const apiTokenPrefix = "jkvm_"
+
+const (
+	maxAPITokens       = 32
+	maxAPITokenNameLen = 64
+)
+
+// APIToken is a named token for scripted access. Only the SHA-256 of the token is
+// stored, the tokens are random so a slow hash isn't needed.
+type APIToken struct {
+	ID          string     `json:"id"`
+	Name        string     `json:"name"`
+	Hint        string     `json:"hint"`
+	HashedToken string     `json:"hashedToken"`
+	CreatedAt   time.Time  `json:"createdAt"`
+	ExpiresAt   *time.Time `json:"expiresAt,omitempty"`
+	LastUsedAt  *time.Time `json:"lastUsedAt,omitempty"`
+}
+
+// APITokenInfo is the token as returned over JSON-RPC, without the hash.
+type APITokenInfo struct {
+	ID         string     `json:"id"`
+	Name       string     `json:"name"`
+	Hint       string     `json:"hint"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	ExpiresAt  *time.Time `json:"expiresAt,omitempty"`
+	LastUsedAt *time.Time `json:"lastUsedAt,omitempty"`
+	Expired    bool       `json:"expired"`
+}
+
+type CreatedAPIToken struct {
+	APITokenInfo
+	// Token is only returned once, when the token is created.
+	Token string `json:"token"`
+}
+
+var apiTokensLock = &sync.Mutex{}
+
+func hashSecretToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func (t *APIToken) isExpired(now time.Time) bool {
+	return t.ExpiresAt != nil && now.After(*t.ExpiresAt)
+}
+
+func (t *APIToken) info() APITokenInfo {
+	return APITokenInfo{
+		ID:         t.ID,
+		Name:       t.Name,
+		Hint:       t.Hint,
+		CreatedAt:  t.CreatedAt,
+		ExpiresAt:  t.ExpiresAt,
+		LastUsedAt: t.LastUsedAt,
+		Expired:    t.isExpired(time.Now()),
+	}
+}
+
+// getBearerToken returns the token of an `Authorization: Bearer` header, if any.
+func getBearerToken(c *gin.Context) (string, bool) {
+	scheme, token, ok := strings.Cut(c.GetHeader("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+
+	token = strings.TrimSpace(token)
+	return token, token != ""
+}
+
+// validateAPIToken checks the token against the stored hashes and records its use.
+func validateAPIToken(token string) (*APIToken, bool) {
+	if !strings.HasPrefix(token, apiTokenPrefix) {
+		return nil, false
+	}
+
+	hashed := []byte(hashSecretToken(token))
+	now := time.Now()
+
+	apiTokensLock.Lock()
+	defer apiTokensLock.Unlock()
+
+	for i := range config.APITokens {
+		t := &config.APITokens[i]
+		if subtle.ConstantTimeCompare(hashed, []byte(t.HashedToken)) != 1 {
+			continue
+		}
+
+		if t.isExpired(now) {
+			return nil, false
+		}
+
+		// only kept in memory, it gets persisted with the next config save. Written
+		// under configLock too as SaveConfig encodes the tokens without apiTokensLock.
+		configLock.Lock()
+		t.LastUsedAt = &now
+		configLock.Unlock()
+		return t, true
+	}
+
+	return nil, false
+}
+
+func rpcGetAPITokens() ([]APITokenInfo, error) {
+	apiTokensLock.Lock()
+	defer apiTokensLock.Unlock()
+
+	tokens := make([]APITokenInfo, 0, len(config.APITokens))
+	for i := range config.APITokens {
+		tokens = append(tokens, config.APITokens[i].info())
+	}
+
+	return tokens, nil
+}
+
+// rpcCreateAPIToken creates a new token, expiresInDays of 0 means the token never expires.
+func rpcCreateAPIToken(name string, expiresInDays int) (*CreatedAPIToken, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, errors.New("token name cannot be empty")
+	}
+	if len(name) > maxAPITokenNameLen {
+		return nil, fmt.Errorf("token name is too long (max %d characters)", maxAPITokenNameLen)
+	}
+	if expiresInDays < 0 {
+		return nil, errors.New("expiry cannot be negative")
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, fmt.Errorf("failed to generate token: %w", err)
+	}
+	token := apiTokenPrefix + base64.RawURLEncoding.EncodeToString(secret)
+
+	apiToken := APIToken{
+		ID:          uuid.New().String(),
+		Name:        name,
+		Hint:        token[len(token)-4:],
+		HashedToken: hashSecretToken(token),
+		CreatedAt:   time.Now(),
+	}
+	if expiresInDays > 0 {
+		expiresAt := apiToken.CreatedAt.AddDate(0, 0, expiresInDays)
+		apiToken.ExpiresAt = &expiresAt
+	}
+
+	apiTokensLock.Lock()
+	if len(config.APITokens) >= maxAPITokens {
+		apiTokensLock.Unlock()
+		return nil, fmt.Errorf("too many API tokens (max %d)", maxAPITokens)
+	}
+	if slices.ContainsFunc(config.APITokens, func(t APIToken) bool { return t.Name == name }) {
+		apiTokensLock.Unlock()
+		return nil, fmt.Errorf("a token named %q already exists", name)
+	}
+	config.APITokens = append(config.APITokens, apiToken)
+	apiTokensLock.Unlock()
+
+	if err := SaveConfig(); err != nil {
+		return nil, fmt.Errorf("failed to save config: %w", err)
+	}
+
+	logger.Info().Str("id", apiToken.ID).Str("name", apiToken.Name).Msg("API token created")
+
+	return &CreatedAPIToken{
+		APITokenInfo: apiToken.info(),
+		Token:        token,
+	}, nil
+}
+
+func rpcRevokeAPIToken(id string) error {
+	apiTokensLock.Lock()
+	idx := slices.IndexFunc(config.APITokens, func(t APIToken) bool { return t.ID == id })
+	if idx == -1 {
+		apiTokensLock.Unlock()
+		return fmt.Errorf("API token %s not found", id)
+	}
+	name := config.APITokens[idx].Name
+	config.APITokens = slices.Delete(config.APITokens, idx, idx+1)
+	apiTokensLock.Unlock()
+
+	if err := SaveConfig(); err != nil {
+		return fmt.Errorf("failed to save config: %w", err)
+	}
+
+	logger.Info().Str("id", id).Str("name", name).Msg("API token revoked")
+	return nil
+}
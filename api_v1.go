@@ -0,0 +1,303 @@
+package kvm
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"maps"
+	"mime"
+	"net/http"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jetkvm/kvm/internal/openapi"
+)
+
+// The REST API exposes the JSON-RPC handlers over HTTP, for the scripts that don't
+// want to set up a WebRTC session. POST /api/v1/methods/<method> takes the params as a
+// JSON object and answers {"result": ...}, the handlers without params that only read
+// the state can also be called with GET.
+
+const apiV1Prefix = "/api/v1"
+
+// maxAPIRequestBody is enough for any RPC params, the files go through /storage/upload
+const maxAPIRequestBody = 1024 * 1024
+
+var (
+	errorType = reflect.TypeOf((*error)(nil)).Elem()
+
+	openAPIDocument     []byte
+	openAPIDocumentOnce sync.Once
+)
+
+// APIError is the body of every error response of the REST API.
+type APIError struct {
+	Error string `json:"error"`
+	// Code is a stable identifier of the error, Error is meant for humans
+	Code string `json:"code"`
+}
+
+func sendAPIError(c *gin.Context, status int, code string, message string) {
+	c.AbortWithStatusJSON(status, APIError{Error: message, Code: code})
+}
+
+// isAPIMethod reports whether the handler can be called through the REST API, the
+// handlers working on the calling WebRTC session can't.
+func isAPIMethod(handler RPCHandler) bool {
+	handlerType := reflect.TypeOf(handler.Func)
+	return handlerType.Kind() == reflect.Func &&
+		!(handlerType.NumIn() > 0 && handlerType.In(0) == sessionType)
+}
+
+// isAPIReadMethod reports whether the handler can be called with GET.
+func isAPIReadMethod(handler RPCHandler) bool {
+	return handler.Permission == PermissionView && len(handler.Params) == 0
+}
+
+func apiMethodNames() []string {
+	names := make([]string, 0, len(rpcHandlers))
+	for name, handler := range rpcHandlers {
+		if isAPIMethod(handler) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func setupAPIV1Routes(protected *gin.RouterGroup) {
+	api := protected.Group(apiV1Prefix)
+	api.GET("/openapi.json", handleAPIOpenAPI)
+	api.GET("/methods", handleAPIListMethods)
+	api.GET("/methods/:method", handleAPICall)
+	api.POST("/methods/:method", handleAPICall)
+}
+
+func handleAPIListMethods(c *gin.Context) {
+	role := getRequestRole(c)
+
+	methods := make([]string, 0)
+	for _, name := range apiMethodNames() {
+		if role.allows(rpcHandlers[name].Permission) {
+			methods = append(methods, name)
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"methods": methods})
+}
+
+func handleAPICall(c *gin.Context) {
+	method := c.Param("method")
+	handler, ok := rpcHandlers[method]
+	if !ok || !isAPIMethod(handler) {
+		sendAPIError(c, http.StatusNotFound, "method_not_found", "Method not found")
+		return
+	}
+
+	if c.Request.Method == http.MethodGet && !isAPIReadMethod(handler) {
+		c.Header("Allow", http.MethodPost)
+		sendAPIError(c, http.StatusMethodNotAllowed, "method_not_allowed", "Use POST to call this method")
+		return
+	}
+
+	params := map[string]any{}
+	if c.Request.Method == http.MethodPost {
+		// a form on another site can only send the types of the simple requests, requiring
+		// JSON makes the browser ask with a CORS preflight first
+		mediaType, _, err := mime.ParseMediaType(c.GetHeader("Content-Type"))
+		if err != nil || mediaType != "application/json" {
+			sendAPIError(c, http.StatusUnsupportedMediaType, "invalid_request", "The Content-Type must be application/json")
+			return
+		}
+
+		body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxAPIRequestBody+1))
+		if err != nil {
+			sendAPIError(c, http.StatusBadRequest, "invalid_request", "Failed to read the request body")
+			return
+		}
+		if len(body) > maxAPIRequestBody {
+			sendAPIError(c, http.StatusRequestEntityTooLarge, "invalid_request", "Request body is too large")
+			return
+		}
+		if len(strings.TrimSpace(string(body))) > 0 {
+			if err := json.Unmarshal(body, &params); err != nil {
+				sendAPIError(c, http.StatusBadRequest, "invalid_request", "The request body must be a JSON object of the params")
+				return
+			}
+		}
+	}
+
+	if !getRequestRole(c).allows(handler.Permission) {
+		auditAPICall(c, method, params, "denied", "permission denied")
+		sendAPIError(c, http.StatusForbidden, "permission_denied", "Permission denied")
+		return
+	}
+
+	// the REST API has no session, the control is only free to take when no session holds it
+	if handler.RequiresControl {
+		sessionsLock.RLock()
+		controlled := controllerSession != nil
+		sessionsLock.RUnlock()
+		if controlled {
+			sendAPIError(c, http.StatusConflict, "control_held", "Another session has control")
+			return
+		}
+	}
+
+	result, err := invokeRPCHandler(c.Request.Context(), handler, params, nil)
+	if shouldAuditRPC(method, handler) {
+		status, errMessage := auditResult(err)
+		auditAPICall(c, method, params, status, errMessage)
+	}
+	if errors.Is(err, errInvalidParams) {
+		sendAPIError(c, http.StatusBadRequest, "invalid_params", err.Error())
+		return
+	}
+	if errors.Is(err, errRPCTimeout) {
+		sendAPIError(c, http.StatusGatewayTimeout, "timeout", err.Error())
+		return
+	}
+	if err != nil {
+		jsonRpcLogger.Warn().Err(err).Str("method", method).Msg("error calling RPC handler from the REST API")
+		sendAPIError(c, http.StatusInternalServerError, "handler_error", err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"result": result})
+}
+
+func handleAPIOpenAPI(c *gin.Context) {
+	openAPIDocumentOnce.Do(func() {
+		document, err := json.Marshal(buildOpenAPIDocument())
+		if err != nil {
+			jsonRpcLogger.Warn().Err(err).Msg("failed to encode the OpenAPI document")
+			return
+		}
+		openAPIDocument = document
+	})
+
+	if openAPIDocument == nil {
+		sendAPIError(c, http.StatusInternalServerError, "internal_error", "Failed to generate the OpenAPI document")
+		return
+	}
+	c.Data(http.StatusOK, "application/json", openAPIDocument)
+}
+
+// buildOpenAPIDocument describes the REST API from the handler table, the params
+// and the results come from the signatures of the handlers.
+func buildOpenAPIDocument() map[string]any {
+	generator := openapi.NewGenerator()
+	// registers the APIError component referenced by the error responses
+	generator.Schema(reflect.TypeOf(APIError{}))
+
+	errorResponse := func(description string) map[string]any {
+		return map[string]any{
+			"description": description,
+			"content": map[string]any{
+				"application/json": map[string]any{
+					"schema": openapi.Schema{"$ref": "#/components/schemas/APIError"},
+				},
+			},
+		}
+	}
+
+	paths := map[string]any{}
+	for _, name := range apiMethodNames() {
+		handler := rpcHandlers[name]
+		handlerType := reflect.TypeOf(handler.Func)
+
+		// the context given by the dispatcher isn't a param
+		offset := 0
+		if handlerType.NumIn() > 0 && handlerType.In(0) == contextType {
+			offset = 1
+		}
+		properties := openapi.Schema{}
+		for i, param := range handler.Params {
+			if i+offset < handlerType.NumIn() {
+				properties[param] = generator.Schema(handlerType.In(i + offset))
+			}
+		}
+
+		var resultSchema openapi.Schema
+		for i := range handlerType.NumOut() {
+			if out := handlerType.Out(i); out != errorType {
+				resultSchema = generator.Schema(out)
+			}
+		}
+		resultProperties := openapi.Schema{}
+		if resultSchema != nil {
+			resultProperties["result"] = resultSchema
+		}
+
+		operation := map[string]any{
+			"operationId":            name,
+			"tags":                   []string{string(handler.Permission)},
+			"x-jetkvm-permission":    handler.Permission,
+			"x-jetkvm-needs-control": handler.RequiresControl,
+			"responses": map[string]any{
+				"200": map[string]any{
+					"description": "The result of the handler",
+					"content": map[string]any{
+						"application/json": map[string]any{
+							"schema": openapi.Schema{"type": "object", "properties": resultProperties},
+						},
+					},
+				},
+				"400": errorResponse("Invalid params"),
+				"401": errorResponse("Not authenticated"),
+				"403": errorResponse("The role doesn't allow the method"),
+				"409": errorResponse("Another session has control"),
+				"415": errorResponse("The request body isn't JSON"),
+				"500": errorResponse("The handler failed"),
+				"504": errorResponse("The handler didn't return before its deadline"),
+			},
+		}
+
+		if len(handler.Params) > 0 {
+			operation["requestBody"] = map[string]any{
+				"required": true,
+				"content": map[string]any{
+					"application/json": map[string]any{
+						"schema": openapi.Schema{
+							"type":       "object",
+							"properties": properties,
+							"required":   slices.Clone(handler.Params),
+						},
+					},
+				},
+			}
+		}
+
+		path := map[string]any{"post": operation}
+		if isAPIReadMethod(handler) {
+			getOperation := maps.Clone(operation)
+			getOperation["operationId"] = name + "_get"
+			path["get"] = getOperation
+		}
+		paths["/methods/"+name] = path
+	}
+
+	return map[string]any{
+		"openapi": "3.0.3",
+		"info": map[string]any{
+			"title":   "JetKVM API",
+			"version": builtAppVersion,
+		},
+		"servers": []map[string]any{{"url": apiV1Prefix}},
+		"security": []map[string]any{
+			{"bearerAuth": []string{}},
+			{"cookieAuth": []string{}},
+		},
+		"paths": paths,
+		"components": map[string]any{
+			"schemas": generator.Components(),
+			"securitySchemes": map[string]any{
+				"bearerAuth": map[string]any{"type": "http", "scheme": "bearer", "description": "API token"},
+				"cookieAuth": map[string]any{"type": "apiKey", "in": "cookie", "name": authTokenCookieName},
+			},
+		},
+	}
+}
@@ -0,0 +1,249 @@
+package kvm
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/jetkvm/kvm/internal/audit"
+)
+
+const (
+	auditLogPath    = "/userdata/kvm_audit.jsonl"
+	auditLogOldPath = "/userdata/kvm_audit.1.jsonl"
+	// auditKeyPath is the HMAC key of the chain, it never leaves the device
+	auditKeyPath = "/userdata/kvm_audit.key"
+
+	// the log is rotated once it reaches maxAuditLogSize, a single rotated file is kept
+	maxAuditLogSize = 1024 * 1024
+
+	// auditHeadLogInterval is how often the head of the chain is logged when it changed,
+	// a remote syslog sink keeps it out of reach of someone rewriting the log on the device
+	auditHeadLogInterval = 15 * time.Minute
+
+	maxAuditParamLen    = 256
+	defaultAuditEntries = 100
+	maxAuditEntries     = 1000
+)
+
+// auditRedactedKeys are the params whose values never end up in the audit log, the
+// text typed on the host is often a password.
+var auditRedactedKeys = []string{"password", "secret", "token", "code", "sshkey", "certificate", "private", "text"}
+
+// auditSkippedMethods are sent for every key press and mouse move, logging them
+// would flood the log and record what was typed.
+var auditSkippedMethods = []string{"keyboardReport", "absMouseReport", "relMouseReport", "wheelReport"}
+
+type (
+	AuditEntry        = audit.Entry
+	AuditVerifyResult = audit.VerifyResult
+)
+
+var auditLogger = audit.New(audit.Options{
+	Path:    auditLogPath,
+	OldPath: auditLogOldPath,
+	MaxSize: maxAuditLogSize,
+})
+
+// initAuditLog loads the key of the chain and opens the audit log.
+func initAuditLog() {
+	key, err := audit.LoadOrCreateKey(auditKeyPath)
+	if err != nil {
+		// the entries are still chained, but anyone can recompute the hashes
+		logger.Warn().Err(err).Msg("failed to load audit log key")
+	}
+
+	if err := auditLogger.Open(key); err != nil {
+		logger.Warn().Err(err).Msg("failed to open audit log")
+	}
+
+	seq, hash := auditLogger.Head()
+	logger.Info().Int64("seq", seq).Str("hash", hash).Msg("audit log opened")
+
+	go logAuditHead(seq)
+}
+
+func logAuditHead(lastLoggedSeq int64) {
+	ticker := time.NewTicker(auditHeadLogInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		seq, hash := auditLogger.Head()
+		if seq == lastLoggedSeq {
+			continue
+		}
+		logger.Info().Int64("seq", seq).Str("hash", hash).Msg("audit log head")
+		lastLoggedSeq = seq
+	}
+}
+
+func appendAuditEntry(entry AuditEntry) {
+	if _, err := auditLogger.Append(entry); err != nil {
+		logger.Warn().Err(err).Str("action", entry.Action).Msg("failed to write audit entry")
+	}
+}
+
+// sanitizeAuditValue redacts the secrets and truncates the long values.
+func sanitizeAuditValue(key string, value any) any {
+	lowerKey := strings.ToLower(key)
+	if slices.ContainsFunc(auditRedactedKeys, func(redacted string) bool {
+		return strings.Contains(lowerKey, redacted)
+	}) {
+		return "[redacted]"
+	}
+
+	switch v := value.(type) {
+	case string:
+		if len(v) > maxAuditParamLen {
+			// a cut in the middle of a character would change when read back and break the hash
+			return strings.ToValidUTF8(v[:maxAuditParamLen], "") + "..."
+		}
+		return v
+	case map[string]any:
+		return sanitizeAuditParams(v)
+	case []any:
+		sanitized := make([]any, 0, len(v))
+		for _, item := range v {
+			sanitized = append(sanitized, sanitizeAuditValue(key, item))
+		}
+		return sanitized
+	}
+	return value
+}
+
+func sanitizeAuditParams(params map[string]any) map[string]any {
+	if len(params) == 0 {
+		return nil
+	}
+
+	sanitized := make(map[string]any, len(params))
+	for key, value := range params {
+		sanitized[key] = sanitizeAuditValue(key, value)
+	}
+	return sanitized
+}
+
+func auditResult(err error) (string, string) {
+	if err != nil {
+		return "error", err.Error()
+	}
+	return "ok", ""
+}
+
+// shouldAuditRPC reports whether the call is logged, the calls that only read
+// the state are left out.
+func shouldAuditRPC(method string, handler RPCHandler) bool {
+	if handler.Permission == PermissionView {
+		return false
+	}
+	return !slices.Contains(auditSkippedMethods, method)
+}
+
+func auditRPCCall(session *Session, method string, params map[string]any, result string, errMessage string) {
+	origin := "local"
+	if session.IsCloud {
+		origin = "cloud"
+	}
+
+	entry := AuditEntry{
+		Time:      time.Now().UTC(),
+		Origin:    origin,
+		ClientIP:  session.Source,
+		SessionID: session.ID,
+		Role:      string(session.Role),
+		Action:    method,
+		Params:    sanitizeAuditParams(params),
+		Result:    result,
+		Error:     errMessage,
+	}
+	if session.LoginSessionID != "" {
+		if loginSession, ok := loginSessions.get(session.LoginSessionID); ok {
+			entry.Username = loginSession.Username
+		}
+	}
+
+	appendAuditEntry(entry)
+}
+
+// auditHTTP records an action of the HTTP API, such as a login. The role is only
+// known for the requests that went through protectedMiddleware.
+func auditHTTP(c *gin.Context, action string, username string, result string, errMessage string) {
+	entry := AuditEntry{
+		Time:     time.Now().UTC(),
+		Origin:   "local",
+		ClientIP: c.ClientIP(),
+		Username: username,
+		Action:   action,
+		Result:   result,
+		Error:    errMessage,
+	}
+	if role, ok := c.Get(roleContextKey); ok {
+		entry.Role = string(role.(Role))
+	}
+	if loginSession, ok := c.Get(loginSessionContextKey); ok && username == "" {
+		entry.Username = loginSession.(*LoginSession).Username
+	}
+
+	appendAuditEntry(entry)
+}
+
+// auditAPICall records a RPC handler called through the REST API.
+func auditAPICall(c *gin.Context, method string, params map[string]any, result string, errMessage string) {
+	entry := AuditEntry{
+		Time:     time.Now().UTC(),
+		Origin:   "api",
+		ClientIP: c.ClientIP(),
+		Role:     string(getRequestRole(c)),
+		Action:   method,
+		Params:   sanitizeAuditParams(params),
+		Result:   result,
+		Error:    errMessage,
+	}
+	if loginSession, ok := c.Get(loginSessionContextKey); ok {
+		entry.Username = loginSession.(*LoginSession).Username
+	}
+
+	appendAuditEntry(entry)
+}
+
+// rpcGetAuditLog returns the latest entries, newest first. beforeSeq pages through
+// the older entries, 0 starts from the latest one.
+func rpcGetAuditLog(limit int, beforeSeq int64) ([]AuditEntry, error) {
+	if limit <= 0 {
+		limit = defaultAuditEntries
+	}
+	limit = min(limit, maxAuditEntries)
+
+	entries, err := auditLogger.Entries()
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]AuditEntry, 0, min(limit, len(entries)))
+	for i := len(entries) - 1; i >= 0 && len(result) < limit; i-- {
+		if beforeSeq > 0 && entries[i].Seq >= beforeSeq {
+			continue
+		}
+		result = append(result, entries[i])
+	}
+	return result, nil
+}
+
+// rpcVerifyAuditLog checks the chain of the entries still on disk.
+func rpcVerifyAuditLog() (AuditVerifyResult, error) {
+	return auditLogger.Verify(), nil
+}
+
+// handleAuditLogDownload sends the rotated and the current audit log as JSONL.
+func handleAuditLogDownload(c *gin.Context) {
+	c.Header("Content-Type", "application/x-ndjson")
+	c.Header("Content-Disposition", `attachment; filename="jetkvm-audit.jsonl"`)
+	c.Status(http.StatusOK)
+
+	if err := auditLogger.Copy(c.Writer); err != nil {
+		logger.Warn().Err(err).Msg("failed to send audit log")
+	}
+}
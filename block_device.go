@@ -19,10 +19,12 @@ func (r remoteImageBackend) ReadAt(p []byte, off int64) (n int, err error) {
 	logger.Debug().Interface("currentVirtualMediaState", currentVirtualMediaState).Msg("currentVirtualMediaState")
 	logger.Debug().Int64("read size", int64(len(p))).Int64("off", off).Msg("read size and off")
 	if currentVirtualMediaState == nil {
+		virtualMediaStateMutex.RUnlock()
 		return 0, errors.New("image not mounted")
 	}
 	source := currentVirtualMediaState.Source
 	mountedImageSize := currentVirtualMediaState.Size
+	image := httpImage
 	virtualMediaStateMutex.RUnlock()
 
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
@@ -42,7 +44,10 @@ func (r remoteImageBackend) ReadAt(p []byte, off int64) (n int, err error) {
 		n = copy(p, data)
 		return n, nil
 	case HTTP:
-		return httpRangeReader.ReadAt(p, off)
+		if image == nil {
+			return 0, errors.New("http image not available")
+		}
+		return image.ReadAt(p, off)
 	default:
 		return 0, errors.New("unknown image source")
 	}
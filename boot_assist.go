@@ -0,0 +1,336 @@
+package kvm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/jetkvm/kvm/internal/keyboard"
+)
+
+// The boot key assistant presses the key opening the firmware setup or the boot menu
+// over and over from the power or reset action, so that the key lands in the short
+// window the firmware listens for it.
+//
+// It stops when the firmware reacts, which is spotted from the video: the setup and the
+// boot menus usually switch the resolution of the POST screen. The resolution of the
+// first video signal seen once the signal dropped after the action is the one of the
+// POST screen, and a signal lost in between starts over. When the signal doesn't drop
+// on reset, the states right after the action can still be the ones of the old OS, the
+// resolution at the end of bootKeyVideoGrace is taken instead.
+
+const (
+	defaultBootKeyInterval = 200 * time.Millisecond
+	minBootKeyInterval     = 50 * time.Millisecond
+	maxBootKeyInterval     = 2 * time.Second
+	defaultBootKeyTimeout  = time.Minute
+	maxBootKeyTimeout      = 5 * time.Minute
+	// bootKeyHold is how long each press lasts
+	bootKeyHold = 50 * time.Millisecond
+	// bootKeyVideoGrace is how long after the action the video states are ignored,
+	// unless the signal dropped
+	bootKeyVideoGrace = 5 * time.Second
+)
+
+const (
+	BootKeyAssistRunning   = "running"
+	BootKeyAssistDone      = "done"
+	BootKeyAssistTimeout   = "timeout"
+	BootKeyAssistCancelled = "cancelled"
+	BootKeyAssistFailed    = "failed"
+)
+
+// BootKeyPreset is the key a firmware vendor uses to open a menu.
+type BootKeyPreset struct {
+	ID     string `json:"id"`
+	Vendor string `json:"vendor"`
+	Name   string `json:"name"`
+	// Key is named by its KeyboardEvent.code, like the keys of the macros
+	Key string `json:"key"`
+}
+
+var bootKeyPresets = []BootKeyPreset{
+	{ID: "generic-setup-del", Vendor: "Generic", Name: "Setup (Del)", Key: "Delete"},
+	{ID: "generic-setup-f2", Vendor: "Generic", Name: "Setup (F2)", Key: "F2"},
+	{ID: "generic-boot-menu", Vendor: "Generic", Name: "Boot menu (F12)", Key: "F12"},
+	{ID: "dell-setup", Vendor: "Dell", Name: "Setup (F2)", Key: "F2"},
+	{ID: "dell-boot-menu", Vendor: "Dell", Name: "One-time boot menu (F12)", Key: "F12"},
+	{ID: "hp-setup", Vendor: "HP", Name: "Setup (F10)", Key: "F10"},
+	{ID: "hp-boot-menu", Vendor: "HP", Name: "Boot menu (F9)", Key: "F9"},
+	{ID: "hp-startup-menu", Vendor: "HP", Name: "Startup menu (Esc)", Key: "Escape"},
+	{ID: "lenovo-setup", Vendor: "Lenovo", Name: "Setup (F1)", Key: "F1"},
+	{ID: "lenovo-boot-menu", Vendor: "Lenovo", Name: "Boot menu (F12)", Key: "F12"},
+	{ID: "supermicro-setup", Vendor: "Supermicro", Name: "Setup (Del)", Key: "Delete"},
+	{ID: "supermicro-boot-menu", Vendor: "Supermicro", Name: "Boot menu (F11)", Key: "F11"},
+	{ID: "asus-setup", Vendor: "ASUS", Name: "Setup (Del)", Key: "Delete"},
+	{ID: "asus-boot-menu", Vendor: "ASUS", Name: "Boot menu (F8)", Key: "F8"},
+	{ID: "asrock-setup", Vendor: "ASRock", Name: "Setup (F2)", Key: "F2"},
+	{ID: "asrock-boot-menu", Vendor: "ASRock", Name: "Boot menu (F11)", Key: "F11"},
+	{ID: "gigabyte-setup", Vendor: "Gigabyte", Name: "Setup (Del)", Key: "Delete"},
+	{ID: "gigabyte-boot-menu", Vendor: "Gigabyte", Name: "Boot menu (F12)", Key: "F12"},
+	{ID: "msi-setup", Vendor: "MSI", Name: "Setup (Del)", Key: "Delete"},
+	{ID: "msi-boot-menu", Vendor: "MSI", Name: "Boot menu (F11)", Key: "F11"},
+	{ID: "intel-nuc-setup", Vendor: "Intel NUC", Name: "Setup (F2)", Key: "F2"},
+	{ID: "intel-nuc-boot-menu", Vendor: "Intel NUC", Name: "Boot menu (F10)", Key: "F10"},
+	{ID: "acer-setup", Vendor: "Acer", Name: "Setup (F2)", Key: "F2"},
+	{ID: "acer-boot-menu", Vendor: "Acer", Name: "Boot menu (F12)", Key: "F12"},
+}
+
+type BootKeyAssistParams struct {
+	// Preset is the id of a preset, or empty to use Key
+	Preset string `json:"preset,omitempty"`
+	Key    string `json:"key,omitempty"`
+	// Action is the ATX action started first: reset, power-short, or empty for none
+	Action string `json:"action,omitempty"`
+	// Interval is the time between two presses in milliseconds, 200 when zero
+	Interval int `json:"interval,omitempty"`
+	// Timeout is how long the key is pressed at most in milliseconds, a minute when zero
+	Timeout int `json:"timeout,omitempty"`
+}
+
+// BootKeyAssistState is the progress of the assistant, sent in the bootKeyAssistState events.
+type BootKeyAssistState struct {
+	ID      string `json:"id"`
+	State   string `json:"state"`
+	Key     string `json:"key"`
+	Action  string `json:"action,omitempty"`
+	Presses int    `json:"presses"`
+	// Width and Height are the resolution the firmware switched to, once done
+	Width  int    `json:"width,omitempty"`
+	Height int    `json:"height,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+type bootKeyAssist struct {
+	state  BootKeyAssistState
+	cancel context.CancelFunc
+	video  chan VideoInputState
+}
+
+var (
+	bootKeyAssistLock    sync.Mutex
+	currentBootKeyAssist *bootKeyAssist
+)
+
+func rpcGetBootKeyPresets() []BootKeyPreset {
+	return bootKeyPresets
+}
+
+// rpcStartBootKeyAssist starts the action and the presses, and returns right away.
+func rpcStartBootKeyAssist(params BootKeyAssistParams) (*BootKeyAssistState, error) {
+	keyName := params.Key
+	if params.Preset != "" {
+		idx := slices.IndexFunc(bootKeyPresets, func(p BootKeyPreset) bool { return p.ID == params.Preset })
+		if idx == -1 {
+			return nil, fmt.Errorf("%w: unknown preset %s", errInvalidParams, params.Preset)
+		}
+		keyName = bootKeyPresets[idx].Key
+	}
+	key, ok := keyboard.KeyCode(keyName)
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown key %q", errInvalidParams, keyName)
+	}
+
+	if params.Action != "" && params.Action != "reset" && params.Action != "power-short" {
+		return nil, fmt.Errorf("%w: invalid action %q, expected reset or power-short", errInvalidParams, params.Action)
+	}
+
+	interval := time.Duration(params.Interval) * time.Millisecond
+	if interval == 0 {
+		interval = defaultBootKeyInterval
+	}
+	if interval < minBootKeyInterval || interval > maxBootKeyInterval {
+		return nil, fmt.Errorf("%w: the interval must be between %d and %d ms", errInvalidParams, minBootKeyInterval.Milliseconds(), maxBootKeyInterval.Milliseconds())
+	}
+	timeout := time.Duration(params.Timeout) * time.Millisecond
+	if timeout == 0 {
+		timeout = defaultBootKeyTimeout
+	}
+	if timeout < 0 || timeout > maxBootKeyTimeout {
+		return nil, fmt.Errorf("%w: the timeout must be at most %d ms", errInvalidParams, maxBootKeyTimeout.Milliseconds())
+	}
+
+	bootKeyAssistLock.Lock()
+	defer bootKeyAssistLock.Unlock()
+	if currentBootKeyAssist != nil && currentBootKeyAssist.state.State == BootKeyAssistRunning {
+		return nil, errors.New("the boot key assistant is already running")
+	}
+	if err := claimHIDAutomation("the boot key assistant"); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	assist := &bootKeyAssist{
+		state: BootKeyAssistState{
+			ID:     uuid.New().String(),
+			State:  BootKeyAssistRunning,
+			Key:    keyName,
+			Action: params.Action,
+		},
+		cancel: cancel,
+		video:  make(chan VideoInputState, 8),
+	}
+	currentBootKeyAssist = assist
+
+	go runBootKeyAssist(ctx, assist, key, interval)
+
+	state := assist.state
+	return &state, nil
+}
+
+func runBootKeyAssist(ctx context.Context, assist *bootKeyAssist, key uint8, interval time.Duration) {
+	defer assist.cancel()
+
+	scopedLogger := keyboardLogger.With().
+		Str("id", assist.state.ID).
+		Str("key", assist.state.Key).
+		Str("action", assist.state.Action).
+		Logger()
+	scopedLogger.Info().Msg("starting the boot key assistant")
+	broadcastBootKeyAssistState(assist)
+
+	// the POST screen is the first signal after the action, the current one without action
+	var postScreen *VideoInputState
+	if current := getVideoState(); assist.state.Action == "" && current.Ready {
+		postScreen = &current
+	}
+	// graceOver is nil once the states of the old OS can't come anymore
+	var graceOver <-chan time.Time
+
+	var changedTo *VideoInputState
+	err := func() error {
+		if assist.state.Action != "" {
+			if err := rpcSetATXPowerAction(assist.state.Action); err != nil {
+				return fmt.Errorf("failed to %s the host: %w", assist.state.Action, err)
+			}
+			grace := time.NewTimer(bootKeyVideoGrace)
+			defer grace.Stop()
+			graceOver = grace.C
+		}
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			if err := pressBootKey(ctx, key); err != nil {
+				return err
+			}
+			bootKeyAssistLock.Lock()
+			assist.state.Presses++
+			bootKeyAssistLock.Unlock()
+
+		wait:
+			for {
+				select {
+				case <-ctx.Done():
+					return ctx.Err()
+				case <-ticker.C:
+					break wait
+				case <-graceOver:
+					graceOver = nil
+					if current := getVideoState(); postScreen == nil && current.Ready {
+						postScreen = &current
+					}
+				case video := <-assist.video:
+					switch {
+					case !video.Ready:
+						postScreen = nil
+						graceOver = nil
+					case graceOver != nil:
+						// still the old OS, or the POST screen that the end of the grace picks
+					case postScreen == nil:
+						postScreen = &video
+					case video.Width != postScreen.Width || video.Height != postScreen.Height:
+						changedTo = &video
+						return nil
+					}
+				}
+			}
+		}
+	}()
+
+	if releaseErr := gadget.KeyboardReport(0, nil); releaseErr != nil {
+		scopedLogger.Warn().Err(releaseErr).Msg("failed to release the keys")
+	}
+	releaseHIDAutomation()
+
+	bootKeyAssistLock.Lock()
+	switch {
+	case changedTo != nil:
+		assist.state.State = BootKeyAssistDone
+		assist.state.Width = changedTo.Width
+		assist.state.Height = changedTo.Height
+	case errors.Is(err, context.DeadlineExceeded):
+		assist.state.State = BootKeyAssistTimeout
+	case errors.Is(err, context.Canceled):
+		assist.state.State = BootKeyAssistCancelled
+	default:
+		assist.state.State = BootKeyAssistFailed
+		assist.state.Error = err.Error()
+	}
+	bootKeyAssistLock.Unlock()
+
+	scopedLogger.Info().Err(err).Int("presses", assist.state.Presses).Str("state", assist.state.State).Msg("boot key assistant stopped")
+	broadcastBootKeyAssistState(assist)
+}
+
+func pressBootKey(ctx context.Context, key uint8) error {
+	if err := gadget.KeyboardReport(0, []uint8{key}); err != nil {
+		return fmt.Errorf("failed to press the key: %w", err)
+	}
+	holdErr := sleepContext(ctx, bootKeyHold)
+	if err := gadget.KeyboardReport(0, nil); err != nil {
+		return fmt.Errorf("failed to release the key: %w", err)
+	}
+	return holdErr
+}
+
+// notifyBootKeyAssistVideo passes the video states to the running assistant.
+func notifyBootKeyAssistVideo(state VideoInputState) {
+	bootKeyAssistLock.Lock()
+	defer bootKeyAssistLock.Unlock()
+
+	if currentBootKeyAssist == nil || currentBootKeyAssist.state.State != BootKeyAssistRunning {
+		return
+	}
+	select {
+	case currentBootKeyAssist.video <- state:
+	default:
+		keyboardLogger.Warn().Msg("boot key assistant is behind on the video states, dropping one")
+	}
+}
+
+func broadcastBootKeyAssistState(assist *bootKeyAssist) {
+	bootKeyAssistLock.Lock()
+	state := assist.state
+	bootKeyAssistLock.Unlock()
+
+	broadcastJSONRPCEvent("bootKeyAssistState", state)
+}
+
+func rpcCancelBootKeyAssist() error {
+	bootKeyAssistLock.Lock()
+	defer bootKeyAssistLock.Unlock()
+
+	if currentBootKeyAssist == nil || currentBootKeyAssist.state.State != BootKeyAssistRunning {
+		return errors.New("the boot key assistant isn't running")
+	}
+	currentBootKeyAssist.cancel()
+	return nil
+}
+
+// rpcGetBootKeyAssistState returns the progress of the last run, nil when there was none.
+func rpcGetBootKeyAssistState() *BootKeyAssistState {
+	bootKeyAssistLock.Lock()
+	defer bootKeyAssistLock.Unlock()
+
+	if currentBootKeyAssist == nil {
+		return nil
+	}
+	state := currentBootKeyAssist.state
+	return &state
+}
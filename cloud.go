@@ -276,6 +276,7 @@ func handleCloudRegister(c *gin.Context) {
 		return
 	}
 
+	auditHTTP(c, "registerCloud", "", "ok", "")
 	c.JSON(200, gin.H{"message": "Cloud registration successful"})
 }
 
@@ -295,6 +296,12 @@ func disconnectCloud(reason error) {
 		}
 	}()
 	cloudDisconnectChan <- reason
+
+	data := map[string]any{}
+	if reason != nil {
+		data["reason"] = reason.Error()
+	}
+	recordEvent(EventCloudDisconnected, "", "cloud websocket disconnected", data)
 }
 
 func runWebsocketClient() error {
@@ -385,7 +392,7 @@ func runWebsocketClient() error {
 	wsResetMetrics(true, "cloud", wsURL.Host)
 
 	// we don't have a source for the cloud connection
-	return handleWebRTCSignalWsMessages(c, true, wsURL.Host, connectionId, scopedLogger)
+	return handleWebRTCSignalWsMessages(c, true, wsURL.Host, connectionId, "", scopedLogger)
 }
 
 func authenticateSession(ctx context.Context, c *websocket.Conn, req WebRTCSessionRequest) error {
@@ -425,8 +432,9 @@ func handleSessionRequest(
 	req WebRTCSessionRequest,
 	isCloudConnection bool,
 	source string,
+	loginSessionID string,
 	scopedLogger *zerolog.Logger,
-) error {
+) (*Session, error) {
 	var sourceType string
 	if isCloudConnection {
 		sourceType = "cloud"
@@ -443,41 +451,39 @@ func handleSessionRequest(
 	// If the message is from the cloud, we need to authenticate the session.
 	if isCloudConnection {
 		if err := authenticateSession(ctx, c, req); err != nil {
-			return err
+			return nil, err
 		}
 	}
 
 	session, err := newSession(SessionConfig{
-		ws:         c,
-		IsCloud:    isCloudConnection,
-		LocalIP:    req.IP,
-		ICEServers: req.ICEServers,
-		Logger:     scopedLogger,
+		ws:             c,
+		IsCloud:        isCloudConnection,
+		LocalIP:        req.IP,
+		ICEServers:     req.ICEServers,
+		Source:         source,
+		LoginSessionID: loginSessionID,
+		Logger:         scopedLogger,
 	})
 	if err != nil {
 		_ = wsjson.Write(context.Background(), c, gin.H{"error": err})
-		return err
+		return nil, err
 	}
 
 	sd, err := session.ExchangeOffer(req.Sd)
 	if err != nil {
 		_ = wsjson.Write(context.Background(), c, gin.H{"error": err})
-		return err
+		return nil, err
 	}
-	if currentSession != nil {
-		writeJSONRPCEvent("otherSessionConnected", nil, currentSession)
-		peerConn := currentSession.peerConnection
-		go func() {
-			time.Sleep(1 * time.Second)
-			_ = peerConn.Close()
-		}()
+	if err := addSession(session); err != nil {
+		_ = session.peerConnection.Close()
+		_ = wsjson.Write(context.Background(), c, gin.H{"error": err.Error()})
+		return nil, err
 	}
 
-	cloudLogger.Info().Interface("session", session).Msg("new session accepted")
+	cloudLogger.Info().Str("sessionId", session.ID).Msg("new session accepted")
 	cloudLogger.Trace().Interface("session", session).Msg("new session accepted")
-	currentSession = session
 	_ = wsjson.Write(context.Background(), c, gin.H{"type": "answer", "data": sd})
-	return nil
+	return session, nil
 }
 
 func RunWebsocketClient() {
@@ -7,6 +7,7 @@ import (
 	"sync"
 
 	"github.com/jetkvm/kvm/internal/logging"
+	"github.com/jetkvm/kvm/internal/macros"
 	"github.com/jetkvm/kvm/internal/network"
 	"github.com/jetkvm/kvm/internal/usbgadget"
 )
@@ -16,64 +17,13 @@ type WakeOnLanDevice struct {
 	MacAddress string `json:"macAddress"`
 }
 
-// Constants for keyboard macro limits
-const (
-	MaxMacrosPerDevice = 25
-	MaxStepsPerMacro   = 10
-	MaxKeysPerStep     = 10
-	MinStepDelay       = 50
-	MaxStepDelay       = 2000
+// The keyboard macros are defined in internal/macros, the aliases keep the names used
+// by the RPC handlers.
+type (
+	KeyboardMacro     = macros.KeyboardMacro
+	KeyboardMacroStep = macros.KeyboardMacroStep
 )
 
-type KeyboardMacroStep struct {
-	Keys      []string `json:"keys"`
-	Modifiers []string `json:"modifiers"`
-	Delay     int      `json:"delay"`
-}
-
-func (s *KeyboardMacroStep) Validate() error {
-	if len(s.Keys) > MaxKeysPerStep {
-		return fmt.Errorf("too many keys in step (max %d)", MaxKeysPerStep)
-	}
-
-	if s.Delay < MinStepDelay {
-		s.Delay = MinStepDelay
-	} else if s.Delay > MaxStepDelay {
-		s.Delay = MaxStepDelay
-	}
-
-	return nil
-}
-
-type KeyboardMacro struct {
-	ID        string              `json:"id"`
-	Name      string              `json:"name"`
-	Steps     []KeyboardMacroStep `json:"steps"`
-	SortOrder int                 `json:"sortOrder,omitempty"`
-}
-
-func (m *KeyboardMacro) Validate() error {
-	if m.Name == "" {
-		return fmt.Errorf("macro name cannot be empty")
-	}
-
-	if len(m.Steps) == 0 {
-		return fmt.Errorf("macro must have at least one step")
-	}
-
-	if len(m.Steps) > MaxStepsPerMacro {
-		return fmt.Errorf("too many steps in macro (max %d)", MaxStepsPerMacro)
-	}
-
-	for i := range m.Steps {
-		if err := m.Steps[i].Validate(); err != nil {
-			return fmt.Errorf("invalid step %d: %w", i+1, err)
-		}
-	}
-
-	return nil
-}
-
 type Config struct {
 	CloudURL             string                 `json:"cloud_url"`
 	CloudAppURL          string                 `json:"cloud_app_url"`
@@ -83,8 +33,8 @@ type Config struct {
 	AutoUpdateEnabled    bool                   `json:"auto_update_enabled"`
 	IncludePreRelease    bool                   `json:"include_pre_release"`
 	HashedPassword       string                 `json:"hashed_password"`
-	LocalAuthToken       string                 `json:"local_auth_token"`
-	LocalAuthMode        string                 `json:"localAuthMode"` //TODO: fix it with migration
+	LocalAuthToken       string                 `json:"local_auth_token"` // deprecated: migrated to the login session store
+	LocalAuthMode        string                 `json:"localAuthMode"`    //TODO: fix it with migration
 	LocalLoopbackOnly    bool                   `json:"local_loopback_only"`
 	WakeOnLanDevices     []WakeOnLanDevice      `json:"wake_on_lan_devices"`
 	KeyboardMacros       []KeyboardMacro        `json:"keyboard_macros"`
@@ -100,6 +50,19 @@ type Config struct {
 	UsbDevices           *usbgadget.Devices     `json:"usb_devices"`
 	NetworkConfig        *network.NetworkConfig `json:"network_config"`
 	DefaultLogLevel      string                 `json:"default_log_level"`
+	HTTPImageCache       string                 `json:"http_image_cache"` // options: "memory", "disk"
+	HTTPImageCacheSizeMB int                    `json:"http_image_cache_size_mb"`
+	APITokens            []APIToken             `json:"api_tokens"`
+	TOTPEnabled          bool                   `json:"totp_enabled"`
+	TOTPSecret           string                 `json:"totp_secret"`
+	TOTPRecoveryCodes    []string               `json:"totp_recovery_codes"` // hashed
+	OIDCConfig           *OIDCConfig            `json:"oidc_config"`
+	LocalUsers           []LocalUser            `json:"local_users"`
+	LogSinks             *logging.SinksConfig   `json:"log_sinks"`
+	LogScopeLevels       map[string]string      `json:"log_scope_levels"`
+	EventHistoryPersist  bool                   `json:"event_history_persist"`
+	Webhooks             []Webhook              `json:"webhooks"`
+	MQTTConfig           *MQTTConfig            `json:"mqtt_config"`
 }
 
 const configPath = "/userdata/kvm_config.json"
@@ -131,6 +94,14 @@ var defaultConfig = &Config{
 	},
 	NetworkConfig:   &network.NetworkConfig{},
 	DefaultLogLevel: "INFO",
+	HTTPImageCache:  "memory",
+	LogSinks: &logging.SinksConfig{
+		// off until enabled, the file is written to the flash storage of the device
+		File: &logging.FileSinkConfig{
+			Enabled: false,
+			Level:   "INFO",
+		},
+	},
 }
 
 var (
@@ -180,6 +151,7 @@ func LoadConfig() {
 	config = &loadedConfig
 
 	logging.GetRootLogger().UpdateLogLevel(config.DefaultLogLevel)
+	logging.GetRootLogger().UpdateScopeLevels(config.LogScopeLevels)
 
 	logger.Info().Str("path", configPath).Msg("config loaded")
 }
@@ -0,0 +1,270 @@
+package kvm
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"sync"
+	"time"
+)
+
+const (
+	eventHistoryPath = "/userdata/kvm_events.jsonl"
+
+	// maxEventHistory is the size of the ring buffer, the oldest events are dropped first
+	maxEventHistory     = 1000
+	defaultEventHistory = 100
+)
+
+// EventType is the kind of a recorded event.
+type EventType string
+
+const (
+	EventSessionOpened       EventType = "session_opened"
+	EventSessionClosed       EventType = "session_closed"
+	EventICEFailed           EventType = "ice_failed"
+	EventCloudDisconnected   EventType = "cloud_disconnected"
+	EventUSBStateChanged     EventType = "usb_state_changed"
+	EventVideoSignalLost     EventType = "video_signal_lost"
+	EventVideoSignalRestored EventType = "video_signal_restored"
+	EventATXPowerChanged     EventType = "atx_power_changed"
+	EventOTACompleted        EventType = "ota_completed"
+	EventOTAFailed           EventType = "ota_failed"
+)
+
+// HistoryEvent is a connection or device event kept for diagnosing the host.
+type HistoryEvent struct {
+	Seq       int64          `json:"seq"`
+	Time      time.Time      `json:"time"`
+	Type      EventType      `json:"type"`
+	SessionID string         `json:"sessionId,omitempty"`
+	Message   string         `json:"message"`
+	Data      map[string]any `json:"data,omitempty"`
+}
+
+// EventHistoryFilter selects the events returned by getEventHistory, the zero
+// value returns the latest events of any type.
+type EventHistoryFilter struct {
+	Types     []EventType `json:"types"`
+	SessionID string      `json:"sessionId"`
+	Since     time.Time   `json:"since"`
+	Until     time.Time   `json:"until"`
+	Limit     int         `json:"limit"`
+}
+
+type eventHistory struct {
+	lock    sync.Mutex
+	events  []HistoryEvent
+	lastSeq int64
+	// lines is the number of events in the persisted file, it is rewritten from
+	// the buffer once it holds twice as many events as the buffer
+	lines int
+}
+
+var eventRecorder = &eventHistory{}
+
+func (f *EventHistoryFilter) matches(event *HistoryEvent) bool {
+	if len(f.Types) > 0 && !slices.Contains(f.Types, event.Type) {
+		return false
+	}
+	if f.SessionID != "" && event.SessionID != f.SessionID {
+		return false
+	}
+	if !f.Since.IsZero() && event.Time.Before(f.Since) {
+		return false
+	}
+	if !f.Until.IsZero() && event.Time.After(f.Until) {
+		return false
+	}
+	return true
+}
+
+// initEventHistory loads the persisted events, if the persistence is enabled.
+func initEventHistory() {
+	if !config.EventHistoryPersist {
+		return
+	}
+
+	eventRecorder.lock.Lock()
+	defer eventRecorder.lock.Unlock()
+
+	loaded, err := readEventHistoryFile()
+	if err != nil && !os.IsNotExist(err) {
+		logger.Warn().Err(err).Msg("failed to read event history")
+	}
+
+	eventRecorder.lines = len(loaded)
+	if len(loaded) > maxEventHistory {
+		loaded = loaded[len(loaded)-maxEventHistory:]
+	}
+	eventRecorder.events = loaded
+	if len(loaded) > 0 {
+		eventRecorder.lastSeq = loaded[len(loaded)-1].Seq
+	}
+}
+
+func readEventHistoryFile() ([]HistoryEvent, error) {
+	file, err := os.Open(eventHistoryPath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	loaded := make([]HistoryEvent, 0)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var event HistoryEvent
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			// a line cut by a power loss, skip it
+			continue
+		}
+		loaded = append(loaded, event)
+	}
+	return loaded, scanner.Err()
+}
+
+// persistLocked appends the event to the file, or rewrites the file from the buffer
+// when it grew too much.
+func (h *eventHistory) persistLocked(event HistoryEvent) error {
+	if h.lines+1 > 2*maxEventHistory {
+		return h.rewriteLocked()
+	}
+
+	line, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(eventHistoryPath, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	h.lines++
+	return nil
+}
+
+func (h *eventHistory) rewriteLocked() error {
+	tmpPath := eventHistoryPath + ".tmp"
+	file, err := os.OpenFile(tmpPath, os.O_WRONLY|os.O_TRUNC|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+
+	encoder := json.NewEncoder(file)
+	for i := range h.events {
+		if err := encoder.Encode(&h.events[i]); err != nil {
+			file.Close()
+			return err
+		}
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmpPath, eventHistoryPath); err != nil {
+		return err
+	}
+	h.lines = len(h.events)
+	return nil
+}
+
+// recordEvent adds an event to the history and sends it to the webhooks.
+func recordEvent(eventType EventType, sessionID string, message string, data map[string]any) {
+	eventRecorder.lock.Lock()
+
+	event := HistoryEvent{
+		Seq:       eventRecorder.lastSeq + 1,
+		Time:      time.Now().UTC(),
+		Type:      eventType,
+		SessionID: sessionID,
+		Message:   message,
+		Data:      data,
+	}
+	eventRecorder.lastSeq = event.Seq
+
+	eventRecorder.events = append(eventRecorder.events, event)
+	if len(eventRecorder.events) > maxEventHistory {
+		eventRecorder.events = slices.Delete(eventRecorder.events, 0, len(eventRecorder.events)-maxEventHistory)
+	}
+
+	if config != nil && config.EventHistoryPersist {
+		if err := eventRecorder.persistLocked(event); err != nil {
+			logger.Warn().Err(err).Str("type", string(eventType)).Msg("failed to persist event")
+		}
+	}
+	eventRecorder.lock.Unlock()
+
+	notifyWebhooks(event)
+}
+
+// rpcGetEventHistory returns the events matching the filter, newest first.
+func rpcGetEventHistory(filter EventHistoryFilter) ([]HistoryEvent, error) {
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = defaultEventHistory
+	}
+	limit = min(limit, maxEventHistory)
+
+	eventRecorder.lock.Lock()
+	defer eventRecorder.lock.Unlock()
+
+	result := make([]HistoryEvent, 0, min(limit, len(eventRecorder.events)))
+	for i := len(eventRecorder.events) - 1; i >= 0 && len(result) < limit; i-- {
+		if filter.matches(&eventRecorder.events[i]) {
+			result = append(result, eventRecorder.events[i])
+		}
+	}
+	return result, nil
+}
+
+func rpcClearEventHistory() error {
+	eventRecorder.lock.Lock()
+	defer eventRecorder.lock.Unlock()
+
+	eventRecorder.events = nil
+	if err := os.Remove(eventHistoryPath); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove event history: %w", err)
+	}
+	eventRecorder.lines = 0
+
+	logger.Info().Msg("event history cleared")
+	return nil
+}
+
+func rpcGetEventHistoryPersist() (bool, error) {
+	return config.EventHistoryPersist, nil
+}
+
+// rpcSetEventHistoryPersist enables keeping the events across reboots, the events
+// already in memory are written out right away.
+func rpcSetEventHistoryPersist(enabled bool) error {
+	eventRecorder.lock.Lock()
+	if enabled && !config.EventHistoryPersist {
+		if err := eventRecorder.rewriteLocked(); err != nil {
+			eventRecorder.lock.Unlock()
+			return fmt.Errorf("failed to write event history: %w", err)
+		}
+	}
+	if !enabled {
+		if err := os.Remove(eventHistoryPath); err != nil && !os.IsNotExist(err) {
+			logger.Warn().Err(err).Msg("failed to remove event history")
+		}
+		eventRecorder.lines = 0
+	}
+	config.EventHistoryPersist = enabled
+	eventRecorder.lock.Unlock()
+
+	if err := SaveConfig(); err != nil {
+		return fmt.Errorf("failed to save config: %w", err)
+	}
+
+	logger.Info().Bool("enabled", enabled).Msg("event history persistence updated")
+	return nil
+}
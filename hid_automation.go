@@ -0,0 +1,35 @@
+package kvm
+
+import (
+	"fmt"
+	"sync"
+)
+
+// The macros, the pasted text and the boot key assistant all type on the same
+// keyboard, and each one releases every key when it stops. Only one of them runs at a
+// time so that they never interleave their reports or release the keys of another.
+var (
+	hidAutomationLock  sync.Mutex
+	hidAutomationOwner string
+)
+
+// claimHIDAutomation reserves the keyboard for the automation named owner, it fails
+// while another automation is typing.
+func claimHIDAutomation(owner string) error {
+	hidAutomationLock.Lock()
+	defer hidAutomationLock.Unlock()
+
+	if hidAutomationOwner != "" {
+		return fmt.Errorf("the keyboard is busy: %s is running", hidAutomationOwner)
+	}
+	hidAutomationOwner = owner
+	return nil
+}
+
+// releaseHIDAutomation frees the keyboard, once the keys have been released.
+func releaseHIDAutomation() {
+	hidAutomationLock.Lock()
+	defer hidAutomationLock.Unlock()
+
+	hidAutomationOwner = ""
+}
@@ -0,0 +1,74 @@
+package kvm
+
+import (
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"syscall"
+
+	"github.com/jetkvm/kvm/internal/httpimage"
+)
+
+// The HTTP mounts are served by internal/httpimage, the cache is configured here.
+
+var httpImageClient = &http.Client{
+	Timeout: httpimage.ProbeTimeout,
+}
+
+const (
+	httpImageCacheMemory = "memory"
+	httpImageCacheDisk   = "disk"
+
+	defaultHTTPImageMemoryCacheSizeMB = 32
+	defaultHTTPImageDiskCacheSizeMB   = 512
+
+	httpImageDiskCachePath = "/userdata/jetkvm/http_image.cache"
+)
+
+// probeHTTPImage checks that the image at rawURL can be served with range requests
+// and returns its size.
+func probeHTTPImage(rawURL string) (int64, error) {
+	return httpimage.Probe(httpImageClient, rawURL)
+}
+
+func newHTTPImageCacheStore(mode string, sizeMB int) (httpimage.Store, int, error) {
+	switch mode {
+	case httpImageCacheDisk:
+		if sizeMB <= 0 {
+			sizeMB = defaultHTTPImageDiskCacheSizeMB
+		}
+
+		var stat syscall.Statfs_t
+		if err := syscall.Statfs(filepath.Dir(httpImageDiskCachePath), &stat); err != nil {
+			return nil, 0, fmt.Errorf("failed to get storage stats: %w", err)
+		}
+		if freeSpace := stat.Bavail * uint64(stat.Bsize); freeSpace < uint64(sizeMB)*1024*1024*2 {
+			return nil, 0, fmt.Errorf("not enough free space on /userdata for a %d MB cache", sizeMB)
+		}
+
+		store, err := httpimage.NewDiskStore(httpImageDiskCachePath, httpimage.BlockSize)
+		if err != nil {
+			return nil, 0, err
+		}
+		return store, sizeMB * 1024 * 1024 / httpimage.BlockSize, nil
+	case httpImageCacheMemory, "":
+		if sizeMB <= 0 {
+			sizeMB = defaultHTTPImageMemoryCacheSizeMB
+		}
+
+		numSlots := sizeMB * 1024 * 1024 / httpimage.BlockSize
+		return httpimage.NewMemoryStore(numSlots), numSlots, nil
+	default:
+		return nil, 0, fmt.Errorf("unknown cache mode: %s", mode)
+	}
+}
+
+func newHTTPImageBackend(rawURL string, size int64, cacheMode string, cacheSizeMB int) *httpimage.Backend {
+	store, numSlots, err := newHTTPImageCacheStore(cacheMode, cacheSizeMB)
+	if err != nil {
+		logger.Warn().Err(err).Str("cacheMode", cacheMode).Msg("failed to create http image cache, falling back to memory")
+		store, numSlots, _ = newHTTPImageCacheStore(httpImageCacheMemory, 0)
+	}
+
+	return httpimage.New(rawURL, size, store, numSlots, logger)
+}
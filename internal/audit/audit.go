@@ -0,0 +1,322 @@
+// Package audit writes an append-only log of JSON lines. Every entry is chained to
+// the previous one with an HMAC keyed with a secret of the device, so that an entry
+// can't be changed, added or removed without the key, even by someone who can
+// recompute the hashes.
+package audit
+
+import (
+	"bufio"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// keySize is the size of the generated HMAC keys
+	keySize = 32
+	// maxLineSize is the longest entry read back
+	maxLineSize = 1024 * 1024
+)
+
+// Entry is a line of the audit log. Hash is the HMAC-SHA256 of PrevHash and the entry
+// without its hash.
+type Entry struct {
+	Seq       int64          `json:"seq"`
+	Time      time.Time      `json:"time"`
+	Origin    string         `json:"origin"` // "local", "cloud", "api" or "mqtt"
+	ClientIP  string         `json:"clientIp,omitempty"`
+	SessionID string         `json:"sessionId,omitempty"`
+	Username  string         `json:"username,omitempty"`
+	Role      string         `json:"role,omitempty"`
+	Action    string         `json:"action"`
+	Params    map[string]any `json:"params,omitempty"`
+	Result    string         `json:"result"` // "ok", "error" or "denied"
+	Error     string         `json:"error,omitempty"`
+	PrevHash  string         `json:"prevHash"`
+	Hash      string         `json:"hash"`
+}
+
+type VerifyResult struct {
+	Valid   bool   `json:"valid"`
+	Entries int    `json:"entries"`
+	Error   string `json:"error,omitempty"`
+	// BrokenAtSeq is the first entry that doesn't match the chain
+	BrokenAtSeq int64 `json:"brokenAtSeq,omitempty"`
+	// HeadSeq and HeadHash are the last entry, recording them elsewhere makes the
+	// removal of the latest entries detectable
+	HeadSeq  int64  `json:"headSeq,omitempty"`
+	HeadHash string `json:"headHash,omitempty"`
+}
+
+type Options struct {
+	Path string
+	// OldPath is where Path is moved once it reaches MaxSize, a single rotated file is kept
+	OldPath string
+	MaxSize int64
+}
+
+type Log struct {
+	lock sync.Mutex
+	opts Options
+	key  []byte
+
+	file     *os.File
+	size     int64
+	lastSeq  int64
+	lastHash string
+}
+
+func New(opts Options) *Log {
+	return &Log{opts: opts}
+}
+
+// LoadOrCreateKey reads the HMAC key from the file, or creates the file with a new
+// random key when it doesn't exist.
+func LoadOrCreateKey(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err == nil {
+		key, err := hex.DecodeString(strings.TrimSpace(string(data)))
+		if err != nil || len(key) < keySize {
+			return nil, fmt.Errorf("invalid audit key in %s", path)
+		}
+		return key, nil
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	key := make([]byte, keySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, fmt.Errorf("failed to generate audit key: %w", err)
+	}
+	// O_EXCL so that a key written in the meantime is never replaced
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	if _, err := file.WriteString(hex.EncodeToString(key) + "\n"); err != nil {
+		return nil, err
+	}
+	return key, file.Sync()
+}
+
+// Open sets the key and recovers the end of the chain from the last entry.
+func (l *Log) Open(key []byte) error {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	l.key = key
+
+	entries, err := readFile(l.opts.Path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to read audit log: %w", err)
+	}
+	if len(entries) == 0 {
+		// the current file can be empty right after a rotation
+		entries, _ = readFile(l.opts.OldPath)
+	}
+	if len(entries) > 0 {
+		last := entries[len(entries)-1]
+		l.lastSeq = last.Seq
+		l.lastHash = last.Hash
+	}
+
+	return l.openLocked()
+}
+
+func (l *Log) openLocked() error {
+	file, err := os.OpenFile(l.opts.Path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	l.file = file
+	l.size = info.Size()
+	return nil
+}
+
+func (l *Log) rotateLocked() error {
+	if l.file != nil {
+		l.file.Close()
+		l.file = nil
+	}
+
+	if err := os.Rename(l.opts.Path, l.opts.OldPath); err != nil {
+		return fmt.Errorf("failed to rotate audit log: %w", err)
+	}
+	return l.openLocked()
+}
+
+func (l *Log) computeHash(e *Entry) string {
+	unhashed := *e
+	unhashed.Hash = ""
+	data, _ := json.Marshal(unhashed)
+
+	mac := hmac.New(sha256.New, l.key)
+	mac.Write([]byte(e.PrevHash))
+	mac.Write(data)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Append chains the entry to the previous one and writes it, it returns the entry
+// written.
+func (l *Log) Append(entry Entry) (Entry, error) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if l.file == nil {
+		if err := l.openLocked(); err != nil {
+			return entry, fmt.Errorf("failed to open audit log: %w", err)
+		}
+	}
+
+	// a string that isn't valid UTF-8 would change when read back and break the hash
+	entry.Error = strings.ToValidUTF8(entry.Error, "")
+	entry.Seq = l.lastSeq + 1
+	entry.PrevHash = l.lastHash
+	entry.Hash = l.computeHash(&entry)
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return entry, fmt.Errorf("failed to encode audit entry: %w", err)
+	}
+	line = append(line, '\n')
+
+	if l.opts.MaxSize > 0 && l.size+int64(len(line)) > l.opts.MaxSize {
+		if err := l.rotateLocked(); err != nil {
+			return entry, err
+		}
+	}
+
+	n, err := l.file.Write(line)
+	l.size += int64(n)
+	if err != nil {
+		return entry, fmt.Errorf("failed to write audit entry: %w", err)
+	}
+
+	l.lastSeq = entry.Seq
+	l.lastHash = entry.Hash
+	return entry, nil
+}
+
+// Head returns the sequence number and the hash of the last entry.
+func (l *Log) Head() (int64, string) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	return l.lastSeq, l.lastHash
+}
+
+func readFile(path string) ([]Entry, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	entries := make([]Entry, 0)
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), maxLineSize)
+	for scanner.Scan() {
+		var entry Entry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return entries, fmt.Errorf("invalid audit entry after seq %d: %w", lastSeq(entries), err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, scanner.Err()
+}
+
+func lastSeq(entries []Entry) int64 {
+	if len(entries) == 0 {
+		return 0
+	}
+	return entries[len(entries)-1].Seq
+}
+
+// Entries returns the rotated and the current entries, oldest first.
+func (l *Log) Entries() ([]Entry, error) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	old, err := readFile(l.opts.OldPath)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	current, err := readFile(l.opts.Path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	return append(old, current...), nil
+}
+
+// Verify checks the chain of the entries still on disk.
+func (l *Log) Verify() VerifyResult {
+	entries, err := l.Entries()
+	if err != nil {
+		return VerifyResult{Error: err.Error()}
+	}
+
+	for i := range entries {
+		entry := &entries[i]
+		// the first entry links to an entry that was rotated away, it can only be checked on its own
+		if i > 0 && (entry.PrevHash != entries[i-1].Hash || entry.Seq != entries[i-1].Seq+1) {
+			return VerifyResult{Entries: len(entries), BrokenAtSeq: entry.Seq, Error: "entry doesn't follow the previous one"}
+		}
+		if !hmac.Equal([]byte(l.computeHash(entry)), []byte(entry.Hash)) {
+			return VerifyResult{Entries: len(entries), BrokenAtSeq: entry.Seq, Error: "entry hash mismatch"}
+		}
+	}
+
+	result := VerifyResult{Valid: true, Entries: len(entries)}
+	if len(entries) > 0 {
+		result.HeadSeq = entries[len(entries)-1].Seq
+		result.HeadHash = entries[len(entries)-1].Hash
+	}
+
+	// the entries after the head known to the log were removed from the file
+	if headSeq, _ := l.Head(); headSeq > result.HeadSeq {
+		result.Valid = false
+		result.BrokenAtSeq = result.HeadSeq + 1
+		result.Error = fmt.Sprintf("the entries after seq %d are missing", result.HeadSeq)
+	}
+	return result
+}
+
+// Copy writes the rotated and the current log as JSONL.
+func (l *Log) Copy(w io.Writer) error {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	for _, path := range []string{l.opts.OldPath, l.opts.Path} {
+		file, err := os.Open(path)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(w, file)
+		file.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,223 @@
+package audit
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testKey = []byte("0123456789abcdef0123456789abcdef")
+
+func newTestLog(t *testing.T, maxSize int64) *Log {
+	dir := t.TempDir()
+	log := New(Options{
+		Path:    filepath.Join(dir, "audit.jsonl"),
+		OldPath: filepath.Join(dir, "audit.1.jsonl"),
+		MaxSize: maxSize,
+	})
+	require.NoError(t, log.Open(testKey))
+	return log
+}
+
+func appendEntries(t *testing.T, log *Log, n int) {
+	for i := range n {
+		_, err := log.Append(Entry{
+			Time:   time.Now().UTC(),
+			Origin: "local",
+			Action: "setJigglerState",
+			Params: map[string]any{"enabled": i%2 == 0},
+			Result: "ok",
+		})
+		require.NoError(t, err)
+	}
+}
+
+// rewriteFile applies edit to the lines of the current log file.
+func rewriteFile(t *testing.T, path string, edit func(lines []string) []string) {
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	lines = edit(lines)
+	require.NoError(t, os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0600))
+}
+
+func TestAppendRoundTrip(t *testing.T) {
+	log := newTestLog(t, 0)
+
+	written, err := log.Append(Entry{Origin: "api", Action: "login", Result: "error", Error: "bad \xff utf-8"})
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), written.Seq)
+	assert.Empty(t, written.PrevHash)
+	assert.Equal(t, "bad  utf-8", written.Error)
+
+	appendEntries(t, log, 4)
+
+	entries, err := log.Entries()
+	require.NoError(t, err)
+	require.Len(t, entries, 5)
+	assert.Equal(t, written.Hash, entries[0].Hash)
+	assert.Equal(t, entries[0].Hash, entries[1].PrevHash)
+	assert.Equal(t, map[string]any{"enabled": true}, entries[1].Params)
+
+	result := log.Verify()
+	assert.True(t, result.Valid, result.Error)
+	assert.Equal(t, 5, result.Entries)
+	assert.Equal(t, int64(5), result.HeadSeq)
+	assert.Equal(t, entries[4].Hash, result.HeadHash)
+}
+
+func TestReopenContinuesChain(t *testing.T) {
+	log := newTestLog(t, 0)
+	appendEntries(t, log, 3)
+
+	reopened := New(log.opts)
+	require.NoError(t, reopened.Open(testKey))
+	seq, hash := reopened.Head()
+	assert.Equal(t, int64(3), seq)
+
+	written, err := reopened.Append(Entry{Action: "reboot", Result: "ok"})
+	require.NoError(t, err)
+	assert.Equal(t, int64(4), written.Seq)
+	assert.Equal(t, hash, written.PrevHash)
+	assert.True(t, reopened.Verify().Valid)
+}
+
+func TestVerifyDetectsTampering(t *testing.T) {
+	tests := []struct {
+		name        string
+		edit        func(lines []string) []string
+		brokenAtSeq int64
+	}{
+		{
+			name: "changed entry",
+			edit: func(lines []string) []string {
+				lines[2] = strings.Replace(lines[2], `"result":"ok"`, `"result":"denied"`, 1)
+				return lines
+			},
+			brokenAtSeq: 3,
+		},
+		{
+			name: "removed entry",
+			edit: func(lines []string) []string {
+				return append(lines[:1], lines[2:]...)
+			},
+			brokenAtSeq: 3,
+		},
+		{
+			name: "swapped entries",
+			edit: func(lines []string) []string {
+				lines[1], lines[2] = lines[2], lines[1]
+				return lines
+			},
+			brokenAtSeq: 3,
+		},
+		{
+			name: "removed latest entries",
+			edit: func(lines []string) []string {
+				return lines[:3]
+			},
+			brokenAtSeq: 4,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			log := newTestLog(t, 0)
+			appendEntries(t, log, 5)
+			require.True(t, log.Verify().Valid)
+
+			rewriteFile(t, log.opts.Path, tt.edit)
+
+			result := log.Verify()
+			assert.False(t, result.Valid)
+			assert.NotEmpty(t, result.Error)
+			assert.Equal(t, tt.brokenAtSeq, result.BrokenAtSeq)
+		})
+	}
+}
+
+func TestVerifyDetectsRecomputedHashes(t *testing.T) {
+	log := newTestLog(t, 0)
+	appendEntries(t, log, 3)
+
+	// someone without the key can't make a chain that verifies
+	forged := New(Options{
+		Path:    filepath.Join(t.TempDir(), "forged.jsonl"),
+		OldPath: filepath.Join(t.TempDir(), "forged.1.jsonl"),
+	})
+	require.NoError(t, forged.Open([]byte("not the key of the device, at all")))
+	appendEntries(t, forged, 3)
+
+	data, err := os.ReadFile(forged.opts.Path)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(log.opts.Path, data, 0600))
+
+	result := log.Verify()
+	assert.False(t, result.Valid)
+	assert.Equal(t, int64(1), result.BrokenAtSeq)
+}
+
+func TestRotation(t *testing.T) {
+	log := newTestLog(t, 1024)
+	appendEntries(t, log, 20)
+
+	_, err := os.Stat(log.opts.OldPath)
+	require.NoError(t, err, "the log should have been rotated")
+
+	info, err := os.Stat(log.opts.Path)
+	require.NoError(t, err)
+	assert.LessOrEqual(t, info.Size(), int64(1024))
+
+	// only the entries of the last rotation are kept
+	entries, err := log.Entries()
+	require.NoError(t, err)
+	require.NotEmpty(t, entries)
+	assert.Less(t, len(entries), 20)
+	assert.Equal(t, int64(20), entries[len(entries)-1].Seq)
+
+	// the first entry kept links to a rotated away one, the chain still verifies
+	assert.NotEmpty(t, entries[0].PrevHash)
+	result := log.Verify()
+	assert.True(t, result.Valid, result.Error)
+	assert.Equal(t, len(entries), result.Entries)
+	assert.Equal(t, int64(20), result.HeadSeq)
+}
+
+func TestReopenAfterRotation(t *testing.T) {
+	log := newTestLog(t, 1024)
+	appendEntries(t, log, 20)
+
+	// right after a rotation the current file is empty
+	require.NoError(t, os.Rename(log.opts.Path, log.opts.OldPath))
+
+	reopened := New(log.opts)
+	require.NoError(t, reopened.Open(testKey))
+	seq, _ := reopened.Head()
+	assert.Equal(t, int64(20), seq)
+}
+
+func TestLoadOrCreateKey(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.key")
+
+	key, err := LoadOrCreateKey(path)
+	require.NoError(t, err)
+	assert.Len(t, key, keySize)
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	loaded, err := LoadOrCreateKey(path)
+	require.NoError(t, err)
+	assert.Equal(t, key, loaded)
+
+	require.NoError(t, os.WriteFile(path, []byte("not hex"), 0600))
+	_, err = LoadOrCreateKey(path)
+	assert.Error(t, err)
+}
@@ -3,6 +3,7 @@ package confparser
 import (
 	"fmt"
 	"net"
+	"net/url"
 	"reflect"
 	"slices"
 	"strconv"
@@ -345,11 +346,25 @@ func (f *FieldConfig) validateField() error {
 		return nil
 	}
 
+	// validate each element of a slice
+	if vals, ok := f.CurrentValue.([]string); ok {
+		for _, val := range vals {
+			if err := f.validateValue(val); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
 	val, err := toString(f.CurrentValue)
 	if err != nil {
 		return fmt.Errorf("field `%s` cannot use validate_type: %s", f.Name, err)
 	}
 
+	return f.validateValue(val)
+}
+
+func (f *FieldConfig) validateValue(val string) error {
 	if val == "" {
 		return nil
 	}
@@ -372,6 +387,18 @@ func (f *FieldConfig) validateField() error {
 			if _, err := idna.Lookup.ToASCII(val); err != nil {
 				return fmt.Errorf("field `%s` is not a valid hostname: %s", f.Name, val)
 			}
+		case "ip_or_hostname":
+			if net.ParseIP(val) != nil {
+				continue
+			}
+			if _, err := idna.Lookup.ToASCII(val); err != nil {
+				return fmt.Errorf("field `%s` is not a valid IP address or hostname: %s", f.Name, val)
+			}
+		case "url":
+			u, err := url.Parse(val)
+			if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				return fmt.Errorf("field `%s` is not a valid HTTP URL: %s", f.Name, val)
+			}
 		default:
 			return fmt.Errorf("field `%s` cannot use validate_type: unsupported validator: %s", f.Name, validateType)
 		}
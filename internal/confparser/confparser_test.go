@@ -46,6 +46,8 @@ type testNetworkConfig struct {
 	TimeSyncOrdering        []string    `json:"time_sync_ordering,omitempty" one_of:"http,ntp,ntp_dhcp,ntp_user_provided,ntp_fallback" default:"ntp,http"`
 	TimeSyncDisableFallback null.Bool   `json:"time_sync_disable_fallback,omitempty" default:"false"`
 	TimeSyncParallel        null.Int    `json:"time_sync_parallel,omitempty" default:"4"`
+	TimeSyncNTPServers      []string    `json:"time_sync_ntp_servers,omitempty" validate_type:"ip_or_hostname"`
+	TimeSyncHTTPUrls        []string    `json:"time_sync_http_urls,omitempty" validate_type:"url"`
 }
 
 func TestValidateConfig(t *testing.T) {
@@ -113,3 +115,55 @@ func TestValidateIPv4StaticConfigValidateType(t *testing.T) {
 		t.Fatalf("expected error, got nil")
 	}
 }
+
+func TestValidateIPOrHostname(t *testing.T) {
+	valid := [][]string{
+		{"192.168.1.1"},
+		{"2001:db8::1"},
+		{"pool.ntp.org", "time.example.com", "ntp"},
+	}
+	for _, servers := range valid {
+		config := &testNetworkConfig{TimeSyncNTPServers: servers}
+		if err := SetDefaultsAndValidate(config); err != nil {
+			t.Errorf("%v: expected no error, got %v", servers, err)
+		}
+	}
+
+	invalid := [][]string{
+		{"not a hostname"},
+		{"pool.ntp.org", "-bad.example.com"},
+		{"ntp_server!"},
+	}
+	for _, servers := range invalid {
+		config := &testNetworkConfig{TimeSyncNTPServers: servers}
+		if err := SetDefaultsAndValidate(config); err == nil {
+			t.Errorf("%v: expected error, got nil", servers)
+		}
+	}
+}
+
+func TestValidateURL(t *testing.T) {
+	valid := [][]string{
+		{"http://example.com"},
+		{"https://example.com/generate_204", "http://192.168.1.1:8080/time"},
+	}
+	for _, urls := range valid {
+		config := &testNetworkConfig{TimeSyncHTTPUrls: urls}
+		if err := SetDefaultsAndValidate(config); err != nil {
+			t.Errorf("%v: expected no error, got %v", urls, err)
+		}
+	}
+
+	invalid := [][]string{
+		{"example.com"},
+		{"ftp://example.com"},
+		{"http://"},
+		{"https://example.com", "://example.com"},
+	}
+	for _, urls := range invalid {
+		config := &testNetworkConfig{TimeSyncHTTPUrls: urls}
+		if err := SetDefaultsAndValidate(config); err == nil {
+			t.Errorf("%v: expected error, got nil", urls)
+		}
+	}
+}
@@ -0,0 +1,267 @@
+package httpimage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+const (
+	// BlockSize is the size of the blocks fetched and cached
+	BlockSize       = 256 * 1024
+	readAheadBlocks = 8
+	// FetchTimeout is the timeout of a range request
+	FetchTimeout = 30 * time.Second
+	fetchRetries = 3
+)
+
+// Backend reads an image straight from an HTTP server with range requests,
+// so that an HTTP mount keeps working without a browser session. Blocks are kept in
+// a LRU cache and sequential reads trigger a read-ahead of the following blocks.
+type Backend struct {
+	url    string
+	size   int64
+	client *http.Client
+	cache  *cache
+	logger *zerolog.Logger
+
+	inflight     map[int64]chan struct{}
+	inflightLock sync.Mutex
+
+	lastReadEnd   int64
+	readAheadLock sync.Mutex
+	readAheadBusy atomic.Bool
+	closed        chan struct{}
+	closeOnce     sync.Once
+}
+
+// New returns the backend of the image of the size at rawURL, with a cache of numSlots
+// blocks in the store.
+func New(rawURL string, size int64, store Store, numSlots int, logger *zerolog.Logger) *Backend {
+	return &Backend{
+		url:      rawURL,
+		size:     size,
+		client:   &http.Client{},
+		cache:    newCache(store, numSlots, logger),
+		logger:   logger,
+		inflight: make(map[int64]chan struct{}),
+		closed:   make(chan struct{}),
+	}
+}
+
+func (b *Backend) Size() (int64, error) {
+	return b.size, nil
+}
+
+func (b *Backend) ReadAt(p []byte, off int64) (n int, err error) {
+	if off >= b.size {
+		return 0, io.EOF
+	}
+
+	end := off + int64(len(p))
+	if end > b.size {
+		end = b.size
+	}
+
+	for pos := off; pos < end; {
+		block := pos / BlockSize
+		data, err := b.getBlock(block)
+		if err != nil {
+			return n, err
+		}
+
+		blockOffset := pos - block*BlockSize
+		copied := copy(p[n:end-off], data[blockOffset:])
+		n += copied
+		pos += int64(copied)
+	}
+
+	b.maybeReadAhead(off, end)
+
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+func (b *Backend) blockCount() int64 {
+	return (b.size + BlockSize - 1) / BlockSize
+}
+
+var errBlockBusy = errors.New("block is already being fetched")
+
+// getBlock returns the block from the cache, waits for a running fetch of it,
+// or fetches it.
+func (b *Backend) getBlock(block int64) ([]byte, error) {
+	for {
+		if data, ok := b.cache.get(block); ok {
+			return data, nil
+		}
+
+		b.inflightLock.Lock()
+		wait, ok := b.inflight[block]
+		b.inflightLock.Unlock()
+		if ok {
+			select {
+			case <-wait:
+				continue
+			case <-b.closed:
+				return nil, errors.New("image unmounted")
+			}
+		}
+
+		blocks, err := b.fetchBlocks(block, 1)
+		if errors.Is(err, errBlockBusy) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return blocks[0], nil
+	}
+}
+
+// fetchBlocks fetches up to count blocks starting at first with a single range request,
+// stopping before a block that is already being fetched.
+func (b *Backend) fetchBlocks(first int64, count int64) ([][]byte, error) {
+	if last := b.blockCount(); first+count > last {
+		count = last - first
+	}
+
+	b.inflightLock.Lock()
+	done := make(chan struct{})
+	registered := int64(0)
+	for ; registered < count; registered++ {
+		if _, ok := b.inflight[first+registered]; ok {
+			break
+		}
+		b.inflight[first+registered] = done
+	}
+	b.inflightLock.Unlock()
+
+	defer func() {
+		b.inflightLock.Lock()
+		for i := int64(0); i < registered; i++ {
+			delete(b.inflight, first+i)
+		}
+		b.inflightLock.Unlock()
+		close(done)
+	}()
+
+	if registered == 0 {
+		return nil, errBlockBusy
+	}
+
+	start := first * BlockSize
+	end := (first + registered) * BlockSize
+	if end > b.size {
+		end = b.size
+	}
+
+	var (
+		data []byte
+		err  error
+	)
+	for attempt := 1; attempt <= fetchRetries; attempt++ {
+		data, err = b.fetchRange(start, end)
+		if err == nil {
+			break
+		}
+
+		b.logger.Warn().Err(err).Int("attempt", attempt).Int64("start", start).Int64("end", end).Msg("failed to fetch http image range")
+
+		select {
+		case <-time.After(time.Duration(attempt) * time.Second):
+		case <-b.closed:
+			return nil, errors.New("image unmounted")
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	blocks := make([][]byte, 0, registered)
+	for i := int64(0); i < registered; i++ {
+		blockStart := i * BlockSize
+		blockEnd := min(blockStart+BlockSize, int64(len(data)))
+		blockData := data[blockStart:blockEnd]
+
+		b.cache.put(first+i, blockData)
+		blocks = append(blocks, blockData)
+	}
+
+	return blocks, nil
+}
+
+func (b *Backend) fetchRange(start, end int64) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), FetchTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, b.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", start, end-1))
+
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusPartialContent {
+		return nil, fmt.Errorf("unexpected status for range request: %s", resp.Status)
+	}
+
+	data := make([]byte, end-start)
+	if _, err := io.ReadFull(resp.Body, data); err != nil {
+		return nil, fmt.Errorf("failed to read range: %w", err)
+	}
+
+	return data, nil
+}
+
+// maybeReadAhead prefetches the blocks following a sequential read in the background.
+func (b *Backend) maybeReadAhead(off, end int64) {
+	b.readAheadLock.Lock()
+	sequential := off == b.lastReadEnd
+	b.lastReadEnd = end
+	b.readAheadLock.Unlock()
+
+	if !sequential || !b.readAheadBusy.CompareAndSwap(false, true) {
+		return
+	}
+
+	// don't read ahead more than half of the cache, or it would evict itself
+	readAhead := min(int64(readAheadBlocks), int64(b.cache.capacity/2))
+	next := (end + BlockSize - 1) / BlockSize
+	go func() {
+		defer b.readAheadBusy.Store(false)
+
+		for block := next; block < next+readAhead && block < b.blockCount(); block++ {
+			if b.cache.contains(block) {
+				continue
+			}
+
+			count := next + readAhead - block
+			if _, err := b.fetchBlocks(block, count); err != nil && !errors.Is(err, errBlockBusy) {
+				b.logger.Debug().Err(err).Int64("block", block).Msg("http image read-ahead stopped")
+			}
+			return
+		}
+	}()
+}
+
+func (b *Backend) Close() error {
+	b.closeOnce.Do(func() {
+		close(b.closed)
+	})
+	return b.cache.close()
+}
@@ -0,0 +1,201 @@
+package httpimage
+
+import (
+	"container/list"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/rs/zerolog"
+)
+
+// Store holds the cached blocks, one fixed size slot per block.
+type Store interface {
+	readSlot(slot int, p []byte) error
+	writeSlot(slot int, p []byte) error
+	close() error
+}
+
+type memoryStore struct {
+	slots [][]byte
+}
+
+// NewMemoryStore returns a store of numSlots blocks in memory.
+func NewMemoryStore(numSlots int) Store {
+	return &memoryStore{slots: make([][]byte, numSlots)}
+}
+
+func (s *memoryStore) readSlot(slot int, p []byte) error {
+	copy(p, s.slots[slot])
+	return nil
+}
+
+func (s *memoryStore) writeSlot(slot int, p []byte) error {
+	if cap(s.slots[slot]) < len(p) {
+		s.slots[slot] = make([]byte, len(p))
+	}
+	s.slots[slot] = s.slots[slot][:len(p)]
+	copy(s.slots[slot], p)
+	return nil
+}
+
+func (s *memoryStore) close() error {
+	s.slots = nil
+	return nil
+}
+
+// diskStore keeps the blocks in a single sparse file on /userdata,
+// the file is removed when the cache is closed.
+type diskStore struct {
+	file      *os.File
+	blockSize int64
+}
+
+// NewDiskStore returns a store of blocks of blockSize in a file created at path.
+func NewDiskStore(path string, blockSize int64) (Store, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cache file: %w", err)
+	}
+
+	return &diskStore{file: file, blockSize: blockSize}, nil
+}
+
+func (s *diskStore) readSlot(slot int, p []byte) error {
+	_, err := s.file.ReadAt(p, int64(slot)*s.blockSize)
+	return err
+}
+
+func (s *diskStore) writeSlot(slot int, p []byte) error {
+	_, err := s.file.WriteAt(p, int64(slot)*s.blockSize)
+	return err
+}
+
+func (s *diskStore) close() error {
+	path := s.file.Name()
+	if err := s.file.Close(); err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
+
+type cacheEntry struct {
+	block int64
+	slot  int
+	size  int
+}
+
+// cache is a LRU cache of image blocks.
+type cache struct {
+	lock      sync.Mutex
+	entries   map[int64]*list.Element
+	lru       *list.List // most recently used first
+	freeSlots []int
+	capacity  int
+	store     Store
+	logger    *zerolog.Logger
+}
+
+func newCache(store Store, numSlots int, logger *zerolog.Logger) *cache {
+	freeSlots := make([]int, numSlots)
+	for i := range freeSlots {
+		freeSlots[i] = numSlots - 1 - i
+	}
+
+	return &cache{
+		entries:   make(map[int64]*list.Element),
+		lru:       list.New(),
+		freeSlots: freeSlots,
+		capacity:  numSlots,
+		store:     store,
+		logger:    logger,
+	}
+}
+
+func (c *cache) contains(block int64) bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	_, ok := c.entries[block]
+	return ok
+}
+
+// get returns a copy of the cached block.
+func (c *cache) get(block int64) ([]byte, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	elem, ok := c.entries[block]
+	if !ok {
+		return nil, false
+	}
+	entry := elem.Value.(*cacheEntry)
+
+	data := make([]byte, entry.size)
+	if err := c.store.readSlot(entry.slot, data); err != nil {
+		c.logger.Warn().Err(err).Int64("block", block).Msg("failed to read cached block, dropping it")
+		c.removeLocked(elem)
+		return nil, false
+	}
+
+	c.lru.MoveToFront(elem)
+	return data, true
+}
+
+func (c *cache) put(block int64, data []byte) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.store == nil {
+		return
+	}
+
+	if elem, ok := c.entries[block]; ok {
+		c.lru.MoveToFront(elem)
+		return
+	}
+
+	if len(c.freeSlots) == 0 {
+		oldest := c.lru.Back()
+		if oldest == nil {
+			return
+		}
+		c.removeLocked(oldest)
+	}
+
+	slot := c.freeSlots[len(c.freeSlots)-1]
+	c.freeSlots = c.freeSlots[:len(c.freeSlots)-1]
+
+	if err := c.store.writeSlot(slot, data); err != nil {
+		c.logger.Warn().Err(err).Int64("block", block).Msg("failed to cache block")
+		c.freeSlots = append(c.freeSlots, slot)
+		return
+	}
+
+	c.entries[block] = c.lru.PushFront(&cacheEntry{
+		block: block,
+		slot:  slot,
+		size:  len(data),
+	})
+}
+
+func (c *cache) removeLocked(elem *list.Element) {
+	entry := c.lru.Remove(elem).(*cacheEntry)
+	delete(c.entries, entry.block)
+	c.freeSlots = append(c.freeSlots, entry.slot)
+}
+
+func (c *cache) close() error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.store == nil {
+		return nil
+	}
+
+	err := c.store.close()
+	c.store = nil
+	c.entries = make(map[int64]*list.Element)
+	c.lru.Init()
+	return err
+}
@@ -0,0 +1,140 @@
+package httpimage
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testLogger = zerolog.Nop()
+
+func TestCacheEviction(t *testing.T) {
+	stores := map[string]func(t *testing.T) Store{
+		"memory": func(t *testing.T) Store { return NewMemoryStore(2) },
+		"disk": func(t *testing.T) Store {
+			store, err := NewDiskStore(filepath.Join(t.TempDir(), "cache"), 16)
+			require.NoError(t, err)
+			return store
+		},
+	}
+
+	for name, newStore := range stores {
+		t.Run(name, func(t *testing.T) {
+			c := newCache(newStore(t), 2, &testLogger)
+
+			c.put(1, []byte("one"))
+			c.put(2, []byte("two"))
+
+			// reading 1 makes 2 the least recently used block
+			data, ok := c.get(1)
+			require.True(t, ok)
+			assert.Equal(t, []byte("one"), data)
+
+			c.put(3, []byte("three"))
+			assert.True(t, c.contains(1))
+			assert.False(t, c.contains(2))
+			assert.True(t, c.contains(3))
+
+			data, ok = c.get(3)
+			require.True(t, ok)
+			assert.Equal(t, []byte("three"), data)
+
+			// the data returned is a copy
+			data[0] = 'x'
+			data, _ = c.get(3)
+			assert.Equal(t, []byte("three"), data)
+
+			require.NoError(t, c.close())
+			_, ok = c.get(1)
+			assert.False(t, ok)
+			c.put(4, []byte("four"))
+			assert.False(t, c.contains(4))
+		})
+	}
+}
+
+func TestDiskStoreRemovedOnClose(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache")
+	store, err := NewDiskStore(path, 16)
+	require.NoError(t, err)
+
+	require.NoError(t, store.writeSlot(3, []byte("block")))
+	data := make([]byte, 5)
+	require.NoError(t, store.readSlot(3, data))
+	assert.Equal(t, []byte("block"), data)
+
+	require.NoError(t, store.close())
+	_, err = os.Stat(path)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestBackendReadAt(t *testing.T) {
+	// two and a half blocks, so that the last one is short
+	image := make([]byte, BlockSize*5/2)
+	for i := range image {
+		image[i] = byte(i / 7)
+	}
+
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		serveImage(image)(w, r)
+	}))
+	defer server.Close()
+
+	backend := New(server.URL, int64(len(image)), NewMemoryStore(8), 8, &testLogger)
+	defer backend.Close()
+
+	size, err := backend.Size()
+	require.NoError(t, err)
+	assert.Equal(t, int64(len(image)), size)
+
+	// a read across two blocks
+	p := make([]byte, 1000)
+	off := int64(BlockSize - 500)
+	n, err := backend.ReadAt(p, off)
+	require.NoError(t, err)
+	assert.Equal(t, len(p), n)
+	assert.Equal(t, image[off:off+int64(n)], p)
+
+	// the blocks are cached, the reads aren't sequential so nothing is read ahead
+	before := requests.Load()
+	_, err = backend.ReadAt(p, off)
+	require.NoError(t, err)
+	assert.Equal(t, before, requests.Load())
+
+	// a read past the end is short
+	p = make([]byte, 1000)
+	off = int64(len(image) - 100)
+	n, err = backend.ReadAt(p, off)
+	assert.ErrorIs(t, err, io.EOF)
+	assert.Equal(t, 100, n)
+	assert.Equal(t, image[off:], p[:n])
+
+	_, err = backend.ReadAt(p, int64(len(image)))
+	assert.ErrorIs(t, err, io.EOF)
+}
+
+func TestBackendFetchError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// a server that stopped honoring the range requests
+		_, _ = io.Copy(w, bytes.NewReader(make([]byte, 1024)))
+	}))
+	defer server.Close()
+
+	backend := New(server.URL, 1024, NewMemoryStore(2), 2, &testLogger)
+	// closing makes the retries give up right away
+	require.NoError(t, backend.Close())
+
+	_, err := backend.ReadAt(make([]byte, 16), 0)
+	assert.Error(t, err)
+}
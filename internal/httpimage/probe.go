@@ -0,0 +1,167 @@
+package httpimage
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ProbeTimeout is the timeout of the client used by Probe.
+const ProbeTimeout = 10 * time.Second
+
+// probeRange is the range requested to check that the server honors range requests.
+const probeRange = "bytes=0-511"
+
+// UnusableError is returned by Probe when the server answered, but the image can't
+// be mounted from it. The message is meant for the user.
+type UnusableError struct {
+	Reason string
+}
+
+func (e *UnusableError) Error() string {
+	return e.Reason
+}
+
+func unusable(format string, args ...interface{}) error {
+	return &UnusableError{Reason: fmt.Sprintf(format, args...)}
+}
+
+// describeError turns transport errors into something readable,
+// TLS failures are the most common ones with internal mirrors.
+func describeError(err error) string {
+	var (
+		unknownAuthorityErr x509.UnknownAuthorityError
+		certificateErr      x509.CertificateInvalidError
+		hostnameErr         x509.HostnameError
+		recordHeaderErr     tls.RecordHeaderError
+	)
+
+	switch {
+	case errors.As(err, &unknownAuthorityErr):
+		return "TLS error: the server certificate is signed by an unknown authority"
+	case errors.As(err, &certificateErr):
+		return fmt.Sprintf("TLS error: the server certificate is invalid: %v", certificateErr)
+	case errors.As(err, &hostnameErr):
+		return fmt.Sprintf("TLS error: %v", hostnameErr)
+	case errors.As(err, &recordHeaderErr):
+		return "TLS error: the server did not answer with TLS, try http:// instead"
+	}
+
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) && urlErr.Timeout() {
+		return "the server did not respond in time"
+	}
+
+	return fmt.Sprintf("failed to connect to the server: %v", err)
+}
+
+// checkResponse rejects responses that can't be an image, such as
+// error pages or HTML login pages the request got redirected to.
+func checkResponse(resp *http.Response, originalURL string) error {
+	if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+		return unusable("the server requires authentication (HTTP %d)", resp.StatusCode)
+	}
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusPartialContent {
+		return unusable("the server returned HTTP %d", resp.StatusCode)
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if mediaType == "text/html" {
+		if resp.Request != nil && resp.Request.URL.String() != originalURL {
+			return unusable("the URL redirects to an HTML page (%s), it may require a login", resp.Request.URL.Redacted())
+		}
+		return unusable("the URL points to an HTML page, not to an image file")
+	}
+
+	return nil
+}
+
+// parseContentRangeSize returns the complete length from a Content-Range header
+// such as "bytes 0-511/1048576".
+func parseContentRangeSize(contentRange string) (int64, error) {
+	unit, rest, ok := strings.Cut(contentRange, " ")
+	if !ok || unit != "bytes" {
+		return 0, fmt.Errorf("invalid content range: %s", contentRange)
+	}
+
+	_, total, ok := strings.Cut(rest, "/")
+	if !ok || total == "*" {
+		return 0, fmt.Errorf("content range without complete length: %s", contentRange)
+	}
+
+	return strconv.ParseInt(total, 10, 64)
+}
+
+// Probe checks that the image at rawURL can be served with range requests and
+// returns its size.
+func Probe(client *http.Client, rawURL string) (int64, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return 0, unusable("the URL must be an http:// or https:// URL")
+	}
+
+	var (
+		size          int64 = -1
+		acceptsRanges bool
+	)
+
+	headResp, err := client.Head(rawURL)
+	if err != nil {
+		return 0, unusable("%s", describeError(err))
+	}
+	_ = headResp.Body.Close()
+
+	// some servers don't implement HEAD, the ranged GET below is enough in that case
+	if headResp.StatusCode != http.StatusMethodNotAllowed && headResp.StatusCode != http.StatusNotImplemented {
+		if err := checkResponse(headResp, rawURL); err != nil {
+			return 0, err
+		}
+		size = headResp.ContentLength
+		acceptsRanges = strings.EqualFold(headResp.Header.Get("Accept-Ranges"), "bytes")
+	}
+
+	req, err := http.NewRequest(http.MethodGet, rawURL, nil)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Range", probeRange)
+
+	getResp, err := client.Do(req)
+	if err != nil {
+		return 0, unusable("%s", describeError(err))
+	}
+	defer getResp.Body.Close()
+
+	if err := checkResponse(getResp, rawURL); err != nil {
+		return 0, err
+	}
+
+	if getResp.StatusCode != http.StatusPartialContent {
+		if acceptsRanges {
+			return 0, unusable("the server advertises range requests but ignored them")
+		}
+		return 0, unusable("the server does not support range requests (Accept-Ranges: bytes)")
+	}
+
+	// drain the probed range so the connection can be reused
+	_, _ = io.Copy(io.Discard, io.LimitReader(getResp.Body, 512))
+
+	if rangeSize, err := parseContentRangeSize(getResp.Header.Get("Content-Range")); err == nil {
+		size = rangeSize
+	}
+
+	if size <= 0 {
+		return 0, unusable("the server did not report the image size (Content-Length)")
+	}
+
+	return size, nil
+}
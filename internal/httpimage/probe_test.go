@@ -0,0 +1,158 @@
+package httpimage
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func serveImage(image []byte) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "image.iso", time.Time{}, bytes.NewReader(image))
+	}
+}
+
+func TestProbe(t *testing.T) {
+	image := bytes.Repeat([]byte{0xab}, 4096)
+
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		// path is requested on the test server, url replaces it when set
+		path     string
+		url      string
+		wantSize int64
+		// wantReason is the reason of the UnusableError, any other error is unexpected
+		wantReason string
+	}{
+		{
+			name:     "range requests",
+			handler:  serveImage(image),
+			wantSize: 4096,
+		},
+		{
+			name: "no HEAD support",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				if r.Method == http.MethodHead {
+					w.WriteHeader(http.StatusMethodNotAllowed)
+					return
+				}
+				serveImage(image)(w, r)
+			},
+			wantSize: 4096,
+		},
+		{
+			name: "no range support",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Length", "4096")
+				w.WriteHeader(http.StatusOK)
+				if r.Method == http.MethodGet {
+					_, _ = w.Write(image)
+				}
+			},
+			wantReason: "the server does not support range requests (Accept-Ranges: bytes)",
+		},
+		{
+			name: "ranges advertised but ignored",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Accept-Ranges", "bytes")
+				w.Header().Set("Content-Length", "4096")
+				w.WriteHeader(http.StatusOK)
+				if r.Method == http.MethodGet {
+					_, _ = w.Write(image)
+				}
+			},
+			wantReason: "the server advertises range requests but ignored them",
+		},
+		{
+			name: "authentication required",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusUnauthorized)
+			},
+			wantReason: "the server requires authentication (HTTP 401)",
+		},
+		{
+			name: "not found",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusNotFound)
+			},
+			wantReason: "the server returned HTTP 404",
+		},
+		{
+			name: "HTML page",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "text/html; charset=utf-8")
+				_, _ = w.Write([]byte("<html></html>"))
+			},
+			wantReason: "the URL points to an HTML page, not to an image file",
+		},
+		{
+			name: "redirect to a login page",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/login" {
+					http.Redirect(w, r, "/login", http.StatusFound)
+					return
+				}
+				w.Header().Set("Content-Type", "text/html")
+				_, _ = w.Write([]byte("<form></form>"))
+			},
+			path:       "/image.iso",
+			wantReason: "the URL redirects to an HTML page",
+		},
+		{
+			name:       "not an HTTP URL",
+			url:        "ftp://example.com/image.iso",
+			wantReason: "the URL must be an http:// or https:// URL",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rawURL := tt.url
+			if rawURL == "" {
+				server := httptest.NewServer(tt.handler)
+				defer server.Close()
+				rawURL = server.URL + tt.path
+			}
+
+			size, err := Probe(&http.Client{Timeout: ProbeTimeout}, rawURL)
+			if tt.wantReason == "" {
+				require.NoError(t, err)
+				assert.Equal(t, tt.wantSize, size)
+				return
+			}
+
+			var unusableErr *UnusableError
+			require.True(t, errors.As(err, &unusableErr), "unexpected error: %v", err)
+			assert.Contains(t, unusableErr.Reason, tt.wantReason)
+		})
+	}
+}
+
+func TestProbeConnectionError(t *testing.T) {
+	server := httptest.NewServer(serveImage(nil))
+	rawURL := server.URL
+	server.Close()
+
+	_, err := Probe(&http.Client{Timeout: ProbeTimeout}, rawURL)
+	var unusableErr *UnusableError
+	require.True(t, errors.As(err, &unusableErr))
+	assert.Contains(t, unusableErr.Reason, "failed to connect to the server")
+}
+
+func TestParseContentRangeSize(t *testing.T) {
+	size, err := parseContentRangeSize("bytes 0-511/1048576")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1048576), size)
+
+	for _, contentRange := range []string{"", "bytes 0-511/*", "items 0-1/2", "bytes 0-511"} {
+		_, err := parseContentRangeSize(contentRange)
+		assert.Error(t, err, contentRange)
+	}
+}
@@ -0,0 +1,143 @@
+package keyboard
+
+// Modifier bits of the HID keyboard reports
+const (
+	ModifierLeftCtrl   uint8 = 0x01
+	ModifierLeftShift  uint8 = 0x02
+	ModifierLeftAlt    uint8 = 0x04
+	ModifierLeftMeta   uint8 = 0x08
+	ModifierRightCtrl  uint8 = 0x10
+	ModifierRightShift uint8 = 0x20
+	ModifierRightAlt   uint8 = 0x40
+	ModifierRightMeta  uint8 = 0x80
+)
+
+// keyCodes are the HID usages of the keys, as defined for the Linux USB HID gadget driver
+// and in ui/src/keyboardMappings.ts
+var keyCodes = map[string]uint8{
+	"ArrowDown":         0x51,
+	"ArrowLeft":         0x50,
+	"ArrowRight":        0x4f,
+	"ArrowUp":           0x52,
+	"Backquote":         0x35,
+	"Backslash":         0x31,
+	"Backspace":         0x2a,
+	"BracketLeft":       0x2f,
+	"BracketRight":      0x30,
+	"CapsLock":          0x39,
+	"Comma":             0x36,
+	"ContextMenu":       0x65,
+	"Delete":            0x4c,
+	"Digit0":            0x27,
+	"Digit1":            0x1e,
+	"Digit2":            0x1f,
+	"Digit3":            0x20,
+	"Digit4":            0x21,
+	"Digit5":            0x22,
+	"Digit6":            0x23,
+	"Digit7":            0x24,
+	"Digit8":            0x25,
+	"Digit9":            0x26,
+	"End":               0x4d,
+	"Enter":             0x28,
+	"Equal":             0x2e,
+	"Escape":            0x29,
+	"F1":                0x3a,
+	"F2":                0x3b,
+	"F3":                0x3c,
+	"F4":                0x3d,
+	"F5":                0x3e,
+	"F6":                0x3f,
+	"F7":                0x40,
+	"F8":                0x41,
+	"F9":                0x42,
+	"F10":               0x43,
+	"F11":               0x44,
+	"F12":               0x45,
+	"F13":               0x68,
+	"Home":              0x4a,
+	"Insert":            0x49,
+	"IntlBackslash":     0x64,
+	"KeyA":              0x04,
+	"KeyB":              0x05,
+	"KeyC":              0x06,
+	"KeyD":              0x07,
+	"KeyE":              0x08,
+	"KeyF":              0x09,
+	"KeyG":              0x0a,
+	"KeyH":              0x0b,
+	"KeyI":              0x0c,
+	"KeyJ":              0x0d,
+	"KeyK":              0x0e,
+	"KeyL":              0x0f,
+	"KeyM":              0x10,
+	"KeyN":              0x11,
+	"KeyO":              0x12,
+	"KeyP":              0x13,
+	"KeyQ":              0x14,
+	"KeyR":              0x15,
+	"KeyS":              0x16,
+	"KeyT":              0x17,
+	"KeyU":              0x18,
+	"KeyV":              0x19,
+	"KeyW":              0x1a,
+	"KeyX":              0x1b,
+	"KeyY":              0x1c,
+	"KeyZ":              0x1d,
+	"KeypadExclamation": 0xcf,
+	"Minus":             0x2d,
+	"NumLock":           0x53,
+	"Numpad0":           0x62,
+	"Numpad1":           0x59,
+	"Numpad2":           0x5a,
+	"Numpad3":           0x5b,
+	"Numpad4":           0x5c,
+	"Numpad5":           0x5d,
+	"Numpad6":           0x5e,
+	"Numpad7":           0x5f,
+	"Numpad8":           0x60,
+	"Numpad9":           0x61,
+	"NumpadAdd":         0x57,
+	"NumpadDivide":      0x54,
+	"NumpadEnter":       0x58,
+	"NumpadEqual":       0x67,
+	"NumpadMultiply":    0x55,
+	"NumpadSubtract":    0x56,
+	"NumpadDecimal":     0x63,
+	"PageDown":          0x4e,
+	"PageUp":            0x4b,
+	"Period":            0x37,
+	"PrintScreen":       0x46,
+	"Pause":             0x48,
+	"Quote":             0x34,
+	"ScrollLock":        0x47,
+	"Semicolon":         0x33,
+	"Slash":             0x38,
+	"Space":             0x2c,
+	"SystemRequest":     0x9a,
+	"Tab":               0x2b,
+}
+
+// modifierBits are the modifiers by their KeyboardEvent.code
+var modifierBits = map[string]uint8{
+	"ControlLeft":  ModifierLeftCtrl,
+	"ShiftLeft":    ModifierLeftShift,
+	"AltLeft":      ModifierLeftAlt,
+	"MetaLeft":     ModifierLeftMeta,
+	"ControlRight": ModifierRightCtrl,
+	"ShiftRight":   ModifierRightShift,
+	"AltRight":     ModifierRightAlt,
+	"MetaRight":    ModifierRightMeta,
+}
+
+// KeyCode returns the HID usage of the key named by its KeyboardEvent.code.
+func KeyCode(name string) (uint8, bool) {
+	code, ok := keyCodes[name]
+	return code, ok
+}
+
+// ModifierBit returns the bit of the modifier named by its KeyboardEvent.code.
+func ModifierBit(name string) (uint8, bool) {
+	bit, ok := modifierBits[name]
+	return bit, ok
+}
@@ -0,0 +1,152 @@
+// Package keyboard maps text to the HID reports typing it on a host using a given
+// keyboard layout. The layouts mirror the ones of the web UI in ui/src/keyboardLayouts.
+package keyboard
+
+import (
+	"maps"
+	"slices"
+)
+
+// KeyInfo is a key of the host keyboard, along with the modifiers held to press it.
+type KeyInfo struct {
+	// Key is the code of the key, as named by the KeyboardEvent.code of the browsers
+	Key      string
+	Shift    bool
+	AltRight bool
+}
+
+// KeyCombo is how a character is typed on a layout.
+type KeyCombo struct {
+	Key      string
+	Shift    bool
+	AltRight bool
+	// DeadKey is set when the key only types the character once followed by a space
+	DeadKey bool
+	// AccentKey is the dead key pressed before the key to add the accent to the character
+	AccentKey *KeyInfo
+}
+
+// Layout is a keyboard layout, identified by its code in Config.KeyboardLayout.
+type Layout struct {
+	Code  string
+	Name  string
+	Chars map[rune]KeyCombo
+}
+
+// Stroke is a key pressed and released with the modifiers held.
+type Stroke struct {
+	Modifier uint8
+	Key      uint8
+}
+
+// DefaultLayout is the layout used when none is configured.
+const DefaultLayout = "en_US"
+
+// controlChars are typed the same way on every layout
+var controlChars = map[rune]KeyCombo{
+	'\n': {Key: "Enter"},
+	'\t': {Key: "Tab"},
+}
+
+var layouts = indexLayouts(beFR, csCZ, deCH, deDE, enUK, enUS, esES, frCH, frFR, itIT, nbNO, svSE)
+
+func indexLayouts(list ...*Layout) map[string]*Layout {
+	indexed := make(map[string]*Layout, len(list))
+	for _, layout := range list {
+		indexed[layout.Code] = layout
+	}
+	return indexed
+}
+
+// extendLayout returns a copy of the base layout with some characters typed differently.
+func extendLayout(base *Layout, code string, name string, chars map[rune]KeyCombo) *Layout {
+	merged := maps.Clone(base.Chars)
+	maps.Copy(merged, chars)
+	return &Layout{Code: code, Name: name, Chars: merged}
+}
+
+// Get returns the layout with the given code.
+func Get(code string) (*Layout, bool) {
+	layout, ok := layouts[code]
+	return layout, ok
+}
+
+// Layouts returns the names of the layouts, by code.
+func Layouts() map[string]string {
+	names := make(map[string]string, len(layouts))
+	for code, layout := range layouts {
+		names[code] = layout.Name
+	}
+	return names
+}
+
+func (l *Layout) combo(r rune) (KeyCombo, bool) {
+	if combo, ok := l.Chars[r]; ok {
+		return combo, true
+	}
+	combo, ok := controlChars[r]
+	return combo, ok
+}
+
+// Supports reports whether the character can be typed with the layout.
+func (l *Layout) Supports(r rune) bool {
+	_, ok := l.Strokes(r)
+	return ok
+}
+
+// Unsupported returns the characters of the text that can't be typed with the layout,
+// in their order of appearance and without duplicates.
+func (l *Layout) Unsupported(text string) []rune {
+	unsupported := make([]rune, 0)
+	for _, r := range text {
+		if !l.Supports(r) && !slices.Contains(unsupported, r) {
+			unsupported = append(unsupported, r)
+		}
+	}
+	return unsupported
+}
+
+// Strokes returns the keys to press one after the other to type the character: the
+// accent first when the character needs one, and a space after a dead key.
+func (l *Layout) Strokes(r rune) ([]Stroke, bool) {
+	combo, ok := l.combo(r)
+	if !ok {
+		return nil, false
+	}
+
+	strokes := make([]Stroke, 0, 3)
+	if combo.AccentKey != nil {
+		stroke, ok := combo.AccentKey.stroke()
+		if !ok {
+			return nil, false
+		}
+		strokes = append(strokes, stroke)
+	}
+
+	stroke, ok := KeyInfo{Key: combo.Key, Shift: combo.Shift, AltRight: combo.AltRight}.stroke()
+	if !ok {
+		return nil, false
+	}
+	strokes = append(strokes, stroke)
+
+	if combo.DeadKey {
+		strokes = append(strokes, Stroke{Key: keyCodes["Space"]})
+	}
+	return strokes, true
+}
+
+func (k KeyInfo) stroke() (Stroke, bool) {
+	code, ok := keyCodes[k.Key]
+	if !ok {
+		return Stroke{}, false
+	}
+
+	var modifier uint8
+	if k.Shift {
+		modifier |= ModifierLeftShift
+	}
+	if k.AltRight {
+		modifier |= ModifierRightAlt
+	}
+	return Stroke{Modifier: modifier, Key: code}, true
+}
@@ -0,0 +1,169 @@
+package keyboard
+
+var (
+	beFRKeyTrema = KeyInfo{Key: "BracketLeft", Shift: true}  // tréma (umlaut), two dots placed above a vowel
+	beFRKeyHat   = KeyInfo{Key: "BracketLeft"}               // accent circonflexe (accent hat), mark ^ placed above the letter
+	beFRKeyAcute = KeyInfo{Key: "Semicolon", AltRight: true} // accent aigu (acute accent), mark ´ placed above the letter
+	beFRKeyGrave = KeyInfo{Key: "Quote", Shift: true}        // accent grave, mark ` placed above the letter
+	beFRKeyTilde = KeyInfo{Key: "Slash", AltRight: true}     // tilde, mark ~ placed above the letter
+)
+
+var beFR = &Layout{
+	Code: "be_FR",
+	Name: "Belgisch Nederlands",
+	Chars: map[rune]KeyCombo{
+		'A':  {Key: "KeyQ", Shift: true},
+		'Ä':  {Key: "KeyQ", Shift: true, AccentKey: &beFRKeyTrema},
+		'Â':  {Key: "KeyQ", Shift: true, AccentKey: &beFRKeyHat},
+		'Á':  {Key: "KeyQ", Shift: true, AccentKey: &beFRKeyAcute},
+		'À':  {Key: "KeyQ", Shift: true, AccentKey: &beFRKeyGrave},
+		'Ã':  {Key: "KeyQ", Shift: true, AccentKey: &beFRKeyTilde},
+		'B':  {Key: "KeyB", Shift: true},
+		'C':  {Key: "KeyC", Shift: true},
+		'D':  {Key: "KeyD", Shift: true},
+		'E':  {Key: "KeyE", Shift: true},
+		'Ë':  {Key: "KeyE", Shift: true, AccentKey: &beFRKeyTrema},
+		'Ê':  {Key: "KeyE", Shift: true, AccentKey: &beFRKeyHat},
+		'É':  {Key: "KeyE", Shift: true, AccentKey: &beFRKeyAcute},
+		'È':  {Key: "KeyE", Shift: true, AccentKey: &beFRKeyGrave},
+		'Ẽ':  {Key: "KeyE", Shift: true, AccentKey: &beFRKeyTilde},
+		'F':  {Key: "KeyF", Shift: true},
+		'G':  {Key: "KeyG", Shift: true},
+		'H':  {Key: "KeyH", Shift: true},
+		'I':  {Key: "KeyI", Shift: true},
+		'Ï':  {Key: "KeyI", Shift: true, AccentKey: &beFRKeyTrema},
+		'Î':  {Key: "KeyI", Shift: true, AccentKey: &beFRKeyHat},
+		'Í':  {Key: "KeyI", Shift: true, AccentKey: &beFRKeyAcute},
+		'Ì':  {Key: "KeyI", Shift: true, AccentKey: &beFRKeyGrave},
+		'Ĩ':  {Key: "KeyI", Shift: true, AccentKey: &beFRKeyTilde},
+		'J':  {Key: "KeyJ", Shift: true},
+		'K':  {Key: "KeyK", Shift: true},
+		'L':  {Key: "KeyL", Shift: true},
+		'M':  {Key: "Semicolon", Shift: true},
+		'N':  {Key: "KeyN", Shift: true},
+		'O':  {Key: "KeyO", Shift: true},
+		'Ö':  {Key: "KeyO", Shift: true, AccentKey: &beFRKeyTrema},
+		'Ô':  {Key: "KeyO", Shift: true, AccentKey: &beFRKeyHat},
+		'Ó':  {Key: "KeyO", Shift: true, AccentKey: &beFRKeyAcute},
+		'Ò':  {Key: "KeyO", Shift: true, AccentKey: &beFRKeyGrave},
+		'Õ':  {Key: "KeyO", Shift: true, AccentKey: &beFRKeyTilde},
+		'P':  {Key: "KeyP", Shift: true},
+		'Q':  {Key: "KeyA", Shift: true},
+		'R':  {Key: "KeyR", Shift: true},
+		'S':  {Key: "KeyS", Shift: true},
+		'T':  {Key: "KeyT", Shift: true},
+		'U':  {Key: "KeyU", Shift: true},
+		'Ü':  {Key: "KeyU", Shift: true, AccentKey: &beFRKeyTrema},
+		'Û':  {Key: "KeyU", Shift: true, AccentKey: &beFRKeyHat},
+		'Ú':  {Key: "KeyU", Shift: true, AccentKey: &beFRKeyAcute},
+		'Ù':  {Key: "KeyU", Shift: true, AccentKey: &beFRKeyGrave},
+		'Ũ':  {Key: "KeyU", Shift: true, AccentKey: &beFRKeyTilde},
+		'V':  {Key: "KeyV", Shift: true},
+		'W':  {Key: "KeyW", Shift: true},
+		'X':  {Key: "KeyX", Shift: true},
+		'Y':  {Key: "KeyZ", Shift: true},
+		'Z':  {Key: "KeyY", Shift: true},
+		'a':  {Key: "KeyQ"},
+		'ä':  {Key: "KeyQ", AccentKey: &beFRKeyTrema},
+		'â':  {Key: "KeyQ", AccentKey: &beFRKeyHat},
+		'á':  {Key: "KeyQ", AccentKey: &beFRKeyAcute},
+		'ã':  {Key: "KeyQ", AccentKey: &beFRKeyTilde},
+		'b':  {Key: "KeyB"},
+		'c':  {Key: "KeyC"},
+		'd':  {Key: "KeyD"},
+		'e':  {Key: "KeyE"},
+		'ë':  {Key: "KeyE", AccentKey: &beFRKeyTrema},
+		'ê':  {Key: "KeyE", AccentKey: &beFRKeyHat},
+		'ẽ':  {Key: "KeyE", AccentKey: &beFRKeyTilde},
+		'€':  {Key: "KeyE", AltRight: true},
+		'f':  {Key: "KeyF"},
+		'g':  {Key: "KeyG"},
+		'h':  {Key: "KeyH"},
+		'i':  {Key: "KeyI"},
+		'ï':  {Key: "KeyI", AccentKey: &beFRKeyTrema},
+		'î':  {Key: "KeyI", AccentKey: &beFRKeyHat},
+		'í':  {Key: "KeyI", AccentKey: &beFRKeyAcute},
+		'ì':  {Key: "KeyI", AccentKey: &beFRKeyGrave},
+		'ĩ':  {Key: "KeyI", AccentKey: &beFRKeyTilde},
+		'j':  {Key: "KeyJ"},
+		'k':  {Key: "KeyK"},
+		'l':  {Key: "KeyL"},
+		'm':  {Key: "Semicolon"},
+		'n':  {Key: "KeyN"},
+		'o':  {Key: "KeyO"},
+		'ö':  {Key: "KeyO", AccentKey: &beFRKeyTrema},
+		'ó':  {Key: "KeyO", AccentKey: &beFRKeyAcute},
+		'ô':  {Key: "KeyO", AccentKey: &beFRKeyHat},
+		'ò':  {Key: "KeyO", AccentKey: &beFRKeyGrave},
+		'õ':  {Key: "KeyO", AccentKey: &beFRKeyTilde},
+		'p':  {Key: "KeyP"},
+		'q':  {Key: "KeyA"},
+		'r':  {Key: "KeyR"},
+		's':  {Key: "KeyS"},
+		't':  {Key: "KeyT"},
+		'u':  {Key: "KeyU"},
+		'ü':  {Key: "KeyU", AccentKey: &beFRKeyTrema},
+		'û':  {Key: "KeyU", AccentKey: &beFRKeyHat},
+		'ú':  {Key: "KeyU", AccentKey: &beFRKeyAcute},
+		'ũ':  {Key: "KeyU", AccentKey: &beFRKeyTilde},
+		'v':  {Key: "KeyV"},
+		'w':  {Key: "KeyW"},
+		'x':  {Key: "KeyX"},
+		'y':  {Key: "KeyZ"},
+		'z':  {Key: "KeyY"},
+		'²':  {Key: "Backquote"},
+		'³':  {Key: "Backquote", Shift: true},
+		'&':  {Key: "Digit1"},
+		'1':  {Key: "Digit1", Shift: true},
+		'|':  {Key: "Digit1", AltRight: true},
+		'é':  {Key: "Digit2"},
+		'2':  {Key: "Digit2", Shift: true},
+		'@':  {Key: "Digit2", AltRight: true},
+		'"':  {Key: "Digit3"},
+		'3':  {Key: "Digit3", Shift: true},
+		'#':  {Key: "Digit3", AltRight: true},
+		'\'': {Key: "Digit4"},
+		'4':  {Key: "Digit4", Shift: true},
+		'(':  {Key: "Digit5"},
+		'5':  {Key: "Digit5", Shift: true},
+		'§':  {Key: "Digit6"},
+		'6':  {Key: "Digit6", Shift: true},
+		'^':  {Key: "Digit6", AltRight: true},
+		'è':  {Key: "Digit7"},
+		'7':  {Key: "Digit7", Shift: true},
+		'!':  {Key: "Digit8"},
+		'8':  {Key: "Digit8", Shift: true},
+		'ç':  {Key: "Digit9"},
+		'9':  {Key: "Digit9", Shift: true},
+		'{':  {Key: "Digit9", AltRight: true},
+		'à':  {Key: "Digit0"},
+		'0':  {Key: "Digit0", Shift: true},
+		'}':  {Key: "Digit0", AltRight: true},
+		')':  {Key: "Minus"},
+		'°':  {Key: "Minus", Shift: true},
+		'-':  {Key: "Equal", DeadKey: true},
+		'_':  {Key: "Equal", Shift: true},
+		'[':  {Key: "BracketLeft", AltRight: true},
+		'$':  {Key: "BracketRight"},
+		'*':  {Key: "BracketRight", AltRight: true},
+		']':  {Key: "BracketRight", AltRight: true},
+		'ù':  {Key: "Quote"},
+		'%':  {Key: "Quote", Shift: true},
+		'µ':  {Key: "Backslash"},
+		'£':  {Key: "Backslash", Shift: true},
+		',':  {Key: "KeyM"},
+		'?':  {Key: "KeyM", Shift: true},
+		';':  {Key: "Comma"},
+		'.':  {Key: "Comma", Shift: true},
+		':':  {Key: "Period"},
+		'/':  {Key: "Period", Shift: true},
+		'=':  {Key: "Slash"},
+		'+':  {Key: "Slash", Shift: true},
+		'~':  {Key: "Slash", DeadKey: true},
+		'<':  {Key: "IntlBackslash"},
+		'>':  {Key: "IntlBackslash", Shift: true},
+		'\\': {Key: "IntlBackslash", AltRight: true},
+		' ':  {Key: "Space"},
+		'\n': {Key: "Enter"},
+	},
+}
@@ -0,0 +1,154 @@
+package keyboard
+
+var (
+	deDEKeyAcute = KeyInfo{Key: "Equal"}              // accent aigu (acute accent), mark ´ placed above the letter
+	deDEKeyHat   = KeyInfo{Key: "Backquote"}          // accent circonflexe (accent hat), mark ^ placed above the letter
+	deDEKeyGrave = KeyInfo{Key: "Equal", Shift: true} // accent grave, mark ` placed above the letter
+)
+
+var deDE = &Layout{
+	Code: "de_DE",
+	Name: "Deutsch",
+	Chars: map[rune]KeyCombo{
+		'A':  {Key: "KeyA", Shift: true},
+		'Á':  {Key: "KeyA", Shift: true, AccentKey: &deDEKeyAcute},
+		'Â':  {Key: "KeyA", Shift: true, AccentKey: &deDEKeyHat},
+		'À':  {Key: "KeyA", Shift: true, AccentKey: &deDEKeyGrave},
+		'B':  {Key: "KeyB", Shift: true},
+		'C':  {Key: "KeyC", Shift: true},
+		'D':  {Key: "KeyD", Shift: true},
+		'E':  {Key: "KeyE", Shift: true},
+		'É':  {Key: "KeyE", Shift: true, AccentKey: &deDEKeyAcute},
+		'Ê':  {Key: "KeyE", Shift: true, AccentKey: &deDEKeyHat},
+		'È':  {Key: "KeyE", Shift: true, AccentKey: &deDEKeyGrave},
+		'F':  {Key: "KeyF", Shift: true},
+		'G':  {Key: "KeyG", Shift: true},
+		'H':  {Key: "KeyH", Shift: true},
+		'I':  {Key: "KeyI", Shift: true},
+		'Í':  {Key: "KeyI", Shift: true, AccentKey: &deDEKeyAcute},
+		'Î':  {Key: "KeyI", Shift: true, AccentKey: &deDEKeyHat},
+		'Ì':  {Key: "KeyI", Shift: true, AccentKey: &deDEKeyGrave},
+		'J':  {Key: "KeyJ", Shift: true},
+		'K':  {Key: "KeyK", Shift: true},
+		'L':  {Key: "KeyL", Shift: true},
+		'M':  {Key: "KeyM", Shift: true},
+		'N':  {Key: "KeyN", Shift: true},
+		'O':  {Key: "KeyO", Shift: true},
+		'Ó':  {Key: "KeyO", Shift: true, AccentKey: &deDEKeyAcute},
+		'Ô':  {Key: "KeyO", Shift: true, AccentKey: &deDEKeyHat},
+		'Ò':  {Key: "KeyO", Shift: true, AccentKey: &deDEKeyGrave},
+		'P':  {Key: "KeyP", Shift: true},
+		'Q':  {Key: "KeyQ", Shift: true},
+		'R':  {Key: "KeyR", Shift: true},
+		'S':  {Key: "KeyS", Shift: true},
+		'T':  {Key: "KeyT", Shift: true},
+		'U':  {Key: "KeyU", Shift: true},
+		'Ú':  {Key: "KeyU", Shift: true, AccentKey: &deDEKeyAcute},
+		'Û':  {Key: "KeyU", Shift: true, AccentKey: &deDEKeyHat},
+		'Ù':  {Key: "KeyU", Shift: true, AccentKey: &deDEKeyGrave},
+		'V':  {Key: "KeyV", Shift: true},
+		'W':  {Key: "KeyW", Shift: true},
+		'X':  {Key: "KeyX", Shift: true},
+		'Y':  {Key: "KeyZ", Shift: true},
+		'Z':  {Key: "KeyY", Shift: true},
+		'a':  {Key: "KeyA"},
+		'á':  {Key: "KeyA", AccentKey: &deDEKeyAcute},
+		'â':  {Key: "KeyA", AccentKey: &deDEKeyHat},
+		'à':  {Key: "KeyA", AccentKey: &deDEKeyGrave},
+		'b':  {Key: "KeyB"},
+		'c':  {Key: "KeyC"},
+		'd':  {Key: "KeyD"},
+		'e':  {Key: "KeyE"},
+		'é':  {Key: "KeyE", AccentKey: &deDEKeyAcute},
+		'ê':  {Key: "KeyE", AccentKey: &deDEKeyHat},
+		'è':  {Key: "KeyE", AccentKey: &deDEKeyGrave},
+		'€':  {Key: "KeyE", AltRight: true},
+		'f':  {Key: "KeyF"},
+		'g':  {Key: "KeyG"},
+		'h':  {Key: "KeyH"},
+		'i':  {Key: "KeyI"},
+		'í':  {Key: "KeyI", AccentKey: &deDEKeyAcute},
+		'î':  {Key: "KeyI", AccentKey: &deDEKeyHat},
+		'ì':  {Key: "KeyI", AccentKey: &deDEKeyGrave},
+		'j':  {Key: "KeyJ"},
+		'k':  {Key: "KeyK"},
+		'l':  {Key: "KeyL"},
+		'm':  {Key: "KeyM"},
+		'µ':  {Key: "KeyM", AltRight: true},
+		'n':  {Key: "KeyN"},
+		'o':  {Key: "KeyO"},
+		'ó':  {Key: "KeyO", AccentKey: &deDEKeyAcute},
+		'ô':  {Key: "KeyO", AccentKey: &deDEKeyHat},
+		'ò':  {Key: "KeyO", AccentKey: &deDEKeyGrave},
+		'p':  {Key: "KeyP"},
+		'q':  {Key: "KeyQ"},
+		'@':  {Key: "KeyQ", AltRight: true},
+		'r':  {Key: "KeyR"},
+		's':  {Key: "KeyS"},
+		't':  {Key: "KeyT"},
+		'u':  {Key: "KeyU"},
+		'ú':  {Key: "KeyU", AccentKey: &deDEKeyAcute},
+		'û':  {Key: "KeyU", AccentKey: &deDEKeyHat},
+		'ù':  {Key: "KeyU", AccentKey: &deDEKeyGrave},
+		'v':  {Key: "KeyV"},
+		'w':  {Key: "KeyW"},
+		'x':  {Key: "KeyX"},
+		'y':  {Key: "KeyZ"},
+		'z':  {Key: "KeyY"},
+		'°':  {Key: "Backquote", Shift: true},
+		'^':  {Key: "Backquote", DeadKey: true},
+		'1':  {Key: "Digit1"},
+		'!':  {Key: "Digit1", Shift: true},
+		'2':  {Key: "Digit2"},
+		'"':  {Key: "Digit2", Shift: true},
+		'²':  {Key: "Digit2", AltRight: true},
+		'3':  {Key: "Digit3"},
+		'§':  {Key: "Digit3", Shift: true},
+		'³':  {Key: "Digit3", AltRight: true},
+		'4':  {Key: "Digit4"},
+		'$':  {Key: "Digit4", Shift: true},
+		'5':  {Key: "Digit5"},
+		'%':  {Key: "Digit5", Shift: true},
+		'6':  {Key: "Digit6"},
+		'&':  {Key: "Digit6", Shift: true},
+		'7':  {Key: "Digit7"},
+		'/':  {Key: "Digit7", Shift: true},
+		'{':  {Key: "Digit7", AltRight: true},
+		'8':  {Key: "Digit8"},
+		'(':  {Key: "Digit8", Shift: true},
+		'[':  {Key: "Digit8", AltRight: true},
+		'9':  {Key: "Digit9"},
+		')':  {Key: "Digit9", Shift: true},
+		']':  {Key: "Digit9", AltRight: true},
+		'0':  {Key: "Digit0"},
+		'=':  {Key: "Digit0", Shift: true},
+		'}':  {Key: "Digit0", AltRight: true},
+		'ß':  {Key: "Minus"},
+		'?':  {Key: "Minus", Shift: true},
+		'\\': {Key: "Minus", AltRight: true},
+		'´':  {Key: "Equal", DeadKey: true},
+		'`':  {Key: "Equal", Shift: true, DeadKey: true},
+		'ü':  {Key: "BracketLeft"},
+		'Ü':  {Key: "BracketLeft", Shift: true},
+		'+':  {Key: "BracketRight"},
+		'*':  {Key: "BracketRight", Shift: true},
+		'~':  {Key: "BracketRight", AltRight: true},
+		'ö':  {Key: "Semicolon"},
+		'Ö':  {Key: "Semicolon", Shift: true},
+		'ä':  {Key: "Quote"},
+		'Ä':  {Key: "Quote", Shift: true},
+		'#':  {Key: "Backslash"},
+		'\'': {Key: "Backslash", Shift: true},
+		',':  {Key: "Comma"},
+		';':  {Key: "Comma", Shift: true},
+		'.':  {Key: "Period"},
+		':':  {Key: "Period", Shift: true},
+		'-':  {Key: "Slash"},
+		'_':  {Key: "Slash", Shift: true},
+		'<':  {Key: "IntlBackslash"},
+		'>':  {Key: "IntlBackslash", Shift: true},
+		'|':  {Key: "IntlBackslash", AltRight: true},
+		' ':  {Key: "Space"},
+		'\n': {Key: "Enter"},
+	},
+}
@@ -0,0 +1,170 @@
+package keyboard
+
+var (
+	esESKeyTrema = KeyInfo{Key: "Quote", Shift: true}        // tréma (umlaut), two dots placed above a vowel
+	esESKeyAcute = KeyInfo{Key: "Quote"}                     // accent aigu (acute accent), mark ´ placed above the letter
+	esESKeyHat   = KeyInfo{Key: "BracketRight", Shift: true} // accent circonflexe (accent hat), mark ^ placed above the letter
+	esESKeyGrave = KeyInfo{Key: "BracketRight"}              // accent grave, mark ` placed above the letter
+	esESKeyTilde = KeyInfo{Key: "Digit4", AltRight: true}    // tilde, mark ~ placed above the letter
+)
+
+var esES = &Layout{
+	Code: "es_ES",
+	Name: "Español",
+	Chars: map[rune]KeyCombo{
+		'A':  {Key: "KeyA", Shift: true},
+		'Ä':  {Key: "KeyA", Shift: true, AccentKey: &esESKeyTrema},
+		'Á':  {Key: "KeyA", Shift: true, AccentKey: &esESKeyAcute},
+		'Â':  {Key: "KeyA", Shift: true, AccentKey: &esESKeyHat},
+		'À':  {Key: "KeyA", Shift: true, AccentKey: &esESKeyGrave},
+		'Ã':  {Key: "KeyA", Shift: true, AccentKey: &esESKeyTilde},
+		'B':  {Key: "KeyB", Shift: true},
+		'C':  {Key: "KeyC", Shift: true},
+		'D':  {Key: "KeyD", Shift: true},
+		'E':  {Key: "KeyE", Shift: true},
+		'Ë':  {Key: "KeyE", Shift: true, AccentKey: &esESKeyTrema},
+		'É':  {Key: "KeyE", Shift: true, AccentKey: &esESKeyAcute},
+		'Ê':  {Key: "KeyE", Shift: true, AccentKey: &esESKeyHat},
+		'È':  {Key: "KeyE", Shift: true, AccentKey: &esESKeyGrave},
+		'Ẽ':  {Key: "KeyE", Shift: true, AccentKey: &esESKeyTilde},
+		'F':  {Key: "KeyF", Shift: true},
+		'G':  {Key: "KeyG", Shift: true},
+		'H':  {Key: "KeyH", Shift: true},
+		'I':  {Key: "KeyI", Shift: true},
+		'Ï':  {Key: "KeyI", Shift: true, AccentKey: &esESKeyTrema},
+		'Í':  {Key: "KeyI", Shift: true, AccentKey: &esESKeyAcute},
+		'Î':  {Key: "KeyI", Shift: true, AccentKey: &esESKeyHat},
+		'Ì':  {Key: "KeyI", Shift: true, AccentKey: &esESKeyGrave},
+		'Ĩ':  {Key: "KeyI", Shift: true, AccentKey: &esESKeyTilde},
+		'J':  {Key: "KeyJ", Shift: true},
+		'K':  {Key: "KeyK", Shift: true},
+		'L':  {Key: "KeyL", Shift: true},
+		'M':  {Key: "KeyM", Shift: true},
+		'N':  {Key: "KeyN", Shift: true},
+		'O':  {Key: "KeyO", Shift: true},
+		'Ö':  {Key: "KeyO", Shift: true, AccentKey: &esESKeyTrema},
+		'Ó':  {Key: "KeyO", Shift: true, AccentKey: &esESKeyAcute},
+		'Ô':  {Key: "KeyO", Shift: true, AccentKey: &esESKeyHat},
+		'Ò':  {Key: "KeyO", Shift: true, AccentKey: &esESKeyGrave},
+		'Õ':  {Key: "KeyO", Shift: true, AccentKey: &esESKeyTilde},
+		'P':  {Key: "KeyP", Shift: true},
+		'Q':  {Key: "KeyQ", Shift: true},
+		'R':  {Key: "KeyR", Shift: true},
+		'S':  {Key: "KeyS", Shift: true},
+		'T':  {Key: "KeyT", Shift: true},
+		'U':  {Key: "KeyU", Shift: true},
+		'Ü':  {Key: "KeyU", Shift: true, AccentKey: &esESKeyTrema},
+		'Ú':  {Key: "KeyU", Shift: true, AccentKey: &esESKeyAcute},
+		'Û':  {Key: "KeyU", Shift: true, AccentKey: &esESKeyHat},
+		'Ù':  {Key: "KeyU", Shift: true, AccentKey: &esESKeyGrave},
+		'Ũ':  {Key: "KeyU", Shift: true, AccentKey: &esESKeyTilde},
+		'V':  {Key: "KeyV", Shift: true},
+		'W':  {Key: "KeyW", Shift: true},
+		'X':  {Key: "KeyX", Shift: true},
+		'Y':  {Key: "KeyY", Shift: true},
+		'Z':  {Key: "KeyZ", Shift: true},
+		'a':  {Key: "KeyA"},
+		'ä':  {Key: "KeyA", AccentKey: &esESKeyTrema},
+		'á':  {Key: "KeyA", AccentKey: &esESKeyAcute},
+		'â':  {Key: "KeyA", AccentKey: &esESKeyHat},
+		'à':  {Key: "KeyA", AccentKey: &esESKeyGrave},
+		'ã':  {Key: "KeyA", AccentKey: &esESKeyTilde},
+		'b':  {Key: "KeyB"},
+		'c':  {Key: "KeyC"},
+		'd':  {Key: "KeyD"},
+		'e':  {Key: "KeyE"},
+		'ë':  {Key: "KeyE", AccentKey: &esESKeyTrema},
+		'é':  {Key: "KeyE", AccentKey: &esESKeyAcute},
+		'ê':  {Key: "KeyE", AccentKey: &esESKeyHat},
+		'è':  {Key: "KeyE", AccentKey: &esESKeyGrave},
+		'ẽ':  {Key: "KeyE", AccentKey: &esESKeyTilde},
+		'€':  {Key: "KeyE", AltRight: true},
+		'f':  {Key: "KeyF"},
+		'g':  {Key: "KeyG"},
+		'h':  {Key: "KeyH"},
+		'i':  {Key: "KeyI"},
+		'ï':  {Key: "KeyI", AccentKey: &esESKeyTrema},
+		'í':  {Key: "KeyI", AccentKey: &esESKeyAcute},
+		'î':  {Key: "KeyI", AccentKey: &esESKeyHat},
+		'ì':  {Key: "KeyI", AccentKey: &esESKeyGrave},
+		'ĩ':  {Key: "KeyI", AccentKey: &esESKeyTilde},
+		'j':  {Key: "KeyJ"},
+		'k':  {Key: "KeyK"},
+		'l':  {Key: "KeyL"},
+		'm':  {Key: "KeyM"},
+		'n':  {Key: "KeyN"},
+		'o':  {Key: "KeyO"},
+		'ö':  {Key: "KeyO", AccentKey: &esESKeyTrema},
+		'ó':  {Key: "KeyO", AccentKey: &esESKeyAcute},
+		'ô':  {Key: "KeyO", AccentKey: &esESKeyHat},
+		'ò':  {Key: "KeyO", AccentKey: &esESKeyGrave},
+		'õ':  {Key: "KeyO", AccentKey: &esESKeyTilde},
+		'p':  {Key: "KeyP"},
+		'q':  {Key: "KeyQ"},
+		'r':  {Key: "KeyR"},
+		's':  {Key: "KeyS"},
+		't':  {Key: "KeyT"},
+		'u':  {Key: "KeyU"},
+		'ü':  {Key: "KeyU", AccentKey: &esESKeyTrema},
+		'ú':  {Key: "KeyU", AccentKey: &esESKeyAcute},
+		'û':  {Key: "KeyU", AccentKey: &esESKeyHat},
+		'ù':  {Key: "KeyU", AccentKey: &esESKeyGrave},
+		'ũ':  {Key: "KeyU", AccentKey: &esESKeyTilde},
+		'v':  {Key: "KeyV"},
+		'w':  {Key: "KeyW"},
+		'x':  {Key: "KeyX"},
+		'y':  {Key: "KeyY"},
+		'z':  {Key: "KeyZ"},
+		'º':  {Key: "Backquote"},
+		'ª':  {Key: "Backquote", Shift: true},
+		'\\': {Key: "Backquote", AltRight: true},
+		'1':  {Key: "Digit1"},
+		'!':  {Key: "Digit1", Shift: true},
+		'|':  {Key: "Digit1", AltRight: true},
+		'2':  {Key: "Digit2"},
+		'"':  {Key: "Digit2", Shift: true},
+		'@':  {Key: "Digit2", AltRight: true},
+		'3':  {Key: "Digit3"},
+		'·':  {Key: "Digit3", Shift: true},
+		'#':  {Key: "Digit3", AltRight: true},
+		'4':  {Key: "Digit4"},
+		'$':  {Key: "Digit4", Shift: true},
+		'5':  {Key: "Digit5"},
+		'%':  {Key: "Digit5", Shift: true},
+		'6':  {Key: "Digit6"},
+		'&':  {Key: "Digit6", Shift: true},
+		'¬':  {Key: "Digit6", AltRight: true},
+		'7':  {Key: "Digit7"},
+		'/':  {Key: "Digit7", Shift: true},
+		'8':  {Key: "Digit8"},
+		'(':  {Key: "Digit8", Shift: true},
+		'9':  {Key: "Digit9"},
+		')':  {Key: "Digit9", Shift: true},
+		'0':  {Key: "Digit0"},
+		'=':  {Key: "Digit0", Shift: true},
+		'\'': {Key: "Minus"},
+		'?':  {Key: "Minus", Shift: true},
+		'¡':  {Key: "Equal", DeadKey: true},
+		'¿':  {Key: "Equal", Shift: true},
+		'[':  {Key: "BracketLeft", AltRight: true},
+		'+':  {Key: "BracketRight"},
+		'*':  {Key: "BracketRight", Shift: true},
+		']':  {Key: "BracketRight", AltRight: true},
+		'ñ':  {Key: "Semicolon"},
+		'Ñ':  {Key: "Semicolon", Shift: true},
+		'{':  {Key: "Quote", AltRight: true},
+		'ç':  {Key: "Backslash"},
+		'Ç':  {Key: "Backslash", Shift: true},
+		'}':  {Key: "Backslash", AltRight: true},
+		',':  {Key: "Comma"},
+		';':  {Key: "Comma", Shift: true},
+		'.':  {Key: "Period"},
+		':':  {Key: "Period", Shift: true},
+		'-':  {Key: "Slash"},
+		'_':  {Key: "Slash", Shift: true},
+		'<':  {Key: "IntlBackslash"},
+		'>':  {Key: "IntlBackslash", Shift: true},
+		' ':  {Key: "Space"},
+		'\n': {Key: "Enter"},
+	},
+}
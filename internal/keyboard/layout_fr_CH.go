@@ -0,0 +1,10 @@
+package keyboard
+
+var frCH = extendLayout(deCH, "fr_CH", "Français de Suisse", map[rune]KeyCombo{
+	'è': {Key: "BracketLeft"},
+	'ü': {Key: "BracketLeft", Shift: true},
+	'é': {Key: "Semicolon"},
+	'ö': {Key: "Semicolon", Shift: true},
+	'à': {Key: "Quote"},
+	'ä': {Key: "Quote", Shift: true},
+})
@@ -0,0 +1,96 @@
+package keyboard
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLayoutsUseKnownKeys(t *testing.T) {
+	for code, layout := range layouts {
+		assert.Equal(t, code, layout.Code)
+		for r, combo := range layout.Chars {
+			assert.Contains(t, keyCodes, combo.Key, "%s: %q", code, r)
+			if combo.AccentKey != nil {
+				assert.Contains(t, keyCodes, combo.AccentKey.Key, "%s: accent of %q", code, r)
+			}
+		}
+	}
+}
+
+func TestStrokes(t *testing.T) {
+	us, ok := Get("en_US")
+	require.True(t, ok)
+
+	strokes, ok := us.Strokes('a')
+	assert.True(t, ok)
+	assert.Equal(t, []Stroke{{Key: 0x04}}, strokes)
+
+	strokes, ok = us.Strokes('A')
+	assert.True(t, ok)
+	assert.Equal(t, []Stroke{{Modifier: ModifierLeftShift, Key: 0x04}}, strokes)
+
+	strokes, ok = us.Strokes('\t')
+	assert.True(t, ok)
+	assert.Equal(t, []Stroke{{Key: 0x2b}}, strokes)
+
+	_, ok = us.Strokes('é')
+	assert.False(t, ok)
+}
+
+func TestStrokesDeadAndAccentKeys(t *testing.T) {
+	de, ok := Get("de_DE")
+	require.True(t, ok)
+
+	// z and y are swapped on the german keyboards
+	strokes, _ := de.Strokes('z')
+	assert.Equal(t, []Stroke{{Key: 0x1c}}, strokes)
+
+	// the acute accent is typed first, then the letter
+	strokes, ok = de.Strokes('é')
+	assert.True(t, ok)
+	assert.Equal(t, []Stroke{{Key: 0x2e}, {Key: 0x08}}, strokes)
+
+	// a dead key alone is followed by a space
+	strokes, ok = de.Strokes('^')
+	assert.True(t, ok)
+	assert.Equal(t, []Stroke{{Key: 0x35}, {Key: 0x2c}}, strokes)
+
+	strokes, ok = de.Strokes('€')
+	assert.True(t, ok)
+	assert.Equal(t, []Stroke{{Modifier: ModifierRightAlt, Key: 0x08}}, strokes)
+}
+
+func TestExtendedLayout(t *testing.T) {
+	frCH, ok := Get("fr_CH")
+	require.True(t, ok)
+	deCH, ok := Get("de_CH")
+	require.True(t, ok)
+
+	assert.Equal(t, deCH.Chars['a'], frCH.Chars['a'])
+	assert.NotEqual(t, deCH.Chars['é'], frCH.Chars['é'])
+}
+
+func TestUnsupported(t *testing.T) {
+	us, _ := Get("en_US")
+	assert.Equal(t, []rune{'é', '€'}, us.Unsupported("café € é ok\n"))
+	assert.Empty(t, us.Unsupported("#cloud-config\nusers:\n\t- name: root\n"))
+}
+
+func TestKeyNames(t *testing.T) {
+	code, ok := KeyCode("KeyA")
+	assert.True(t, ok)
+	assert.Equal(t, uint8(0x04), code)
+
+	code, ok = KeyCode("ContextMenu")
+	assert.True(t, ok)
+	assert.Equal(t, uint8(0x65), code)
+
+	_, ok = KeyCode("ShiftLeft")
+	assert.False(t, ok)
+
+	bit, ok := ModifierBit("ShiftLeft")
+	assert.True(t, ok)
+	assert.Equal(t, ModifierLeftShift, bit)
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -16,6 +17,8 @@ type Logger struct {
 	scopeLoggers    map[string]*zerolog.Logger
 	scopeLevels     map[string]zerolog.Level
 	scopeLevelMutex sync.Mutex
+	// scopeLevelsFromConfig are set at runtime, they take precedence over the environment
+	scopeLevelsFromConfig map[string]zerolog.Level
 
 	defaultLogLevelFromEnv    zerolog.Level
 	defaultLogLevelFromConfig zerolog.Level
@@ -26,21 +29,36 @@ const (
 	defaultLogLevel = zerolog.ErrorLevel
 )
 
+// ScopeLevel is the current log level of a scope, Source is where the level comes
+// from: "default", "env" or "config".
+type ScopeLevel struct {
+	Scope  string `json:"scope"`
+	Level  string `json:"level"`
+	Source string `json:"source"`
+}
+
 type logOutput struct {
 	mu *sync.Mutex
 }
 
 func (w *logOutput) Write(p []byte) (n int, err error) {
+	return w.WriteLevel(zerolog.NoLevel, p)
+}
+
+// WriteLevel is called by zerolog.MultiLevelWriter, the level lets each sink apply its own filter.
+func (w *logOutput) WriteLevel(level zerolog.Level, p []byte) (n int, err error) {
 	w.mu.Lock()
 	defer w.mu.Unlock()
 
-	// TODO: write to file or syslog
 	if sseServer != nil {
 		// use a goroutine to avoid blocking the Write method
+		msg := string(p)
 		go func() {
-			sseServer.Message <- string(p)
+			sseServer.Message <- msg
 		}()
 	}
+
+	writeToSinks(level, p)
 	return len(p), nil
 }
 
@@ -82,6 +100,7 @@ func NewLogger(zerologLogger zerolog.Logger) *Logger {
 		scopeLoggers:              make(map[string]*zerolog.Logger),
 		scopeLevels:               make(map[string]zerolog.Level),
 		scopeLevelMutex:           sync.Mutex{},
+		scopeLevelsFromConfig:     make(map[string]zerolog.Level),
 		defaultLogLevelFromEnv:    -2,
 		defaultLogLevelFromConfig: -2,
 		defaultLogLevel:           defaultLogLevel,
@@ -148,9 +167,108 @@ func (l *Logger) getScopeLoggerLevel(scope string) zerolog.Level {
 		scopeLevel = level
 	}
 
+	if level, ok := l.scopeLevelsFromConfig[scope]; ok {
+		scopeLevel = level
+	}
+
 	return scopeLevel
 }
 
+func levelName(level zerolog.Level) string {
+	for name, l := range zerologLevels {
+		if l == level {
+			return name
+		}
+	}
+	return level.String()
+}
+
+// refreshScopeLoggersLocked applies the level changes to the existing scope loggers,
+// scopeLevelMutex must be held.
+func (l *Logger) refreshScopeLoggersLocked() {
+	for scope, logger := range l.scopeLoggers {
+		if logger.GetLevel() != l.getScopeLoggerLevel(scope) {
+			*logger = l.newScopeLogger(scope)
+		}
+	}
+}
+
+// GetScopeLevels returns the level of every scope that has a logger, sorted by scope.
+func (l *Logger) GetScopeLevels() []ScopeLevel {
+	l.scopeLevelMutex.Lock()
+	defer l.scopeLevelMutex.Unlock()
+
+	scopes := make([]string, 0, len(l.scopeLoggers))
+	for scope := range l.scopeLoggers {
+		scopes = append(scopes, scope)
+	}
+	sort.Strings(scopes)
+
+	levels := make([]ScopeLevel, 0, len(scopes))
+	for _, scope := range scopes {
+		source := "default"
+		if _, ok := l.scopeLevelsFromConfig[scope]; ok {
+			source = "config"
+		} else if _, ok := l.scopeLevels[scope]; ok {
+			source = "env"
+		}
+
+		levels = append(levels, ScopeLevel{
+			Scope:  scope,
+			Level:  levelName(l.getScopeLoggerLevel(scope)),
+			Source: source,
+		})
+	}
+	return levels
+}
+
+// HasScope reports whether a logger was created for the scope.
+func (l *Logger) HasScope(scope string) bool {
+	l.scopeLevelMutex.Lock()
+	defer l.scopeLevelMutex.Unlock()
+
+	_, ok := l.scopeLoggers[scope]
+	return ok
+}
+
+// SetScopeLevel sets the level of a scope at runtime, an empty level goes back to
+// the level from the environment or the default one.
+func (l *Logger) SetScopeLevel(scope string, level string) error {
+	l.scopeLevelMutex.Lock()
+	defer l.scopeLevelMutex.Unlock()
+
+	if level == "" {
+		delete(l.scopeLevelsFromConfig, scope)
+	} else {
+		logLevel, ok := zerologLevels[strings.ToUpper(level)]
+		if !ok {
+			return fmt.Errorf("invalid log level %q", level)
+		}
+		l.scopeLevelsFromConfig[scope] = logLevel
+	}
+
+	l.refreshScopeLoggersLocked()
+	return nil
+}
+
+// UpdateScopeLevels replaces the per-scope levels with the ones from the config.
+func (l *Logger) UpdateScopeLevels(levels map[string]string) {
+	l.scopeLevelMutex.Lock()
+	defer l.scopeLevelMutex.Unlock()
+
+	l.scopeLevelsFromConfig = make(map[string]zerolog.Level, len(levels))
+	for scope, level := range levels {
+		logLevel, ok := zerologLevels[strings.ToUpper(level)]
+		if !ok {
+			l.l.Warn().Str("scope", scope).Str("logLevel", level).Msg("invalid scope log level from config, ignoring it")
+			continue
+		}
+		l.scopeLevelsFromConfig[scope] = logLevel
+	}
+
+	l.refreshScopeLoggersLocked()
+}
+
 func (l *Logger) newScopeLogger(scope string) zerolog.Logger {
 	scopeLevel := l.getScopeLoggerLevel(scope)
 	logger := l.l.Level(scopeLevel).With().Str("component", scope).Logger()
@@ -159,6 +277,9 @@ func (l *Logger) newScopeLogger(scope string) zerolog.Logger {
 }
 
 func (l *Logger) getLogger(scope string) *zerolog.Logger {
+	l.scopeLevelMutex.Lock()
+	defer l.scopeLevelMutex.Unlock()
+
 	logger, ok := l.scopeLoggers[scope]
 	if !ok || logger == nil {
 		scopeLogger := l.newScopeLogger(scope)
@@ -186,12 +307,8 @@ func (l *Logger) UpdateLogLevel(configDefaultLogLevel string) {
 	l.updateLogLevel()
 
 	if needUpdate {
-		for scope, logger := range l.scopeLoggers {
-			currentLevel := logger.GetLevel()
-			targetLevel := l.getScopeLoggerLevel(scope)
-			if currentLevel != targetLevel {
-				*logger = l.newScopeLogger(scope)
-			}
-		}
+		l.scopeLevelMutex.Lock()
+		l.refreshScopeLoggersLocked()
+		l.scopeLevelMutex.Unlock()
 	}
 }
@@ -0,0 +1,39 @@
+package logging
+
+import (
+	"io"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScopeLevels(t *testing.T) {
+	root := zerolog.New(io.Discard)
+	l := NewLogger(root)
+	l.UpdateLogLevel("INFO")
+
+	nbd := l.getLogger("nbd")
+	l.getLogger("jsonrpc")
+	assert.Equal(t, zerolog.InfoLevel, nbd.GetLevel())
+	assert.True(t, l.HasScope("nbd"))
+	assert.False(t, l.HasScope("missing"))
+
+	require.NoError(t, l.SetScopeLevel("nbd", "trace"))
+	assert.Equal(t, zerolog.TraceLevel, nbd.GetLevel(), "existing loggers follow the new level")
+
+	levels := l.GetScopeLevels()
+	require.Len(t, levels, 2)
+	assert.Equal(t, ScopeLevel{Scope: "jsonrpc", Level: "INFO", Source: "default"}, levels[0])
+	assert.Equal(t, ScopeLevel{Scope: "nbd", Level: "TRACE", Source: "config"}, levels[1])
+
+	assert.Error(t, l.SetScopeLevel("nbd", "loud"))
+
+	require.NoError(t, l.SetScopeLevel("nbd", ""))
+	assert.Equal(t, zerolog.InfoLevel, nbd.GetLevel())
+
+	l.UpdateScopeLevels(map[string]string{"jsonrpc": "DEBUG", "nbd": "invalid"})
+	assert.Equal(t, zerolog.DebugLevel, l.getLogger("jsonrpc").GetLevel())
+	assert.Equal(t, zerolog.InfoLevel, nbd.GetLevel())
+}
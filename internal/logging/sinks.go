@@ -0,0 +1,442 @@
+package logging
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+const (
+	defaultFileSinkMaxSizeKB = 1024
+	defaultFileSinkMaxFiles  = 3
+	maxFileSinkMaxSizeKB     = 16 * 1024
+	maxFileSinkMaxFiles      = 10
+
+	// syslogFacility is the "daemon" facility
+	syslogFacility    = 3
+	syslogAppName     = "jetkvm"
+	syslogQueueSize   = 1024
+	syslogDialTimeout = 5 * time.Second
+	syslogRetryMin    = 1 * time.Second
+	syslogRetryMax    = 1 * time.Minute
+)
+
+// FileSinkConfig writes the logs as JSON lines to a file that is rotated once it
+// reaches MaxSizeKB, MaxFiles files are kept including the current one.
+type FileSinkConfig struct {
+	Enabled   bool   `json:"enabled"`
+	Level     string `json:"level"`
+	MaxSizeKB int    `json:"max_size_kb"`
+	MaxFiles  int    `json:"max_files"`
+}
+
+// SyslogSinkConfig sends the logs to a remote syslog server with the RFC 5424 format.
+type SyslogSinkConfig struct {
+	Enabled bool   `json:"enabled"`
+	Level   string `json:"level"`
+	// Protocol is one of "udp", "tcp" or "tls"
+	Protocol string `json:"protocol"`
+	// Address is the host:port of the server
+	Address string `json:"address"`
+	// CACertificate is a PEM bundle to verify a server using a private CA, for "tls" only
+	CACertificate string `json:"ca_certificate,omitempty"`
+}
+
+type SinksConfig struct {
+	File   *FileSinkConfig   `json:"file,omitempty"`
+	Syslog *SyslogSinkConfig `json:"syslog,omitempty"`
+}
+
+type sink interface {
+	writeLevel(level zerolog.Level, p []byte)
+	close()
+}
+
+var (
+	sinks      []sink
+	sinksMutex sync.RWMutex
+)
+
+func writeToSinks(level zerolog.Level, p []byte) {
+	sinksMutex.RLock()
+	defer sinksMutex.RUnlock()
+
+	for _, s := range sinks {
+		s.writeLevel(level, p)
+	}
+}
+
+func parseSinkLevel(level string) (zerolog.Level, error) {
+	if level == "" {
+		return zerolog.InfoLevel, nil
+	}
+
+	parsed, ok := zerologLevels[strings.ToUpper(level)]
+	if !ok {
+		return zerolog.NoLevel, fmt.Errorf("invalid log level %q", level)
+	}
+	return parsed, nil
+}
+
+// ValidateSinks checks the sink config without applying it.
+func ValidateSinks(cfg SinksConfig) error {
+	if cfg.File != nil {
+		if _, err := parseSinkLevel(cfg.File.Level); err != nil {
+			return fmt.Errorf("file sink: %w", err)
+		}
+		if cfg.File.MaxSizeKB < 0 || cfg.File.MaxSizeKB > maxFileSinkMaxSizeKB {
+			return fmt.Errorf("file sink: max size must be between 0 and %d KB", maxFileSinkMaxSizeKB)
+		}
+		if cfg.File.MaxFiles < 0 || cfg.File.MaxFiles > maxFileSinkMaxFiles {
+			return fmt.Errorf("file sink: max files must be between 0 and %d", maxFileSinkMaxFiles)
+		}
+	}
+
+	if cfg.Syslog != nil {
+		if _, err := parseSinkLevel(cfg.Syslog.Level); err != nil {
+			return fmt.Errorf("syslog sink: %w", err)
+		}
+		switch cfg.Syslog.Protocol {
+		case "udp", "tcp", "tls":
+		default:
+			return fmt.Errorf("syslog sink: invalid protocol %q", cfg.Syslog.Protocol)
+		}
+		if cfg.Syslog.Enabled {
+			if _, _, err := net.SplitHostPort(cfg.Syslog.Address); err != nil {
+				return fmt.Errorf("syslog sink: invalid address: %w", err)
+			}
+		}
+		if cfg.Syslog.CACertificate != "" && !x509.NewCertPool().AppendCertsFromPEM([]byte(cfg.Syslog.CACertificate)) {
+			return errors.New("syslog sink: no valid certificate found in the CA certificate")
+		}
+	}
+
+	return nil
+}
+
+// ConfigureSinks replaces the active sinks, the file sink writes to filePath.
+func ConfigureSinks(cfg SinksConfig, filePath string) error {
+	if err := ValidateSinks(cfg); err != nil {
+		return err
+	}
+
+	newSinks := make([]sink, 0, 2)
+
+	if cfg.File != nil && cfg.File.Enabled {
+		fileSink, err := newFileSink(*cfg.File, filePath)
+		if err != nil {
+			return err
+		}
+		newSinks = append(newSinks, fileSink)
+	}
+
+	if cfg.Syslog != nil && cfg.Syslog.Enabled {
+		newSinks = append(newSinks, newSyslogSink(*cfg.Syslog))
+	}
+
+	sinksMutex.Lock()
+	oldSinks := sinks
+	sinks = newSinks
+	sinksMutex.Unlock()
+
+	for _, s := range oldSinks {
+		s.close()
+	}
+
+	return nil
+}
+
+type fileSink struct {
+	mu       sync.Mutex
+	level    zerolog.Level
+	path     string
+	maxSize  int64
+	maxFiles int
+	file     *os.File
+	size     int64
+}
+
+func newFileSink(cfg FileSinkConfig, path string) (*fileSink, error) {
+	level, _ := parseSinkLevel(cfg.Level)
+
+	s := &fileSink{
+		level:    level,
+		path:     path,
+		maxSize:  int64(cfg.MaxSizeKB) * 1024,
+		maxFiles: cfg.MaxFiles,
+	}
+	if s.maxSize == 0 {
+		s.maxSize = defaultFileSinkMaxSizeKB * 1024
+	}
+	if s.maxFiles == 0 {
+		s.maxFiles = defaultFileSinkMaxFiles
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create log directory: %w", err)
+	}
+	if err := s.open(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *fileSink) open() error {
+	file, err := os.OpenFile(s.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open log file: %w", err)
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("failed to stat log file: %w", err)
+	}
+
+	s.file = file
+	s.size = info.Size()
+	return nil
+}
+
+// rotatedPath returns the path of the nth rotated file, e.g. jetkvm.log.1
+func (s *fileSink) rotatedPath(n int) string {
+	return s.path + "." + strconv.Itoa(n)
+}
+
+func (s *fileSink) rotate() error {
+	s.file.Close()
+	s.file = nil
+
+	_ = os.Remove(s.rotatedPath(s.maxFiles - 1))
+	for n := s.maxFiles - 2; n >= 1; n-- {
+		_ = os.Rename(s.rotatedPath(n), s.rotatedPath(n+1))
+	}
+
+	if s.maxFiles > 1 {
+		if err := os.Rename(s.path, s.rotatedPath(1)); err != nil {
+			return err
+		}
+	} else if err := os.Remove(s.path); err != nil {
+		return err
+	}
+
+	return s.open()
+}
+
+func (s *fileSink) writeLevel(level zerolog.Level, p []byte) {
+	if level < s.level {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.file == nil {
+		return
+	}
+
+	if s.size > 0 && s.size+int64(len(p)) > s.maxSize {
+		if err := s.rotate(); err != nil {
+			defaultLogger.Warn().Err(err).Str("path", s.path).Msg("failed to rotate log file")
+			if s.file == nil {
+				return
+			}
+		}
+	}
+
+	n, err := s.file.Write(p)
+	s.size += int64(n)
+	if err != nil {
+		defaultLogger.Warn().Err(err).Str("path", s.path).Msg("failed to write log file")
+	}
+}
+
+func (s *fileSink) close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.file != nil {
+		s.file.Close()
+		s.file = nil
+	}
+}
+
+// syslogSink sends the messages from a goroutine, so that a slow or unreachable
+// server never blocks the logging. Messages are dropped when the queue is full.
+type syslogSink struct {
+	level    zerolog.Level
+	cfg      SyslogSinkConfig
+	hostname string
+	queue    chan []byte
+	done     chan struct{}
+	wg       sync.WaitGroup
+}
+
+func newSyslogSink(cfg SyslogSinkConfig) *syslogSink {
+	level, _ := parseSinkLevel(cfg.Level)
+
+	hostname, err := os.Hostname()
+	if err != nil || hostname == "" {
+		hostname = "-"
+	}
+
+	s := &syslogSink{
+		level:    level,
+		cfg:      cfg,
+		hostname: hostname,
+		queue:    make(chan []byte, syslogQueueSize),
+		done:     make(chan struct{}),
+	}
+
+	s.wg.Add(1)
+	go s.run()
+
+	return s
+}
+
+func syslogSeverity(level zerolog.Level) int {
+	switch level {
+	case zerolog.PanicLevel:
+		return 0 // emergency
+	case zerolog.FatalLevel:
+		return 2 // critical
+	case zerolog.ErrorLevel:
+		return 3 // error
+	case zerolog.WarnLevel:
+		return 4 // warning
+	case zerolog.InfoLevel, zerolog.NoLevel:
+		return 6 // informational
+	}
+	return 7 // debug
+}
+
+// formatRFC5424 builds a RFC 5424 message from a zerolog JSON line, the component
+// becomes the MSGID and the JSON line is sent as the MSG.
+func formatRFC5424(level zerolog.Level, p []byte, hostname string, now time.Time) []byte {
+	var fields struct {
+		Component string `json:"component"`
+		Scope     string `json:"scope"`
+	}
+	_ = json.Unmarshal(p, &fields)
+
+	msgID := fields.Component
+	if msgID == "" {
+		msgID = fields.Scope
+	}
+	if msgID == "" {
+		msgID = "-"
+	}
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "<%d>1 %s %s %s %d %s - ",
+		syslogFacility*8+syslogSeverity(level),
+		now.UTC().Format("2006-01-02T15:04:05.000000Z07:00"),
+		hostname,
+		syslogAppName,
+		os.Getpid(),
+		msgID,
+	)
+	b.Write(bytes.TrimRight(p, "\n"))
+	return b.Bytes()
+}
+
+func (s *syslogSink) writeLevel(level zerolog.Level, p []byte) {
+	if level < s.level {
+		return
+	}
+
+	msg := formatRFC5424(level, p, s.hostname, time.Now())
+	select {
+	case s.queue <- msg:
+	default:
+		// the server can't keep up, drop the message
+	}
+}
+
+func (s *syslogSink) dial() (net.Conn, error) {
+	switch s.cfg.Protocol {
+	case "tls":
+		tlsConfig := &tls.Config{}
+		if s.cfg.CACertificate != "" {
+			pool := x509.NewCertPool()
+			pool.AppendCertsFromPEM([]byte(s.cfg.CACertificate))
+			tlsConfig.RootCAs = pool
+		}
+		if host, _, err := net.SplitHostPort(s.cfg.Address); err == nil {
+			tlsConfig.ServerName = host
+		}
+		dialer := &net.Dialer{Timeout: syslogDialTimeout}
+		return tls.DialWithDialer(dialer, "tcp", s.cfg.Address, tlsConfig)
+	default:
+		return net.DialTimeout(s.cfg.Protocol, s.cfg.Address, syslogDialTimeout)
+	}
+}
+
+// frame adds the RFC 6587 octet counting framing of the stream transports.
+func (s *syslogSink) frame(msg []byte) []byte {
+	if s.cfg.Protocol == "udp" {
+		return msg
+	}
+	return append([]byte(strconv.Itoa(len(msg))+" "), msg...)
+}
+
+func (s *syslogSink) run() {
+	defer s.wg.Done()
+
+	var conn net.Conn
+	retryDelay := syslogRetryMin
+	defer func() {
+		if conn != nil {
+			conn.Close()
+		}
+	}()
+
+	for {
+		var msg []byte
+		select {
+		case <-s.done:
+			return
+		case msg = <-s.queue:
+		}
+
+		for conn == nil {
+			var err error
+			conn, err = s.dial()
+			if err == nil {
+				retryDelay = syslogRetryMin
+				break
+			}
+
+			defaultLogger.Warn().Err(err).Str("address", s.cfg.Address).Msg("failed to connect to syslog server")
+			select {
+			case <-s.done:
+				return
+			case <-time.After(retryDelay):
+			}
+			retryDelay = min(retryDelay*2, syslogRetryMax)
+		}
+
+		if _, err := conn.Write(s.frame(msg)); err != nil {
+			defaultLogger.Warn().Err(err).Str("address", s.cfg.Address).Msg("failed to send to syslog server")
+			conn.Close()
+			conn = nil
+		}
+	}
+}
+
+func (s *syslogSink) close() {
+	close(s.done)
+	s.wg.Wait()
+}
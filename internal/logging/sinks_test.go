@@ -0,0 +1,73 @@
+package logging
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormatRFC5424(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 30, 45, 123456000, time.UTC)
+	line := []byte(`{"level":"warn","scope":"jetkvm","component":"usbgadget","message":"hello"}` + "\n")
+
+	msg := string(formatRFC5424(zerolog.WarnLevel, line, "kvm", now))
+
+	// daemon facility (3) * 8 + warning (4)
+	assert.True(t, strings.HasPrefix(msg, "<28>1 2025-06-01T12:30:45.123456Z kvm jetkvm "), msg)
+	assert.Contains(t, msg, " usbgadget - {")
+	assert.False(t, strings.HasSuffix(msg, "\n"))
+}
+
+func TestFormatRFC5424WithoutComponent(t *testing.T) {
+	msg := string(formatRFC5424(zerolog.ErrorLevel, []byte(`{"message":"hello"}`), "kvm", time.Now()))
+
+	assert.True(t, strings.HasPrefix(msg, "<27>1 "), msg)
+	assert.Contains(t, msg, " - - {")
+}
+
+func TestSyslogFraming(t *testing.T) {
+	udp := &syslogSink{cfg: SyslogSinkConfig{Protocol: "udp"}}
+	tcp := &syslogSink{cfg: SyslogSinkConfig{Protocol: "tcp"}}
+
+	assert.Equal(t, "<14>1 msg", string(udp.frame([]byte("<14>1 msg"))))
+	assert.Equal(t, "9 <14>1 msg", string(tcp.frame([]byte("<14>1 msg"))))
+}
+
+func TestFileSinkRotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logs", "jetkvm.log")
+
+	s, err := newFileSink(FileSinkConfig{Enabled: true, Level: "INFO", MaxSizeKB: 1, MaxFiles: 3}, path)
+	require.NoError(t, err)
+	defer s.close()
+
+	line := []byte(strings.Repeat("x", 299) + "\n")
+	for i := 0; i < 12; i++ {
+		s.writeLevel(zerolog.InfoLevel, line)
+	}
+	// filtered by the level of the sink
+	s.writeLevel(zerolog.DebugLevel, line)
+
+	for _, p := range []string{path, path + ".1", path + ".2"} {
+		info, err := os.Stat(p)
+		require.NoError(t, err, p)
+		assert.LessOrEqual(t, info.Size(), int64(1024), p)
+	}
+	_, err = os.Stat(path + ".3")
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestValidateSinks(t *testing.T) {
+	assert.NoError(t, ValidateSinks(SinksConfig{
+		File:   &FileSinkConfig{Enabled: true, Level: "debug"},
+		Syslog: &SyslogSinkConfig{Enabled: true, Protocol: "tls", Address: "logs.example.com:6514"},
+	}))
+	assert.Error(t, ValidateSinks(SinksConfig{File: &FileSinkConfig{Level: "LOUD"}}))
+	assert.Error(t, ValidateSinks(SinksConfig{Syslog: &SyslogSinkConfig{Enabled: true, Protocol: "sctp", Address: "a:1"}}))
+	assert.Error(t, ValidateSinks(SinksConfig{Syslog: &SyslogSinkConfig{Enabled: true, Protocol: "udp", Address: "nope"}}))
+}
@@ -0,0 +1,187 @@
+// Package macros defines the keyboard macros saved in the config, and their export
+// and import format.
+package macros
+
+import (
+	"fmt"
+	"unicode/utf8"
+)
+
+// Constants for keyboard macro limits
+const (
+	MaxMacrosPerDevice = 100
+	MaxStepsPerMacro   = 200
+	MaxKeysPerStep     = 10
+	MinStepDelay       = 50
+	MaxStepDelay       = 30000
+	// MaxMacroTextLength is in characters, for the text steps
+	MaxMacroTextLength = 4096
+	MaxRepeatCount     = 100
+	// MaxRepeatDepth is how many repeat steps can be nested
+	MaxRepeatDepth = 3
+	// MaxMacroRunSteps caps the steps run by a macro, once the repeats are unrolled
+	MaxMacroRunSteps    = 10000
+	MaxWaitVideoTimeout = 300000
+)
+
+// StepType is what a step of a macro does, the steps of the macros saved before
+// the types were added have none and press keys.
+type StepType string
+
+const (
+	StepKeys      StepType = "keys"
+	StepText      StepType = "text"
+	StepAbsMouse  StepType = "absMouse"
+	StepRelMouse  StepType = "relMouse"
+	StepATX       StepType = "atx"
+	StepWaitVideo StepType = "waitVideo"
+	StepRepeat    StepType = "repeat"
+)
+
+type KeyboardMacroStep struct {
+	Type      StepType `json:"type,omitempty"`
+	Keys      []string `json:"keys"`
+	Modifiers []string `json:"modifiers"`
+	// Delay is how long the keys are held for the keys steps, and the pause after the
+	// step for the others
+	Delay int `json:"delay"`
+
+	// Text is typed with the keyboard layout of the device
+	Text string `json:"text,omitempty"`
+	// X and Y are the position for absMouse, from 0 to 32767, and the move for relMouse,
+	// from -127 to 127
+	X       int   `json:"x,omitempty"`
+	Y       int   `json:"y,omitempty"`
+	Buttons uint8 `json:"buttons,omitempty"`
+	// Action is the ATX action: power-short, power-long or reset
+	Action string `json:"action,omitempty"`
+	// Timeout is how long waitVideo waits for a video signal, in milliseconds
+	Timeout int `json:"timeout,omitempty"`
+	// Count is how many times repeat runs its Steps
+	Count int                 `json:"count,omitempty"`
+	Steps []KeyboardMacroStep `json:"steps,omitempty"`
+}
+
+// StepType returns the type of the step, keys for the steps without one.
+func (s *KeyboardMacroStep) StepType() StepType {
+	if s.Type == "" {
+		return StepKeys
+	}
+	return s.Type
+}
+
+func (s *KeyboardMacroStep) Validate() error {
+	return s.validate(0)
+}
+
+func (s *KeyboardMacroStep) validate(depth int) error {
+	if s.StepType() == StepKeys {
+		if len(s.Keys) > MaxKeysPerStep {
+			return fmt.Errorf("too many keys in step (max %d)", MaxKeysPerStep)
+		}
+
+		if s.Delay < MinStepDelay {
+			s.Delay = MinStepDelay
+		} else if s.Delay > MaxStepDelay {
+			s.Delay = MaxStepDelay
+		}
+		return nil
+	}
+
+	if s.Delay < 0 || s.Delay > MaxStepDelay {
+		return fmt.Errorf("delay must be between 0 and %d ms", MaxStepDelay)
+	}
+
+	switch s.Type {
+	case StepText:
+		if s.Text == "" {
+			return fmt.Errorf("text step cannot be empty")
+		}
+		if utf8.RuneCountInString(s.Text) > MaxMacroTextLength {
+			return fmt.Errorf("text too long (max %d characters)", MaxMacroTextLength)
+		}
+	case StepAbsMouse:
+		if s.X < 0 || s.X > 32767 || s.Y < 0 || s.Y > 32767 {
+			return fmt.Errorf("mouse position must be between 0 and 32767")
+		}
+	case StepRelMouse:
+		if s.X < -127 || s.X > 127 || s.Y < -127 || s.Y > 127 {
+			return fmt.Errorf("mouse move must be between -127 and 127")
+		}
+	case StepATX:
+		if s.Action != "power-short" && s.Action != "power-long" && s.Action != "reset" {
+			return fmt.Errorf("invalid ATX action %q", s.Action)
+		}
+	case StepWaitVideo:
+		if s.Timeout <= 0 || s.Timeout > MaxWaitVideoTimeout {
+			return fmt.Errorf("timeout must be between 1 and %d ms", MaxWaitVideoTimeout)
+		}
+	case StepRepeat:
+		if depth >= MaxRepeatDepth {
+			return fmt.Errorf("too many nested repeats (max %d)", MaxRepeatDepth)
+		}
+		if s.Count < 1 || s.Count > MaxRepeatCount {
+			return fmt.Errorf("repeat count must be between 1 and %d", MaxRepeatCount)
+		}
+		if len(s.Steps) == 0 {
+			return fmt.Errorf("repeat must have at least one step")
+		}
+		if len(s.Steps) > MaxStepsPerMacro {
+			return fmt.Errorf("too many steps in repeat (max %d)", MaxStepsPerMacro)
+		}
+		for i := range s.Steps {
+			if err := s.Steps[i].validate(depth + 1); err != nil {
+				return fmt.Errorf("invalid repeated step %d: %w", i+1, err)
+			}
+		}
+	default:
+		return fmt.Errorf("unknown step type %q", s.Type)
+	}
+	return nil
+}
+
+// CountSteps returns how many steps run, with the repeats unrolled.
+func CountSteps(steps []KeyboardMacroStep) int {
+	count := 0
+	for _, step := range steps {
+		if step.StepType() == StepRepeat {
+			count += step.Count * CountSteps(step.Steps)
+			continue
+		}
+		count++
+	}
+	return count
+}
+
+type KeyboardMacro struct {
+	ID        string              `json:"id"`
+	Name      string              `json:"name"`
+	Steps     []KeyboardMacroStep `json:"steps"`
+	SortOrder int                 `json:"sortOrder,omitempty"`
+}
+
+func (m *KeyboardMacro) Validate() error {
+	if m.Name == "" {
+		return fmt.Errorf("macro name cannot be empty")
+	}
+
+	if len(m.Steps) == 0 {
+		return fmt.Errorf("macro must have at least one step")
+	}
+
+	if len(m.Steps) > MaxStepsPerMacro {
+		return fmt.Errorf("too many steps in macro (max %d)", MaxStepsPerMacro)
+	}
+
+	for i := range m.Steps {
+		if err := m.Steps[i].Validate(); err != nil {
+			return fmt.Errorf("invalid step %d: %w", i+1, err)
+		}
+	}
+
+	if CountSteps(m.Steps) > MaxMacroRunSteps {
+		return fmt.Errorf("macro runs too many steps once repeated (max %d)", MaxMacroRunSteps)
+	}
+
+	return nil
+}
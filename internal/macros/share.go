@@ -0,0 +1,222 @@
+package macros
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// The macros are shared between devices as a JSON document:
+//
+//	{
+//	  "format": "jetkvm-keyboard-macros",
+//	  "version": 1,
+//	  "exportedAt": "2025-01-01T00:00:00Z",
+//	  "deviceId": "...",
+//	  "appVersion": "...",
+//	  "macros": [{"id": "...", "name": "...", "steps": [...], "sortOrder": 1}]
+//	}
+//
+// The version is bumped when a change to KeyboardMacro can't be read by the older
+// versions.
+
+const (
+	ExportFormat        = "jetkvm-keyboard-macros"
+	ExportFormatVersion = 1
+)
+
+const (
+	// ImportMerge adds the macros to the ones of the device
+	ImportMerge = "merge"
+	// ImportReplace removes the macros of the device first
+	ImportReplace = "replace"
+)
+
+const (
+	// ConflictSkip keeps the macro of the device
+	ConflictSkip = "skip"
+	// ConflictOverwrite replaces the macro of the device
+	ConflictOverwrite = "overwrite"
+	// ConflictRename imports the macro with a new id and name
+	ConflictRename = "rename"
+)
+
+const (
+	StatusImported    = "imported"
+	StatusOverwritten = "overwritten"
+	StatusRenamed     = "renamed"
+	StatusSkipped     = "skipped"
+	StatusInvalid     = "invalid"
+)
+
+type KeyboardMacrosExport struct {
+	Format     string          `json:"format"`
+	Version    int             `json:"version"`
+	ExportedAt time.Time       `json:"exportedAt"`
+	DeviceID   string          `json:"deviceId,omitempty"`
+	AppVersion string          `json:"appVersion,omitempty"`
+	Macros     []KeyboardMacro `json:"macros"`
+}
+
+// NewExport returns the export of the macros, they are copied.
+func NewExport(macros []KeyboardMacro, deviceID, appVersion string) KeyboardMacrosExport {
+	return KeyboardMacrosExport{
+		Format:     ExportFormat,
+		Version:    ExportFormatVersion,
+		ExportedAt: time.Now().UTC(),
+		DeviceID:   deviceID,
+		AppVersion: appVersion,
+		Macros:     slices.Clone(macros),
+	}
+}
+
+type ImportKeyboardMacrosParams struct {
+	Data KeyboardMacrosExport `json:"data"`
+	// Mode is merge or replace, merge when empty
+	Mode string `json:"mode,omitempty"`
+	// OnConflict is what merge does with a macro having the id or the name of one of
+	// the device: skip, overwrite or rename, skip when empty
+	OnConflict string `json:"onConflict,omitempty"`
+	// DryRun checks the import without saving it
+	DryRun bool `json:"dryRun,omitempty"`
+}
+
+// MacroImportItem is the outcome of the import of a macro of the document.
+type MacroImportItem struct {
+	Index  int    `json:"index"`
+	ID     string `json:"id"`
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	// Conflict is set when the macro has the id or the name of a macro of the device, or
+	// of a macro imported before it
+	Conflict string `json:"conflict,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+type MacroImportResult struct {
+	// Applied is false when a macro is invalid, nothing is imported then, or for a dry run
+	Applied bool              `json:"applied"`
+	Macros  []MacroImportItem `json:"macros"`
+}
+
+// Import applies the import to the macros of the device, which are left untouched.
+// Every macro is validated first, the import is all or nothing: the macros to save
+// are only returned when every imported macro is valid and it isn't a dry run.
+func Import(current []KeyboardMacro, params ImportKeyboardMacrosParams) ([]KeyboardMacro, *MacroImportResult, error) {
+	if params.Data.Format != ExportFormat {
+		return nil, nil, fmt.Errorf("not a keyboard macros export, the format is %q", params.Data.Format)
+	}
+	if params.Data.Version < 1 || params.Data.Version > ExportFormatVersion {
+		return nil, nil, fmt.Errorf("unsupported keyboard macros export version %d (max %d)", params.Data.Version, ExportFormatVersion)
+	}
+
+	if len(params.Data.Macros) == 0 {
+		return nil, nil, errors.New("no macros to import")
+	}
+
+	mode := params.Mode
+	if mode == "" {
+		mode = ImportMerge
+	}
+	if mode != ImportMerge && mode != ImportReplace {
+		return nil, nil, fmt.Errorf("invalid import mode %q", mode)
+	}
+	onConflict := params.OnConflict
+	if onConflict == "" {
+		onConflict = ConflictSkip
+	}
+	if onConflict != ConflictSkip && onConflict != ConflictOverwrite && onConflict != ConflictRename {
+		return nil, nil, fmt.Errorf("invalid conflict resolution %q", onConflict)
+	}
+
+	var macros []KeyboardMacro
+	if mode == ImportMerge {
+		macros = slices.Clone(current)
+	}
+	nextSortOrder := 1
+	for _, macro := range macros {
+		nextSortOrder = max(nextSortOrder, macro.SortOrder+1)
+	}
+
+	result := &MacroImportResult{Macros: make([]MacroImportItem, 0, len(params.Data.Macros))}
+	valid := true
+	seenIDs := map[string]bool{}
+	for i, macro := range params.Data.Macros {
+		item := MacroImportItem{Index: i, ID: macro.ID, Name: macro.Name}
+
+		if err := macro.Validate(); err != nil {
+			item.Status = StatusInvalid
+			item.Error = err.Error()
+			valid = false
+			result.Macros = append(result.Macros, item)
+			continue
+		}
+		if macro.ID == "" || seenIDs[macro.ID] {
+			macro.ID = uuid.New().String()
+		}
+		seenIDs[macro.ID] = true
+
+		idIdx := slices.IndexFunc(macros, func(m KeyboardMacro) bool { return m.ID == macro.ID })
+		nameIdx := slices.IndexFunc(macros, func(m KeyboardMacro) bool { return m.Name == macro.Name })
+		conflictIdx := idIdx
+		switch {
+		case idIdx != -1:
+			item.Conflict = "id"
+		case nameIdx != -1:
+			item.Conflict = "name"
+			conflictIdx = nameIdx
+		}
+
+		switch {
+		case conflictIdx == -1:
+			macro.SortOrder = nextSortOrder
+			nextSortOrder++
+			macros = append(macros, macro)
+			item.Status = StatusImported
+		case onConflict == ConflictSkip:
+			item.Status = StatusSkipped
+		case onConflict == ConflictOverwrite:
+			macro.ID = macros[conflictIdx].ID
+			macro.SortOrder = macros[conflictIdx].SortOrder
+			macros[conflictIdx] = macro
+			item.Status = StatusOverwritten
+		default:
+			macro.ID = uuid.New().String()
+			macro.Name = uniqueName(macros, macro.Name)
+			macro.SortOrder = nextSortOrder
+			nextSortOrder++
+			macros = append(macros, macro)
+			item.Status = StatusRenamed
+		}
+
+		item.ID = macro.ID
+		item.Name = macro.Name
+		result.Macros = append(result.Macros, item)
+	}
+
+	if !valid {
+		return nil, result, nil
+	}
+	if len(macros) > MaxMacrosPerDevice {
+		return nil, nil, fmt.Errorf("the import would make %d macros (max %d)", len(macros), MaxMacrosPerDevice)
+	}
+	if params.DryRun {
+		return nil, result, nil
+	}
+
+	result.Applied = true
+	return macros, result, nil
+}
+
+// uniqueName returns the name with the first free " (n)" suffix.
+func uniqueName(macros []KeyboardMacro, name string) string {
+	for i := 2; ; i++ {
+		candidate := fmt.Sprintf("%s (%d)", name, i)
+		if !slices.ContainsFunc(macros, func(m KeyboardMacro) bool { return m.Name == candidate }) {
+			return candidate
+		}
+	}
+}
@@ -0,0 +1,246 @@
+package macros
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func keysMacro(id, name string, sortOrder int) KeyboardMacro {
+	return KeyboardMacro{
+		ID:        id,
+		Name:      name,
+		SortOrder: sortOrder,
+		Steps:     []KeyboardMacroStep{{Keys: []string{"KeyA"}, Delay: 100}},
+	}
+}
+
+func exportOf(macros ...KeyboardMacro) KeyboardMacrosExport {
+	return NewExport(macros, "device", "1.0.0")
+}
+
+func TestImportMerge(t *testing.T) {
+	device := []KeyboardMacro{
+		keysMacro("a", "Login", 1),
+		keysMacro("b", "Reboot", 2),
+	}
+
+	tests := []struct {
+		name       string
+		onConflict string
+		imported   []KeyboardMacro
+		// want is the id, name and sort order of the macros once imported
+		want     []KeyboardMacro
+		statuses []string
+		conflict []string
+	}{
+		{
+			name:     "new macro",
+			imported: []KeyboardMacro{keysMacro("c", "Paste", 7)},
+			want: []KeyboardMacro{
+				keysMacro("a", "Login", 1),
+				keysMacro("b", "Reboot", 2),
+				keysMacro("c", "Paste", 3),
+			},
+			statuses: []string{StatusImported},
+			conflict: []string{""},
+		},
+		{
+			name:     "skip is the default",
+			imported: []KeyboardMacro{keysMacro("a", "Other", 1), keysMacro("c", "Reboot", 2)},
+			want: []KeyboardMacro{
+				keysMacro("a", "Login", 1),
+				keysMacro("b", "Reboot", 2),
+			},
+			statuses: []string{StatusSkipped, StatusSkipped},
+			conflict: []string{"id", "name"},
+		},
+		{
+			name:       "overwrite keeps the id and the order of the device",
+			onConflict: ConflictOverwrite,
+			imported:   []KeyboardMacro{keysMacro("c", "Reboot", 9)},
+			want: []KeyboardMacro{
+				keysMacro("a", "Login", 1),
+				keysMacro("b", "Reboot", 2),
+			},
+			statuses: []string{StatusOverwritten},
+			conflict: []string{"name"},
+		},
+		{
+			name:       "rename",
+			onConflict: ConflictRename,
+			imported:   []KeyboardMacro{keysMacro("a", "Login", 1), keysMacro("d", "Login", 2)},
+			want: []KeyboardMacro{
+				keysMacro("a", "Login", 1),
+				keysMacro("b", "Reboot", 2),
+				keysMacro("", "Login (2)", 3),
+				keysMacro("", "Login (3)", 4),
+			},
+			statuses: []string{StatusRenamed, StatusRenamed},
+			conflict: []string{"id", "name"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			macros, result, err := Import(device, ImportKeyboardMacrosParams{
+				Data:       exportOf(tt.imported...),
+				OnConflict: tt.onConflict,
+			})
+			require.NoError(t, err)
+			assert.True(t, result.Applied)
+
+			require.Len(t, macros, len(tt.want))
+			for i, want := range tt.want {
+				if want.ID != "" {
+					assert.Equal(t, want.ID, macros[i].ID)
+				} else {
+					assert.NotEmpty(t, macros[i].ID)
+				}
+				assert.Equal(t, want.Name, macros[i].Name)
+				assert.Equal(t, want.SortOrder, macros[i].SortOrder)
+			}
+
+			require.Len(t, result.Macros, len(tt.imported))
+			for i, item := range result.Macros {
+				assert.Equal(t, i, item.Index)
+				assert.Equal(t, tt.statuses[i], item.Status)
+				assert.Equal(t, tt.conflict[i], item.Conflict)
+			}
+
+			// the macros of the device are never changed in place
+			assert.Equal(t, []KeyboardMacro{keysMacro("a", "Login", 1), keysMacro("b", "Reboot", 2)}, device)
+		})
+	}
+}
+
+func TestImportReplace(t *testing.T) {
+	device := []KeyboardMacro{keysMacro("a", "Login", 1)}
+
+	macros, result, err := Import(device, ImportKeyboardMacrosParams{
+		Data: exportOf(keysMacro("a", "Login", 5), keysMacro("b", "Reboot", 6)),
+		Mode: ImportReplace,
+	})
+	require.NoError(t, err)
+	assert.True(t, result.Applied)
+	require.Len(t, macros, 2)
+	assert.Equal(t, "a", macros[0].ID)
+	assert.Equal(t, 1, macros[0].SortOrder)
+	assert.Equal(t, 2, macros[1].SortOrder)
+	for _, item := range result.Macros {
+		assert.Equal(t, StatusImported, item.Status)
+		assert.Empty(t, item.Conflict)
+	}
+}
+
+func TestImportDuplicateIDs(t *testing.T) {
+	macros, result, err := Import(nil, ImportKeyboardMacrosParams{
+		Data: exportOf(keysMacro("a", "One", 1), keysMacro("a", "Two", 2), keysMacro("", "Three", 3)),
+	})
+	require.NoError(t, err)
+	require.Len(t, macros, 3)
+
+	// the duplicated and the missing ids are replaced, no macro is skipped
+	assert.Equal(t, "a", macros[0].ID)
+	assert.NotEqual(t, "a", macros[1].ID)
+	assert.NotEmpty(t, macros[2].ID)
+	assert.NotEqual(t, macros[1].ID, macros[2].ID)
+	for i, item := range result.Macros {
+		assert.Equal(t, StatusImported, item.Status)
+		assert.Equal(t, macros[i].ID, item.ID)
+	}
+}
+
+func TestImportNotApplied(t *testing.T) {
+	device := []KeyboardMacro{keysMacro("a", "Login", 1)}
+	invalid := KeyboardMacro{ID: "x", Name: "Broken", Steps: []KeyboardMacroStep{{Type: StepText}}}
+
+	tests := []struct {
+		name        string
+		params      ImportKeyboardMacrosParams
+		wantInvalid []int
+	}{
+		{
+			name: "dry run",
+			params: ImportKeyboardMacrosParams{
+				Data:   exportOf(keysMacro("b", "Reboot", 1)),
+				DryRun: true,
+			},
+		},
+		{
+			name: "an invalid macro stops the import",
+			params: ImportKeyboardMacrosParams{
+				Data: exportOf(keysMacro("b", "Reboot", 1), invalid),
+				Mode: ImportReplace,
+			},
+			wantInvalid: []int{1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			macros, result, err := Import(device, tt.params)
+			require.NoError(t, err)
+			assert.Nil(t, macros)
+			assert.False(t, result.Applied)
+			require.Len(t, result.Macros, len(tt.params.Data.Macros))
+
+			for i, item := range result.Macros {
+				if slices.Contains(tt.wantInvalid, i) {
+					assert.Equal(t, StatusInvalid, item.Status)
+					assert.NotEmpty(t, item.Error)
+				} else {
+					assert.Equal(t, StatusImported, item.Status)
+				}
+			}
+		})
+	}
+}
+
+func TestImportErrors(t *testing.T) {
+	tooMany := make([]KeyboardMacro, MaxMacrosPerDevice)
+	for i := range tooMany {
+		tooMany[i] = keysMacro("", "Macro", i)
+	}
+
+	tests := []struct {
+		name   string
+		params ImportKeyboardMacrosParams
+	}{
+		{
+			name:   "wrong format",
+			params: ImportKeyboardMacrosParams{Data: KeyboardMacrosExport{Format: "other", Version: 1, Macros: tooMany[:1]}},
+		},
+		{
+			name:   "newer version",
+			params: ImportKeyboardMacrosParams{Data: KeyboardMacrosExport{Format: ExportFormat, Version: ExportFormatVersion + 1, Macros: tooMany[:1]}},
+		},
+		{
+			name:   "no macros",
+			params: ImportKeyboardMacrosParams{Data: exportOf()},
+		},
+		{
+			name:   "invalid mode",
+			params: ImportKeyboardMacrosParams{Data: exportOf(keysMacro("b", "Reboot", 1)), Mode: "append"},
+		},
+		{
+			name:   "invalid conflict resolution",
+			params: ImportKeyboardMacrosParams{Data: exportOf(keysMacro("b", "Reboot", 1)), OnConflict: "ignore"},
+		},
+		{
+			name:   "too many macros",
+			params: ImportKeyboardMacrosParams{Data: exportOf(tooMany...), OnConflict: ConflictRename},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			macros, result, err := Import([]KeyboardMacro{keysMacro("a", "Login", 1)}, tt.params)
+			assert.Error(t, err)
+			assert.Nil(t, macros)
+			assert.Nil(t, result)
+		})
+	}
+}
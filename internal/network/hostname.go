@@ -131,6 +131,9 @@ func (s *NetworkInterfaceState) setHostnameIfNotSame() error {
 	s.currentHostname = hostname
 	s.currentFqdn = fqdn
 
+	// advertise the new system name to the link partner
+	s.lldp.TriggerTx()
+
 	scopedLogger.Info().Msg("hostname set")
 
 	return nil
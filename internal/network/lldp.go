@@ -0,0 +1,690 @@
+package network
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+const (
+	lldpEtherType    = 0x88cc
+	lldpTxInterval   = 30 * time.Second
+	lldpTxHoldMulti  = 4
+	lldpReadTimeout  = 1 * time.Second
+	lldpMaxFrameSize = 1500
+)
+
+// lldpMulticastAddr is the nearest bridge group address, see IEEE 802.1AB-2016 7.1
+var lldpMulticastAddr = net.HardwareAddr{0x01, 0x80, 0xc2, 0x00, 0x00, 0x0e}
+
+// LLDP TLV types, see IEEE 802.1AB-2016 8.4
+const (
+	lldpTLVTypeEnd               = 0
+	lldpTLVTypeChassisID         = 1
+	lldpTLVTypePortID            = 2
+	lldpTLVTypeTTL               = 3
+	lldpTLVTypePortDescription   = 4
+	lldpTLVTypeSystemName        = 5
+	lldpTLVTypeSystemDescription = 6
+	lldpTLVTypeSystemCapability  = 7
+	lldpTLVTypeManagementAddress = 8
+	lldpTLVTypeOrgSpecific       = 127
+)
+
+const (
+	lldpChassisIDSubtypeMACAddress     = 4
+	lldpChassisIDSubtypeNetworkAddress = 5
+	lldpPortIDSubtypeMACAddress        = 3
+	lldpPortIDSubtypeNetworkAddress    = 4
+	lldpPortIDSubtypeInterfaceName     = 5
+
+	lldpSystemCapabilityStation = 1 << 7
+
+	lldpAddressFamilyIPv4 = 1
+	lldpAddressFamilyIPv6 = 2
+)
+
+// IEEE 802.1 organizationally specific TLVs, see IEEE 802.1Q-2018 Annex D
+var lldpOUI8021 = [3]byte{0x00, 0x80, 0xc2}
+
+const (
+	lldp8021SubtypePortVLANID = 1
+	lldp8021SubtypeVLANName   = 3
+)
+
+// LLDP modes, validated by NetworkConfig.LLDPMode
+//
+//   - disabled: neither transmit nor receive LLDPDUs
+//   - basic: receive neighbors and advertise the mandatory TLVs only
+//   - all: receive neighbors and advertise the mandatory TLVs plus the ones listed in LLDPTxTLVs
+const (
+	LLDPModeDisabled = "disabled"
+	LLDPModeBasic    = "basic"
+	LLDPModeAll      = "all"
+)
+
+type LLDPNeighbor struct {
+	ChassisID         string    `json:"chassis_id"`
+	PortID            string    `json:"port_id"`
+	PortDescription   string    `json:"port_description,omitempty"`
+	SystemName        string    `json:"system_name,omitempty"`
+	SystemDescription string    `json:"system_description,omitempty"`
+	ManagementAddress string    `json:"management_address,omitempty"`
+	VLANID            uint16    `json:"vlan_id,omitempty"`
+	VLANName          string    `json:"vlan_name,omitempty"`
+	TTL               uint16    `json:"ttl"`
+	LastSeen          time.Time `json:"last_seen"`
+
+	expiresAt time.Time
+}
+
+func (n *LLDPNeighbor) key() string {
+	return n.ChassisID + "/" + n.PortID
+}
+
+// LLDPLocalInfo is the information advertised to the link partner
+type LLDPLocalInfo struct {
+	MACAddress        net.HardwareAddr
+	PortName          string
+	PortDescription   string
+	SystemName        string
+	SystemDescription string
+	ManagementAddress net.IP
+	VLANID            uint16
+}
+
+type LLDP struct {
+	interfaceName string
+	l             *zerolog.Logger
+
+	mode       string
+	txTLVs     []string
+	configLock sync.Mutex
+
+	getLocalInfo func() LLDPLocalInfo
+	onChange     func(neighbors []LLDPNeighbor)
+
+	conn    *lldpConn
+	stop    chan struct{}
+	txNow   chan struct{}
+	running sync.WaitGroup
+	lock    sync.Mutex
+
+	neighbors     map[string]*LLDPNeighbor
+	neighborsLock sync.Mutex
+}
+
+type LLDPOptions struct {
+	InterfaceName string
+	Logger        *zerolog.Logger
+	GetLocalInfo  func() LLDPLocalInfo
+	OnChange      func(neighbors []LLDPNeighbor)
+}
+
+func NewLLDP(opts *LLDPOptions) *LLDP {
+	l := opts.Logger.With().Str("service", "lldp").Logger()
+
+	return &LLDP{
+		interfaceName: opts.InterfaceName,
+		l:             &l,
+		mode:          LLDPModeDisabled,
+		getLocalInfo:  opts.GetLocalInfo,
+		onChange:      opts.OnChange,
+		neighbors:     make(map[string]*LLDPNeighbor),
+	}
+}
+
+// SetConfig applies the LLDP mode and TLVs, starting or stopping the
+// transmit and receive loops as needed.
+func (d *LLDP) SetConfig(mode string, txTLVs []string) error {
+	if mode == "" {
+		mode = LLDPModeDisabled
+	}
+
+	// read before taking the lock, getLocalInfo takes the locks of the interface state
+	var info LLDPLocalInfo
+	if mode == LLDPModeDisabled {
+		info = d.getLocalInfo()
+	}
+
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	wasRunning := d.conn != nil
+
+	d.configLock.Lock()
+	d.mode = mode
+	d.txTLVs = slices.Clone(txTLVs)
+	d.configLock.Unlock()
+
+	if mode == LLDPModeDisabled {
+		if wasRunning {
+			d.stopLocked(info)
+		}
+		return nil
+	}
+
+	if wasRunning {
+		// advertise the new TLVs right away
+		d.triggerTx()
+		return nil
+	}
+
+	return d.startLocked()
+}
+
+// TriggerTx sends an LLDPDU immediately, e.g. after the link came up or the
+// hostname changed.
+func (d *LLDP) TriggerTx() {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	d.triggerTx()
+}
+
+func (d *LLDP) triggerTx() {
+	if d.txNow == nil {
+		return
+	}
+
+	select {
+	case d.txNow <- struct{}{}:
+	default:
+	}
+}
+
+func (d *LLDP) Stop() {
+	info := d.getLocalInfo()
+
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	d.stopLocked(info)
+}
+
+func (d *LLDP) startLocked() error {
+	conn, err := openLLDPConn(d.interfaceName, lldpMulticastAddr)
+	if err != nil {
+		return fmt.Errorf("failed to open LLDP socket: %w", err)
+	}
+
+	d.conn = conn
+	d.stop = make(chan struct{})
+	d.txNow = make(chan struct{}, 1)
+
+	d.running.Add(2)
+	go d.runTx(conn, d.stop, d.txNow)
+	go d.runRx(conn, d.stop)
+
+	d.l.Info().Str("mode", d.mode).Strs("tx_tlvs", d.txTLVs).Msg("LLDP started")
+
+	return nil
+}
+
+// stopLocked stops the loops and sends the shutdown frame, info is read by the caller
+// before taking the lock.
+func (d *LLDP) stopLocked(info LLDPLocalInfo) {
+	if d.conn == nil {
+		return
+	}
+
+	close(d.stop)
+	d.running.Wait()
+
+	// tell the link partner to forget about us, see IEEE 802.1AB-2016 9.2.7.7.2
+	if frame, err := buildLLDPFrame(info, 0, LLDPModeBasic, nil); err == nil {
+		if err := d.conn.write(frame, lldpMulticastAddr); err != nil {
+			d.l.Warn().Err(err).Msg("failed to send LLDP shutdown frame")
+		}
+	}
+
+	_ = d.conn.close()
+	d.conn = nil
+	d.stop = nil
+	d.txNow = nil
+
+	d.clearNeighbors()
+
+	d.l.Info().Msg("LLDP stopped")
+}
+
+func (d *LLDP) runTx(conn *lldpConn, stop chan struct{}, txNow chan struct{}) {
+	defer d.running.Done()
+
+	ticker := time.NewTicker(lldpTxInterval)
+	defer ticker.Stop()
+
+	d.transmit(conn)
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-txNow:
+			d.transmit(conn)
+		case <-ticker.C:
+			d.transmit(conn)
+		}
+	}
+}
+
+func (d *LLDP) transmit(conn *lldpConn) {
+	ttl := uint16(lldpTxInterval.Seconds() * lldpTxHoldMulti)
+
+	frame, err := d.buildFrame(d.getLocalInfo(), ttl)
+	if err != nil {
+		d.l.Warn().Err(err).Msg("failed to build LLDP frame")
+		return
+	}
+
+	if err := conn.write(frame, lldpMulticastAddr); err != nil {
+		d.l.Warn().Err(err).Msg("failed to send LLDP frame")
+		return
+	}
+
+	d.l.Trace().Int("size", len(frame)).Msg("LLDP frame sent")
+}
+
+func (d *LLDP) runRx(conn *lldpConn, stop chan struct{}) {
+	defer d.running.Done()
+
+	buf := make([]byte, lldpMaxFrameSize)
+	for {
+		select {
+		case <-stop:
+			return
+		default:
+		}
+
+		n, err := conn.read(buf)
+		if err != nil {
+			if !errors.Is(err, errLLDPReadTimeout) {
+				d.l.Warn().Err(err).Msg("failed to read LLDP frame")
+				time.Sleep(lldpReadTimeout)
+			}
+			d.expireNeighbors()
+			continue
+		}
+
+		neighbor, err := parseLLDPFrame(buf[:n])
+		if err != nil {
+			d.l.Debug().Err(err).Msg("ignoring invalid LLDP frame")
+			continue
+		}
+
+		d.updateNeighbor(neighbor)
+		d.expireNeighbors()
+	}
+}
+
+func (d *LLDP) updateNeighbor(neighbor *LLDPNeighbor) {
+	d.neighborsLock.Lock()
+
+	key := neighbor.key()
+	existing, exists := d.neighbors[key]
+
+	changed := false
+	if neighbor.TTL == 0 {
+		if exists {
+			delete(d.neighbors, key)
+			changed = true
+			d.l.Info().Str("chassis_id", neighbor.ChassisID).Str("port_id", neighbor.PortID).Msg("LLDP neighbor shut down")
+		}
+	} else {
+		if !exists {
+			d.l.Info().
+				Str("chassis_id", neighbor.ChassisID).
+				Str("port_id", neighbor.PortID).
+				Str("system_name", neighbor.SystemName).
+				Uint16("vlan_id", neighbor.VLANID).
+				Msg("LLDP neighbor discovered")
+		}
+		changed = !exists || !sameLLDPNeighbor(existing, neighbor)
+		d.neighbors[key] = neighbor
+	}
+
+	d.neighborsLock.Unlock()
+
+	if changed {
+		d.notifyChange()
+	}
+}
+
+func (d *LLDP) expireNeighbors() {
+	d.neighborsLock.Lock()
+
+	now := time.Now()
+	changed := false
+	for key, neighbor := range d.neighbors {
+		if now.After(neighbor.expiresAt) {
+			d.l.Info().Str("chassis_id", neighbor.ChassisID).Str("port_id", neighbor.PortID).Msg("LLDP neighbor expired")
+			delete(d.neighbors, key)
+			changed = true
+		}
+	}
+
+	d.neighborsLock.Unlock()
+
+	if changed {
+		d.notifyChange()
+	}
+}
+
+func (d *LLDP) clearNeighbors() {
+	d.neighborsLock.Lock()
+	changed := len(d.neighbors) > 0
+	d.neighbors = make(map[string]*LLDPNeighbor)
+	d.neighborsLock.Unlock()
+
+	if changed {
+		d.notifyChange()
+	}
+}
+
+func (d *LLDP) notifyChange() {
+	if d.onChange != nil {
+		d.onChange(d.GetNeighbors())
+	}
+}
+
+// GetNeighbors returns the neighbors that haven't expired yet, sorted by chassis and port ID.
+func (d *LLDP) GetNeighbors() []LLDPNeighbor {
+	d.neighborsLock.Lock()
+	defer d.neighborsLock.Unlock()
+
+	now := time.Now()
+	neighbors := make([]LLDPNeighbor, 0, len(d.neighbors))
+	for _, neighbor := range d.neighbors {
+		if now.After(neighbor.expiresAt) {
+			continue
+		}
+		neighbors = append(neighbors, *neighbor)
+	}
+
+	sort.Slice(neighbors, func(i, j int) bool {
+		return neighbors[i].key() < neighbors[j].key()
+	})
+
+	return neighbors
+}
+
+func sameLLDPNeighbor(a, b *LLDPNeighbor) bool {
+	return a.PortDescription == b.PortDescription &&
+		a.SystemName == b.SystemName &&
+		a.SystemDescription == b.SystemDescription &&
+		a.ManagementAddress == b.ManagementAddress &&
+		a.VLANID == b.VLANID &&
+		a.VLANName == b.VLANName
+}
+
+func (d *LLDP) buildFrame(info LLDPLocalInfo, ttl uint16) ([]byte, error) {
+	d.configLock.Lock()
+	mode := d.mode
+	txTLVs := d.txTLVs
+	d.configLock.Unlock()
+
+	return buildLLDPFrame(info, ttl, mode, txTLVs)
+}
+
+func buildLLDPFrame(info LLDPLocalInfo, ttl uint16, mode string, txTLVs []string) ([]byte, error) {
+	if len(info.MACAddress) != 6 {
+		return nil, fmt.Errorf("invalid MAC address: %s", info.MACAddress)
+	}
+
+	frame := make([]byte, 0, 256)
+	frame = append(frame, lldpMulticastAddr...)
+	frame = append(frame, info.MACAddress...)
+	frame = binary.BigEndian.AppendUint16(frame, lldpEtherType)
+
+	// mandatory TLVs
+	frame = appendLLDPTLV(frame, lldpTLVTypeChassisID, append([]byte{lldpChassisIDSubtypeMACAddress}, info.MACAddress...))
+	frame = appendLLDPTLV(frame, lldpTLVTypePortID, append([]byte{lldpPortIDSubtypeInterfaceName}, []byte(info.PortName)...))
+	frame = appendLLDPTLV(frame, lldpTLVTypeTTL, binary.BigEndian.AppendUint16(nil, ttl))
+
+	if mode == LLDPModeAll && ttl != 0 {
+		if slices.Contains(txTLVs, "port") && info.PortDescription != "" {
+			frame = appendLLDPTLV(frame, lldpTLVTypePortDescription, []byte(info.PortDescription))
+		}
+
+		if slices.Contains(txTLVs, "system") {
+			if info.SystemName != "" {
+				frame = appendLLDPTLV(frame, lldpTLVTypeSystemName, []byte(info.SystemName))
+			}
+			if info.SystemDescription != "" {
+				frame = appendLLDPTLV(frame, lldpTLVTypeSystemDescription, []byte(info.SystemDescription))
+			}
+		}
+
+		if slices.Contains(txTLVs, "chassis") {
+			capabilities := binary.BigEndian.AppendUint16(nil, lldpSystemCapabilityStation)
+			capabilities = binary.BigEndian.AppendUint16(capabilities, lldpSystemCapabilityStation)
+			frame = appendLLDPTLV(frame, lldpTLVTypeSystemCapability, capabilities)
+
+			if value := lldpManagementAddressValue(info.ManagementAddress); value != nil {
+				frame = appendLLDPTLV(frame, lldpTLVTypeManagementAddress, value)
+			}
+		}
+
+		if slices.Contains(txTLVs, "vlan") && info.VLANID != 0 {
+			value := append(slices.Clone(lldpOUI8021[:]), lldp8021SubtypePortVLANID)
+			value = binary.BigEndian.AppendUint16(value, info.VLANID)
+			frame = appendLLDPTLV(frame, lldpTLVTypeOrgSpecific, value)
+		}
+	}
+
+	frame = appendLLDPTLV(frame, lldpTLVTypeEnd, nil)
+
+	return frame, nil
+}
+
+func lldpManagementAddressValue(ip net.IP) []byte {
+	var (
+		family byte
+		addr   []byte
+	)
+
+	if ip4 := ip.To4(); ip4 != nil {
+		family, addr = lldpAddressFamilyIPv4, ip4
+	} else if ip16 := ip.To16(); ip16 != nil {
+		family, addr = lldpAddressFamilyIPv6, ip16
+	} else {
+		return nil
+	}
+
+	value := []byte{byte(len(addr) + 1), family}
+	value = append(value, addr...)
+	// interface numbering subtype: unknown, interface number: 0, OID length: 0
+	value = append(value, 1, 0, 0, 0, 0, 0)
+
+	return value
+}
+
+func appendLLDPTLV(frame []byte, typ uint8, value []byte) []byte {
+	// truncate the value to the maximum TLV length of 511 bytes
+	if len(value) > 511 {
+		value = value[:511]
+	}
+
+	header := uint16(typ)<<9 | uint16(len(value))
+	frame = binary.BigEndian.AppendUint16(frame, header)
+	return append(frame, value...)
+}
+
+func parseLLDPFrame(frame []byte) (*LLDPNeighbor, error) {
+	if len(frame) < 14 {
+		return nil, fmt.Errorf("frame too short: %d bytes", len(frame))
+	}
+
+	offset := 12
+	etherType := binary.BigEndian.Uint16(frame[offset:])
+	// skip the 802.1Q tag if present
+	if etherType == 0x8100 {
+		offset += 4
+		if len(frame) < offset+2 {
+			return nil, fmt.Errorf("frame too short: %d bytes", len(frame))
+		}
+		etherType = binary.BigEndian.Uint16(frame[offset:])
+	}
+
+	if etherType != lldpEtherType {
+		return nil, fmt.Errorf("unexpected ethertype: 0x%04x", etherType)
+	}
+
+	return parseLLDPDU(frame[offset+2:])
+}
+
+func parseLLDPDU(data []byte) (*LLDPNeighbor, error) {
+	neighbor := &LLDPNeighbor{}
+
+	hasChassisID, hasPortID, hasTTL := false, false, false
+
+	for index := 0; len(data) > 0; index++ {
+		if len(data) < 2 {
+			return nil, fmt.Errorf("truncated TLV header")
+		}
+
+		header := binary.BigEndian.Uint16(data)
+		typ := uint8(header >> 9)
+		length := int(header & 0x1ff)
+		data = data[2:]
+
+		if len(data) < length {
+			return nil, fmt.Errorf("truncated TLV %d: expected %d bytes, got %d", typ, length, len(data))
+		}
+
+		value := data[:length]
+		data = data[length:]
+
+		// the first three TLVs must be chassis ID, port ID and TTL, in this order
+		switch {
+		case index == 0 && typ != lldpTLVTypeChassisID,
+			index == 1 && typ != lldpTLVTypePortID,
+			index == 2 && typ != lldpTLVTypeTTL:
+			return nil, fmt.Errorf("unexpected TLV %d at position %d", typ, index)
+		}
+
+		switch typ {
+		case lldpTLVTypeEnd:
+			data = nil
+		case lldpTLVTypeChassisID:
+			if length < 2 {
+				return nil, fmt.Errorf("invalid chassis ID TLV")
+			}
+			neighbor.ChassisID = formatLLDPID(value[0], value[1:], lldpChassisIDSubtypeMACAddress, lldpChassisIDSubtypeNetworkAddress)
+			hasChassisID = true
+		case lldpTLVTypePortID:
+			if length < 2 {
+				return nil, fmt.Errorf("invalid port ID TLV")
+			}
+			neighbor.PortID = formatLLDPID(value[0], value[1:], lldpPortIDSubtypeMACAddress, lldpPortIDSubtypeNetworkAddress)
+			hasPortID = true
+		case lldpTLVTypeTTL:
+			if length < 2 {
+				return nil, fmt.Errorf("invalid TTL TLV")
+			}
+			neighbor.TTL = binary.BigEndian.Uint16(value)
+			hasTTL = true
+		case lldpTLVTypePortDescription:
+			neighbor.PortDescription = lldpString(value)
+		case lldpTLVTypeSystemName:
+			neighbor.SystemName = lldpString(value)
+		case lldpTLVTypeSystemDescription:
+			neighbor.SystemDescription = lldpString(value)
+		case lldpTLVTypeManagementAddress:
+			if neighbor.ManagementAddress == "" {
+				neighbor.ManagementAddress = parseLLDPManagementAddress(value)
+			}
+		case lldpTLVTypeOrgSpecific:
+			parseLLDPOrgSpecific(neighbor, value)
+		}
+	}
+
+	if !hasChassisID || !hasPortID || !hasTTL {
+		return nil, fmt.Errorf("missing mandatory TLVs")
+	}
+
+	neighbor.LastSeen = time.Now()
+	neighbor.expiresAt = neighbor.LastSeen.Add(time.Duration(neighbor.TTL) * time.Second)
+
+	return neighbor, nil
+}
+
+func formatLLDPID(subtype byte, value []byte, macSubtype byte, networkAddressSubtype byte) string {
+	switch subtype {
+	case macSubtype:
+		if len(value) == 6 {
+			return net.HardwareAddr(value).String()
+		}
+	case networkAddressSubtype:
+		if len(value) == 5 && value[0] == lldpAddressFamilyIPv4 {
+			return net.IP(value[1:]).String()
+		}
+		if len(value) == 17 && value[0] == lldpAddressFamilyIPv6 {
+			return net.IP(value[1:]).String()
+		}
+	}
+
+	return lldpString(value)
+}
+
+func parseLLDPManagementAddress(value []byte) string {
+	if len(value) < 2 {
+		return ""
+	}
+
+	addrLen := int(value[0])
+	if addrLen < 2 || len(value) < addrLen+1 {
+		return ""
+	}
+
+	family, addr := value[1], value[2:addrLen+1]
+	switch {
+	case family == lldpAddressFamilyIPv4 && len(addr) == 4,
+		family == lldpAddressFamilyIPv6 && len(addr) == 16:
+		return net.IP(addr).String()
+	}
+
+	return ""
+}
+
+func parseLLDPOrgSpecific(neighbor *LLDPNeighbor, value []byte) {
+	if len(value) < 4 || [3]byte(value[:3]) != lldpOUI8021 {
+		return
+	}
+
+	subtype, value := value[3], value[4:]
+	switch subtype {
+	case lldp8021SubtypePortVLANID:
+		if len(value) >= 2 {
+			neighbor.VLANID = binary.BigEndian.Uint16(value)
+		}
+	case lldp8021SubtypeVLANName:
+		if len(value) < 3 {
+			return
+		}
+		vlanID := binary.BigEndian.Uint16(value)
+		nameLen := int(value[2])
+		if len(value) < 3+nameLen {
+			return
+		}
+		// only keep the name of the port VLAN, or the first one if the PVID is unknown
+		if neighbor.VLANName == "" && (neighbor.VLANID == 0 || neighbor.VLANID == vlanID) {
+			neighbor.VLANName = lldpString(value[3 : 3+nameLen])
+			if neighbor.VLANID == 0 {
+				neighbor.VLANID = vlanID
+			}
+		}
+	}
+}
+
+func lldpString(value []byte) string {
+	return strings.TrimRight(strings.ToValidUTF8(string(value), ""), "\x00")
+}
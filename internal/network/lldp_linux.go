@@ -0,0 +1,96 @@
+//go:build linux
+
+package network
+
+import (
+	"errors"
+	"fmt"
+	"net"
+
+	"golang.org/x/sys/unix"
+)
+
+var errLLDPReadTimeout = errors.New("read timeout")
+
+type lldpConn struct {
+	fd      int
+	ifIndex int
+}
+
+func htons(v uint16) uint16 {
+	return (v << 8) | (v >> 8)
+}
+
+func openLLDPConn(interfaceName string, multicastAddr net.HardwareAddr) (*lldpConn, error) {
+	iface, err := net.InterfaceByName(interfaceName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get interface %s: %w", interfaceName, err)
+	}
+
+	fd, err := unix.Socket(unix.AF_PACKET, unix.SOCK_RAW|unix.SOCK_CLOEXEC, int(htons(lldpEtherType)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create packet socket: %w", err)
+	}
+
+	if err := unix.Bind(fd, &unix.SockaddrLinklayer{
+		Protocol: htons(lldpEtherType),
+		Ifindex:  iface.Index,
+	}); err != nil {
+		_ = unix.Close(fd)
+		return nil, fmt.Errorf("failed to bind packet socket: %w", err)
+	}
+
+	mreq := &unix.PacketMreq{
+		Ifindex: int32(iface.Index),
+		Type:    unix.PACKET_MR_MULTICAST,
+		Alen:    uint16(len(multicastAddr)),
+	}
+	copy(mreq.Address[:], multicastAddr)
+	if err := unix.SetsockoptPacketMreq(fd, unix.SOL_PACKET, unix.PACKET_ADD_MEMBERSHIP, mreq); err != nil {
+		_ = unix.Close(fd)
+		return nil, fmt.Errorf("failed to join LLDP multicast group: %w", err)
+	}
+
+	// use a read timeout so the receive loop can be stopped
+	timeout := unix.NsecToTimeval(lldpReadTimeout.Nanoseconds())
+	if err := unix.SetsockoptTimeval(fd, unix.SOL_SOCKET, unix.SO_RCVTIMEO, &timeout); err != nil {
+		_ = unix.Close(fd)
+		return nil, fmt.Errorf("failed to set read timeout: %w", err)
+	}
+
+	return &lldpConn{fd: fd, ifIndex: iface.Index}, nil
+}
+
+func (c *lldpConn) read(buf []byte) (int, error) {
+	for {
+		n, from, err := unix.Recvfrom(c.fd, buf, 0)
+		if err != nil {
+			if errors.Is(err, unix.EAGAIN) || errors.Is(err, unix.EINTR) {
+				return 0, errLLDPReadTimeout
+			}
+			return 0, err
+		}
+
+		// ignore the frames we sent ourselves
+		if ll, ok := from.(*unix.SockaddrLinklayer); ok && ll.Pkttype == unix.PACKET_OUTGOING {
+			continue
+		}
+
+		return n, nil
+	}
+}
+
+func (c *lldpConn) write(frame []byte, dst net.HardwareAddr) error {
+	addr := &unix.SockaddrLinklayer{
+		Protocol: htons(lldpEtherType),
+		Ifindex:  c.ifIndex,
+		Halen:    uint8(len(dst)),
+	}
+	copy(addr.Addr[:], dst)
+
+	return unix.Sendto(c.fd, frame, 0, addr)
+}
+
+func (c *lldpConn) close() error {
+	return unix.Close(c.fd)
+}
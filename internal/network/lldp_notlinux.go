@@ -0,0 +1,29 @@
+//go:build !linux
+
+package network
+
+import (
+	"errors"
+	"fmt"
+	"net"
+)
+
+var errLLDPReadTimeout = errors.New("read timeout")
+
+type lldpConn struct{}
+
+func openLLDPConn(interfaceName string, multicastAddr net.HardwareAddr) (*lldpConn, error) {
+	return nil, fmt.Errorf("not implemented")
+}
+
+func (c *lldpConn) read(buf []byte) (int, error) {
+	return 0, fmt.Errorf("not implemented")
+}
+
+func (c *lldpConn) write(frame []byte, dst net.HardwareAddr) error {
+	return fmt.Errorf("not implemented")
+}
+
+func (c *lldpConn) close() error {
+	return nil
+}
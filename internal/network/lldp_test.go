@@ -0,0 +1,151 @@
+package network
+
+import (
+	"net"
+	"testing"
+)
+
+func testLLDPLocalInfo() LLDPLocalInfo {
+	return LLDPLocalInfo{
+		MACAddress:        net.HardwareAddr{0x44, 0xb7, 0xd0, 0x01, 0x02, 0x03},
+		PortName:          "eth0",
+		PortDescription:   "eth0",
+		SystemName:        "jetkvm.local",
+		SystemDescription: "JetKVM 0.4.6",
+		ManagementAddress: net.ParseIP("192.168.1.10"),
+		VLANID:            42,
+	}
+}
+
+func TestBuildAndParseLLDPFrame(t *testing.T) {
+	frame, err := buildLLDPFrame(testLLDPLocalInfo(), 120, LLDPModeAll, []string{"chassis", "port", "system", "vlan"})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	neighbor, err := parseLLDPFrame(frame)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if neighbor.ChassisID != "44:b7:d0:01:02:03" {
+		t.Fatalf("expected chassis id to be 44:b7:d0:01:02:03, got %s", neighbor.ChassisID)
+	}
+	if neighbor.PortID != "eth0" {
+		t.Fatalf("expected port id to be eth0, got %s", neighbor.PortID)
+	}
+	if neighbor.TTL != 120 {
+		t.Fatalf("expected ttl to be 120, got %d", neighbor.TTL)
+	}
+	if neighbor.SystemName != "jetkvm.local" {
+		t.Fatalf("expected system name to be jetkvm.local, got %s", neighbor.SystemName)
+	}
+	if neighbor.SystemDescription != "JetKVM 0.4.6" {
+		t.Fatalf("expected system description to be JetKVM 0.4.6, got %s", neighbor.SystemDescription)
+	}
+	if neighbor.ManagementAddress != "192.168.1.10" {
+		t.Fatalf("expected management address to be 192.168.1.10, got %s", neighbor.ManagementAddress)
+	}
+	if neighbor.VLANID != 42 {
+		t.Fatalf("expected vlan id to be 42, got %d", neighbor.VLANID)
+	}
+}
+
+func TestBuildLLDPFrameBasicMode(t *testing.T) {
+	frame, err := buildLLDPFrame(testLLDPLocalInfo(), 120, LLDPModeBasic, []string{"chassis", "port", "system", "vlan"})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	neighbor, err := parseLLDPFrame(frame)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if neighbor.SystemName != "" || neighbor.ManagementAddress != "" || neighbor.VLANID != 0 {
+		t.Fatalf("expected only mandatory TLVs in basic mode, got %+v", neighbor)
+	}
+}
+
+func TestBuildLLDPFrameTxTLVs(t *testing.T) {
+	frame, err := buildLLDPFrame(testLLDPLocalInfo(), 120, LLDPModeAll, []string{"system"})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	neighbor, err := parseLLDPFrame(frame)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if neighbor.SystemName != "jetkvm.local" {
+		t.Fatalf("expected system name to be jetkvm.local, got %s", neighbor.SystemName)
+	}
+	if neighbor.ManagementAddress != "" {
+		t.Fatalf("expected no management address, got %s", neighbor.ManagementAddress)
+	}
+	if neighbor.VLANID != 0 {
+		t.Fatalf("expected no vlan id, got %d", neighbor.VLANID)
+	}
+}
+
+func TestParseLLDPFrameFromSwitch(t *testing.T) {
+	frame := []byte{
+		// destination, source, ethertype
+		0x01, 0x80, 0xc2, 0x00, 0x00, 0x0e,
+		0x00, 0x11, 0x22, 0x33, 0x44, 0x55,
+		0x88, 0xcc,
+		// chassis id, mac address
+		0x02, 0x07, 0x04, 0x00, 0x11, 0x22, 0x33, 0x44, 0x55,
+		// port id, interface name "Gi1/0/7"
+		0x04, 0x08, 0x05, 'G', 'i', '1', '/', '0', '/', '7',
+		// ttl 120
+		0x06, 0x02, 0x00, 0x78,
+		// system name "core-sw1"
+		0x0a, 0x08, 'c', 'o', 'r', 'e', '-', 's', 'w', '1',
+		// port vlan id 100
+		0xfe, 0x06, 0x00, 0x80, 0xc2, 0x01, 0x00, 0x64,
+		// vlan name 100 "mgmt"
+		0xfe, 0x0b, 0x00, 0x80, 0xc2, 0x03, 0x00, 0x64, 0x04, 'm', 'g', 'm', 't',
+		// end
+		0x00, 0x00,
+	}
+
+	neighbor, err := parseLLDPFrame(frame)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if neighbor.ChassisID != "00:11:22:33:44:55" {
+		t.Fatalf("expected chassis id to be 00:11:22:33:44:55, got %s", neighbor.ChassisID)
+	}
+	if neighbor.PortID != "Gi1/0/7" {
+		t.Fatalf("expected port id to be Gi1/0/7, got %s", neighbor.PortID)
+	}
+	if neighbor.SystemName != "core-sw1" {
+		t.Fatalf("expected system name to be core-sw1, got %s", neighbor.SystemName)
+	}
+	if neighbor.VLANID != 100 {
+		t.Fatalf("expected vlan id to be 100, got %d", neighbor.VLANID)
+	}
+	if neighbor.VLANName != "mgmt" {
+		t.Fatalf("expected vlan name to be mgmt, got %s", neighbor.VLANName)
+	}
+}
+
+func TestParseLLDPFrameMissingMandatoryTLV(t *testing.T) {
+	frame := []byte{
+		0x01, 0x80, 0xc2, 0x00, 0x00, 0x0e,
+		0x00, 0x11, 0x22, 0x33, 0x44, 0x55,
+		0x88, 0xcc,
+		// chassis id, mac address
+		0x02, 0x07, 0x04, 0x00, 0x11, 0x22, 0x33, 0x44, 0x55,
+		// ttl without port id
+		0x06, 0x02, 0x00, 0x78,
+		0x00, 0x00,
+	}
+
+	if _, err := parseLLDPFrame(frame); err == nil {
+		t.Fatalf("expected an error, got nil")
+	}
+}
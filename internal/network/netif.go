@@ -3,6 +3,7 @@ package network
 import (
 	"fmt"
 	"net"
+	"slices"
 	"sync"
 
 	"github.com/jetkvm/kvm/internal/confparser"
@@ -22,17 +23,21 @@ type NetworkInterfaceState struct {
 	ipv6Addresses []IPv6Address
 	ipv6LinkLocal *net.IP
 	macAddr       *net.HardwareAddr
+	vlanID        uint16
 
 	l         *zerolog.Logger
 	stateLock sync.Mutex
 
 	config     *NetworkConfig
 	dhcpClient *udhcpc.DHCPClient
+	lldp       *LLDP
 
 	defaultHostname string
 	currentHostname string
 	currentFqdn     string
 
+	systemDescription string
+
 	onStateChange  func(state *NetworkInterfaceState)
 	onInitialCheck func(state *NetworkInterfaceState)
 	cbConfigChange func(config *NetworkConfig)
@@ -41,15 +46,17 @@ type NetworkInterfaceState struct {
 }
 
 type NetworkInterfaceOptions struct {
-	InterfaceName     string
-	DhcpPidFile       string
-	Logger            *zerolog.Logger
-	DefaultHostname   string
-	OnStateChange     func(state *NetworkInterfaceState)
-	OnInitialCheck    func(state *NetworkInterfaceState)
-	OnDhcpLeaseChange func(lease *udhcpc.Lease)
-	OnConfigChange    func(config *NetworkConfig)
-	NetworkConfig     *NetworkConfig
+	InterfaceName         string
+	DhcpPidFile           string
+	Logger                *zerolog.Logger
+	DefaultHostname       string
+	SystemDescription     string
+	OnStateChange         func(state *NetworkInterfaceState)
+	OnInitialCheck        func(state *NetworkInterfaceState)
+	OnDhcpLeaseChange     func(lease *udhcpc.Lease)
+	OnConfigChange        func(config *NetworkConfig)
+	OnLLDPNeighborsChange func(neighbors []LLDPNeighbor)
+	NetworkConfig         *NetworkConfig
 }
 
 func NewNetworkInterfaceState(opts *NetworkInterfaceOptions) (*NetworkInterfaceState, error) {
@@ -68,14 +75,15 @@ func NewNetworkInterfaceState(opts *NetworkInterfaceOptions) (*NetworkInterfaceS
 
 	l := opts.Logger
 	s := &NetworkInterfaceState{
-		interfaceName:   opts.InterfaceName,
-		defaultHostname: opts.DefaultHostname,
-		stateLock:       sync.Mutex{},
-		l:               l,
-		onStateChange:   opts.OnStateChange,
-		onInitialCheck:  opts.OnInitialCheck,
-		cbConfigChange:  opts.OnConfigChange,
-		config:          opts.NetworkConfig,
+		interfaceName:     opts.InterfaceName,
+		defaultHostname:   opts.DefaultHostname,
+		systemDescription: opts.SystemDescription,
+		stateLock:         sync.Mutex{},
+		l:                 l,
+		onStateChange:     opts.OnStateChange,
+		onInitialCheck:    opts.OnInitialCheck,
+		cbConfigChange:    opts.OnConfigChange,
+		config:            opts.NetworkConfig,
 	}
 
 	// create the dhcp client
@@ -98,6 +106,13 @@ func NewNetworkInterfaceState(opts *NetworkInterfaceOptions) (*NetworkInterfaceS
 
 	s.dhcpClient = dhcpClient
 
+	s.lldp = NewLLDP(&LLDPOptions{
+		InterfaceName: opts.InterfaceName,
+		Logger:        l,
+		GetLocalInfo:  s.lldpLocalInfo,
+		OnChange:      opts.OnLLDPNeighborsChange,
+	})
+
 	return s, nil
 }
 
@@ -146,7 +161,25 @@ func (s *NetworkInterfaceState) MACString() string {
 	return s.macAddr.String()
 }
 
+// NtpAddresses returns the NTP servers received from the DHCP server.
+func (s *NetworkInterfaceState) NtpAddresses() []string {
+	return s.dhcpClient.GetNtpServers()
+}
+
 func (s *NetworkInterfaceState) update() (DhcpTargetState, error) {
+	// the LLDP transmit reads the state under stateLock, so LLDP is only told about the
+	// link once the lock is released
+	var lldpLinkUp, lldpLinkDown bool
+	defer func() {
+		if lldpLinkUp {
+			s.lldp.TriggerTx()
+		}
+		if lldpLinkDown {
+			// the link partner might be a different switch once the link comes back
+			s.lldp.clearNeighbors()
+		}
+	}()
+
 	s.stateLock.Lock()
 	defer s.stateLock.Unlock()
 
@@ -177,14 +210,21 @@ func (s *NetworkInterfaceState) update() (DhcpTargetState, error) {
 		if interfaceGoingUp {
 			s.l.Info().Msg("interface state transitioned to up")
 			dhcpTargetState = DhcpTargetStateRenew
+			lldpLinkUp = true
 		} else if interfaceGoingDown {
 			s.l.Info().Msg("interface state transitioned to down")
+			lldpLinkDown = true
 		}
 	}
 
 	// set the mac address
 	s.macAddr = &attrs.HardwareAddr
 
+	// set the vlan id if the interface is a vlan interface
+	if vlan, ok := iface.(*netlink.Vlan); ok {
+		s.vlanID = uint16(vlan.VlanId)
+	}
+
 	// get the ip addresses
 	addrs, err := netlinkAddrs(iface)
 	if err != nil {
@@ -342,5 +382,39 @@ func (s *NetworkInterfaceState) CheckAndUpdateDhcp() error {
 
 func (s *NetworkInterfaceState) onConfigChange(config *NetworkConfig) {
 	_ = s.setHostnameIfNotSame()
+	s.updateLLDPConfig()
 	s.cbConfigChange(config)
 }
+
+func (s *NetworkInterfaceState) updateLLDPConfig() {
+	if err := s.lldp.SetConfig(s.config.LLDPMode.String, s.config.LLDPTxTLVs); err != nil {
+		s.l.Warn().Err(err).Msg("failed to apply LLDP config")
+	}
+}
+
+func (s *NetworkInterfaceState) lldpLocalInfo() LLDPLocalInfo {
+	info := LLDPLocalInfo{
+		PortName:          s.interfaceName,
+		PortDescription:   s.interfaceName,
+		SystemName:        s.GetFQDN(),
+		SystemDescription: s.systemDescription,
+	}
+
+	// called from the LLDP transmit goroutine while update() may be changing the addresses
+	s.stateLock.Lock()
+	defer s.stateLock.Unlock()
+
+	info.VLANID = s.vlanID
+
+	if s.macAddr != nil {
+		info.MACAddress = slices.Clone(*s.macAddr)
+	}
+
+	if s.ipv4Addr != nil {
+		info.ManagementAddress = slices.Clone(*s.ipv4Addr)
+	} else if s.ipv6Addr != nil {
+		info.ManagementAddress = slices.Clone(*s.ipv6Addr)
+	}
+
+	return info
+}
@@ -34,6 +34,8 @@ func (s *NetworkInterfaceState) Run() error {
 		return err
 	}
 
+	s.updateLLDPConfig()
+
 	go func() {
 		ticker := time.NewTicker(1 * time.Second)
 		defer ticker.Stop()
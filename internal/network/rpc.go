@@ -24,6 +24,7 @@ type RpcNetworkState struct {
 	IPv4Addresses []string         `json:"ipv4_addresses,omitempty"`
 	IPv6Addresses []RpcIPv6Address `json:"ipv6_addresses,omitempty"`
 	DHCPLease     *udhcpc.Lease    `json:"dhcp_lease,omitempty"`
+	LLDPNeighbors []LLDPNeighbor   `json:"lldp_neighbors,omitempty"`
 }
 
 type RpcNetworkSettings struct {
@@ -85,6 +86,7 @@ func (s *NetworkInterfaceState) RpcGetNetworkState() RpcNetworkState {
 		IPv4Addresses: s.ipv4Addresses,
 		IPv6Addresses: ipv6Addresses,
 		DHCPLease:     s.dhcpClient.GetLease(),
+		LLDPNeighbors: s.lldp.GetNeighbors(),
 	}
 }
 
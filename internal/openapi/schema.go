@@ -0,0 +1,198 @@
+// Package openapi builds OpenAPI 3 schemas from Go types, following the encoding/json rules.
+package openapi
+
+import (
+	"encoding"
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schema is a JSON schema object of an OpenAPI document.
+type Schema map[string]any
+
+var (
+	timeType            = reflect.TypeOf(time.Time{})
+	durationType        = reflect.TypeOf(time.Duration(0))
+	jsonMarshalerType   = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType   = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	rawMessageType      = reflect.TypeOf(json.RawMessage{})
+	componentsRefPrefix = "#/components/schemas/"
+)
+
+// Generator converts Go types to schemas. Named structs are added once to the
+// components and referenced from everywhere else, which also handles recursive types.
+type Generator struct {
+	components map[string]Schema
+	names      map[reflect.Type]string
+}
+
+func NewGenerator() *Generator {
+	return &Generator{
+		components: make(map[string]Schema),
+		names:      make(map[reflect.Type]string),
+	}
+}
+
+// Components returns the schemas referenced by the schemas generated so far.
+func (g *Generator) Components() map[string]Schema {
+	return g.components
+}
+
+// Schema returns the schema of the values of type t once encoded to JSON.
+func (g *Generator) Schema(t reflect.Type) Schema {
+	if t == nil {
+		return Schema{}
+	}
+
+	nullable := false
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+		nullable = true
+	}
+
+	schema := g.schema(t)
+	if nullable && schema["$ref"] == nil {
+		schema["nullable"] = true
+	}
+	return schema
+}
+
+func (g *Generator) schema(t reflect.Type) Schema {
+	switch t {
+	case timeType:
+		return Schema{"type": "string", "format": "date-time"}
+	case durationType:
+		return Schema{"type": "integer", "format": "int64", "description": "duration in nanoseconds"}
+	case rawMessageType:
+		return Schema{}
+	}
+
+	// the custom encodings can't be described from the type
+	if t.Implements(jsonMarshalerType) || reflect.PointerTo(t).Implements(jsonMarshalerType) {
+		return Schema{}
+	}
+	if t.Implements(textMarshalerType) || reflect.PointerTo(t).Implements(textMarshalerType) {
+		return Schema{"type": "string"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return Schema{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32:
+		return Schema{"type": "integer", "format": "int32"}
+	case reflect.Int64:
+		return Schema{"type": "integer", "format": "int64"}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return Schema{"type": "integer", "minimum": 0}
+	case reflect.Float32, reflect.Float64:
+		return Schema{"type": "number"}
+	case reflect.String:
+		return Schema{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		return Schema{"type": "array", "items": g.Schema(t.Elem())}
+	case reflect.Map:
+		return Schema{"type": "object", "additionalProperties": g.Schema(t.Elem())}
+	case reflect.Struct:
+		return g.structRef(t)
+	}
+
+	// interfaces can hold anything
+	return Schema{}
+}
+
+// structRef returns a reference to the component of a named struct, or the inline
+// schema of an anonymous one.
+func (g *Generator) structRef(t reflect.Type) Schema {
+	if t.Name() == "" {
+		return g.structSchema(t)
+	}
+
+	name, ok := g.names[t]
+	if !ok {
+		name = g.componentName(t)
+		g.names[t] = name
+		// set before generating the fields, so that recursive types end on the reference
+		g.components[name] = Schema{}
+		g.components[name] = g.structSchema(t)
+	}
+	return Schema{"$ref": componentsRefPrefix + name}
+}
+
+// componentName is the type name, prefixed with the package when another package
+// already uses the same name.
+func (g *Generator) componentName(t reflect.Type) string {
+	name := t.Name()
+	if _, taken := g.components[name]; !taken {
+		return name
+	}
+
+	pkg := t.PkgPath()
+	if idx := strings.LastIndex(pkg, "/"); idx != -1 {
+		pkg = pkg[idx+1:]
+	}
+	base := pkg + "." + name
+	name = base
+	for i := 2; ; i++ {
+		if _, taken := g.components[name]; !taken {
+			return name
+		}
+		name = base + strconv.Itoa(i)
+	}
+}
+
+func (g *Generator) structSchema(t reflect.Type) Schema {
+	properties := Schema{}
+	required := make([]string, 0)
+	g.addFields(t, properties, &required)
+
+	schema := Schema{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+// addFields adds the exported fields, the fields of the embedded structs are
+// promoted like encoding/json does.
+func (g *Generator) addFields(t reflect.Type, properties Schema, required *[]string) {
+	for i := range t.NumField() {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+
+		name, opts, _ := strings.Cut(tag, ",")
+		fieldType := field.Type
+		if field.Anonymous && name == "" {
+			for fieldType.Kind() == reflect.Pointer {
+				fieldType = fieldType.Elem()
+			}
+			if fieldType.Kind() == reflect.Struct {
+				g.addFields(fieldType, properties, required)
+				continue
+			}
+		}
+		if !field.IsExported() {
+			continue
+		}
+
+		if name == "" {
+			name = field.Name
+		}
+		properties[name] = g.Schema(field.Type)
+
+		omitEmpty := false
+		for _, opt := range strings.Split(opts, ",") {
+			if opt == "omitempty" || opt == "omitzero" {
+				omitEmpty = true
+			}
+		}
+		if !omitEmpty && field.Type.Kind() != reflect.Pointer {
+			*required = append(*required, name)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package openapi
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testBase struct {
+	ID string `json:"id"`
+}
+
+type testNode struct {
+	testBase
+	Name     string            `json:"name"`
+	Note     string            `json:"note,omitempty"`
+	Parent   *testNode         `json:"parent"`
+	Children []testNode        `json:"children"`
+	Labels   map[string]string `json:"labels"`
+	Created  time.Time         `json:"created"`
+	Keys     []uint8           `json:"keys"`
+	Ignored  string            `json:"-"`
+	internal string
+}
+
+func TestSchemaScalars(t *testing.T) {
+	g := NewGenerator()
+
+	assert.Equal(t, Schema{"type": "boolean"}, g.Schema(reflect.TypeOf(true)))
+	assert.Equal(t, Schema{"type": "string"}, g.Schema(reflect.TypeOf("")))
+	assert.Equal(t, Schema{"type": "number"}, g.Schema(reflect.TypeOf(1.5)))
+	assert.Equal(t, Schema{"type": "string", "nullable": true}, g.Schema(reflect.TypeOf((*string)(nil))))
+	assert.Equal(t, Schema{"type": "array", "items": Schema{"type": "integer", "minimum": 0}}, g.Schema(reflect.TypeOf([]uint8{})))
+	assert.Equal(t, Schema{}, g.Schema(reflect.TypeOf((*any)(nil)).Elem()))
+	assert.Empty(t, g.Components())
+}
+
+func TestSchemaStruct(t *testing.T) {
+	g := NewGenerator()
+
+	assert.Equal(t, Schema{"$ref": "#/components/schemas/testNode"}, g.Schema(reflect.TypeOf(testNode{})))
+
+	node := g.Components()["testNode"]
+	properties := node["properties"].(Schema)
+	assert.ElementsMatch(t, []string{"id", "name", "note", "parent", "children", "labels", "created", "keys"}, keys(properties))
+	assert.Equal(t, Schema{"$ref": "#/components/schemas/testNode"}, properties["parent"], "recursive types use a reference")
+	assert.Equal(t, Schema{"type": "string", "format": "date-time"}, properties["created"])
+	assert.Equal(t, []string{"id", "name", "children", "labels", "created", "keys"}, node["required"])
+}
+
+func keys(m Schema) []string {
+	result := make([]string, 0, len(m))
+	for k := range m {
+		result = append(result, k)
+	}
+	return result
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"os/exec"
+	"slices"
 	"sync"
 	"time"
 
@@ -28,9 +29,9 @@ type TimeSync struct {
 	syncLock *sync.Mutex
 	l        *zerolog.Logger
 
-	ntpServers    []string
-	httpUrls      []string
-	networkConfig *network.NetworkConfig
+	networkConfig     *network.NetworkConfig
+	networkConfigLock *sync.Mutex
+	dhcpNtpServers    func() []string
 
 	rtcDevicePath string
 	rtcDevice     *os.File //nolint:unused
@@ -42,9 +43,10 @@ type TimeSync struct {
 }
 
 type TimeSyncOptions struct {
-	PreCheckFunc  func() (bool, error)
-	Logger        *zerolog.Logger
-	NetworkConfig *network.NetworkConfig
+	PreCheckFunc   func() (bool, error)
+	Logger         *zerolog.Logger
+	NetworkConfig  *network.NetworkConfig
+	DhcpNtpServers func() []string
 }
 
 type SyncMode struct {
@@ -53,8 +55,33 @@ type SyncMode struct {
 	Ordering        []string
 	NtpUseFallback  bool
 	HttpUseFallback bool
+	NtpServers      []string
+	HttpUrls        []string
+	Parallel        int
 }
 
+// Time sources, in the order given by NetworkConfig.TimeSyncOrdering
+//
+//   - ntp_user_provided: the NTP servers in NetworkConfig.TimeSyncNTPServers
+//   - ntp_dhcp: the NTP servers received with the DHCP lease (option 42)
+//   - ntp_fallback: the built-in public NTP servers
+//   - ntp: shorthand for ntp_user_provided, ntp_dhcp and ntp_fallback
+//   - http: the URLs in NetworkConfig.TimeSyncHTTPUrls, then the built-in ones when
+//     none of them answered
+//
+// The built-in servers are skipped when NetworkConfig.TimeSyncDisableFallback is set.
+const (
+	TimeSourceNtp             = "ntp"
+	TimeSourceNtpDhcp         = "ntp_dhcp"
+	TimeSourceNtpUserProvided = "ntp_user_provided"
+	TimeSourceNtpFallback     = "ntp_fallback"
+	TimeSourceHttp            = "http"
+)
+
+var defaultTimeSyncOrdering = []string{TimeSourceNtp, TimeSourceHttp}
+
+const defaultTimeSyncParallel = 4
+
 func NewTimeSync(opts *TimeSyncOptions) *TimeSync {
 	rtcDevice, err := getRtcDevicePath()
 	if err != nil {
@@ -64,14 +91,14 @@ func NewTimeSync(opts *TimeSyncOptions) *TimeSync {
 	}
 
 	t := &TimeSync{
-		syncLock:      &sync.Mutex{},
-		l:             opts.Logger,
-		rtcDevicePath: rtcDevice,
-		rtcLock:       &sync.Mutex{},
-		preCheckFunc:  opts.PreCheckFunc,
-		ntpServers:    defaultNTPServers,
-		httpUrls:      defaultHTTPUrls,
-		networkConfig: opts.NetworkConfig,
+		syncLock:          &sync.Mutex{},
+		l:                 opts.Logger,
+		rtcDevicePath:     rtcDevice,
+		rtcLock:           &sync.Mutex{},
+		preCheckFunc:      opts.PreCheckFunc,
+		networkConfig:     opts.NetworkConfig,
+		networkConfigLock: &sync.Mutex{},
+		dhcpNtpServers:    opts.DhcpNtpServers,
 	}
 
 	if t.rtcDevicePath != "" {
@@ -82,10 +109,23 @@ func NewTimeSync(opts *TimeSyncOptions) *TimeSync {
 	return t
 }
 
+// SetNetworkConfig replaces the network config used for the next sync.
+func (t *TimeSync) SetNetworkConfig(networkConfig *network.NetworkConfig) {
+	t.networkConfigLock.Lock()
+	defer t.networkConfigLock.Unlock()
+
+	t.networkConfig = networkConfig
+}
+
 func (t *TimeSync) getSyncMode() SyncMode {
+	t.networkConfigLock.Lock()
+	defer t.networkConfigLock.Unlock()
+
 	syncMode := SyncMode{
 		NtpUseFallback:  true,
 		HttpUseFallback: true,
+		Ordering:        defaultTimeSyncOrdering,
+		Parallel:        defaultTimeSyncParallel,
 	}
 	var syncModeString string
 
@@ -95,6 +135,17 @@ func (t *TimeSync) getSyncMode() SyncMode {
 			syncMode.NtpUseFallback = false
 			syncMode.HttpUseFallback = false
 		}
+
+		if len(t.networkConfig.TimeSyncOrdering) > 0 {
+			syncMode.Ordering = t.networkConfig.TimeSyncOrdering
+		}
+
+		if t.networkConfig.TimeSyncParallel.Int64 > 0 {
+			syncMode.Parallel = int(t.networkConfig.TimeSyncParallel.Int64)
+		}
+
+		syncMode.NtpServers = t.networkConfig.TimeSyncNTPServers
+		syncMode.HttpUrls = t.networkConfig.TimeSyncHTTPUrls
 	}
 
 	switch syncModeString {
@@ -110,6 +161,56 @@ func (t *TimeSync) getSyncMode() SyncMode {
 	return syncMode
 }
 
+func (t *TimeSync) getParallelism() int {
+	return t.getSyncMode().Parallel
+}
+
+func (t *TimeSync) getDhcpNtpServers() []string {
+	if t.dhcpNtpServers == nil {
+		return nil
+	}
+
+	return t.dhcpNtpServers()
+}
+
+// expandTimeSources expands the shorthand sources of the ordering and drops
+// the ones disabled by the sync mode, keeping only the first occurrence of each.
+func expandTimeSources(syncMode SyncMode) []string {
+	sources := make([]string, 0)
+	add := func(source string) {
+		if !slices.Contains(sources, source) {
+			sources = append(sources, source)
+		}
+	}
+
+	for _, source := range syncMode.Ordering {
+		switch source {
+		case TimeSourceNtp:
+			if syncMode.Ntp {
+				add(TimeSourceNtpUserProvided)
+				add(TimeSourceNtpDhcp)
+				if syncMode.NtpUseFallback {
+					add(TimeSourceNtpFallback)
+				}
+			}
+		case TimeSourceNtpUserProvided, TimeSourceNtpDhcp:
+			if syncMode.Ntp {
+				add(source)
+			}
+		case TimeSourceNtpFallback:
+			if syncMode.Ntp && syncMode.NtpUseFallback {
+				add(source)
+			}
+		case TimeSourceHttp:
+			if syncMode.Http {
+				add(source)
+			}
+		}
+	}
+
+	return sources
+}
+
 func (t *TimeSync) doTimeSync() {
 	metricTimeSyncStatus.Set(0)
 	for {
@@ -158,12 +259,44 @@ func (t *TimeSync) Sync() error {
 
 	metricTimeSyncCount.Inc()
 
-	if syncMode.Ntp {
-		now, offset = t.queryNetworkTime()
-	}
+	for _, source := range expandTimeSources(syncMode) {
+		scopedLogger := t.l.With().Str("source", source).Logger()
+
+		switch source {
+		case TimeSourceNtpUserProvided:
+			if len(syncMode.NtpServers) == 0 {
+				scopedLogger.Debug().Msg("no user provided NTP servers, skipping")
+				continue
+			}
+			now, offset = t.queryNetworkTime(syncMode.NtpServers)
+		case TimeSourceNtpDhcp:
+			dhcpNtpServers := t.getDhcpNtpServers()
+			if len(dhcpNtpServers) == 0 {
+				scopedLogger.Debug().Msg("no NTP servers received from DHCP, skipping")
+				continue
+			}
+			now, offset = t.queryNetworkTime(dhcpNtpServers)
+		case TimeSourceNtpFallback:
+			now, offset = t.queryNetworkTime(defaultNTPServers)
+		case TimeSourceHttp:
+			// the user provided URLs are shuffled among themselves only, the built-in
+			// ones are a fallback queried when none of them answered
+			if len(syncMode.HttpUrls) > 0 {
+				now = t.queryAllHttpTime(syncMode.HttpUrls)
+			} else {
+				scopedLogger.Debug().Msg("no user provided HTTP URLs")
+			}
+			if now == nil && syncMode.HttpUseFallback {
+				now = t.queryAllHttpTime(defaultHTTPUrls)
+			}
+		}
+
+		if now != nil {
+			scopedLogger.Info().Str("time", now.Format(time.RFC3339)).Msg("time obtained")
+			break
+		}
 
-	if syncMode.Http && now == nil {
-		now = t.queryAllHttpTime()
+		scopedLogger.Warn().Msg("failed to get time from source")
 	}
 
 	if now == nil {
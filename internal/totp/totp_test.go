@@ -0,0 +1,62 @@
+package totp
+
+import (
+	"encoding/base32"
+	"strings"
+	"testing"
+	"time"
+)
+
+// the SHA1 test vectors of RFC 6238 appendix B, truncated to 6 digits
+var rfcSecret = base32.StdEncoding.EncodeToString([]byte("12345678901234567890"))
+
+func TestGenerateCodeRFC6238(t *testing.T) {
+	vectors := map[int64]string{
+		59:          "287082",
+		1111111109:  "081804",
+		1111111111:  "050471",
+		1234567890:  "005924",
+		2000000000:  "279037",
+		20000000000: "353130",
+	}
+
+	for unix, expected := range vectors {
+		code, err := GenerateCode(rfcSecret, Step(time.Unix(unix, 0)))
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if code != expected {
+			t.Fatalf("expected code at %d to be %s, got %s", unix, expected, code)
+		}
+	}
+}
+
+func TestValidateSkew(t *testing.T) {
+	now := time.Unix(1111111109, 0)
+
+	previous, _ := GenerateCode(rfcSecret, Step(now)-1)
+	if _, ok := Validate(rfcSecret, previous, now, 1); !ok {
+		t.Fatalf("expected the previous code to be accepted with a skew of 1")
+	}
+	if _, ok := Validate(rfcSecret, previous, now, 0); ok {
+		t.Fatalf("expected the previous code to be rejected without skew")
+	}
+	if _, ok := Validate(rfcSecret, "12345", now, 1); ok {
+		t.Fatalf("expected a short code to be rejected")
+	}
+}
+
+func TestGenerateSecretAndURI(t *testing.T) {
+	secret, err := GenerateSecret()
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(secret) != 32 {
+		t.Fatalf("expected a 32 characters secret, got %d", len(secret))
+	}
+
+	uri := URI("JetKVM", "jetkvm-1", secret)
+	if !strings.HasPrefix(uri, "otpauth://totp/JetKVM:jetkvm-1?") || !strings.Contains(uri, "secret="+secret) {
+		t.Fatalf("unexpected uri: %s", uri)
+	}
+}
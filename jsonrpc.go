@@ -1,6 +1,7 @@
 package kvm
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
@@ -9,12 +10,16 @@ import (
 	"os/exec"
 	"path/filepath"
 	"reflect"
+	"slices"
 	"strconv"
+	"sync"
 	"time"
 
-	"github.com/pion/webrtc/v4"
 	"go.bug.st/serial"
 
+	"github.com/jetkvm/kvm/internal/httpimage"
+	"github.com/jetkvm/kvm/internal/keyboard"
+	"github.com/jetkvm/kvm/internal/macros"
 	"github.com/jetkvm/kvm/internal/usbgadget"
 )
 
@@ -23,6 +28,31 @@ type JSONRPCRequest struct {
 	Method  string                 `json:"method"`
 	Params  map[string]interface{} `json:"params,omitempty"`
 	ID      interface{}            `json:"id,omitempty"`
+
+	// notification is set when the request has no id member, a null id still gets a response
+	notification bool
+}
+
+func (r *JSONRPCRequest) UnmarshalJSON(data []byte) error {
+	type request JSONRPCRequest
+	// the id of the outer struct shadows the one of the request, so that an absent id
+	// can be told from a null one
+	var fields struct {
+		request
+		ID json.RawMessage `json:"id"`
+	}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+
+	*r = JSONRPCRequest(fields.request)
+	r.notification = fields.ID == nil
+	if !r.notification {
+		if err := json.Unmarshal(fields.ID, &r.ID); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 type JSONRPCResponse struct {
@@ -38,6 +68,12 @@ type JSONRPCEvent struct {
 	Params  interface{} `json:"params,omitempty"`
 }
 
+// RPCTransport carries the JSON-RPC messages of a session, a WebRTC data channel
+// or a websocket.
+type RPCTransport interface {
+	SendText(message string) error
+}
+
 type DisplayRotationSettings struct {
 	Rotation string `json:"rotation"`
 }
@@ -48,12 +84,17 @@ type BacklightSettings struct {
 	OffAfter      int `json:"off_after"`
 }
 
-func writeJSONRPCResponse(response JSONRPCResponse, session *Session) {
+// writeJSONRPCResponse sends a response, or the array of responses of a batch.
+func writeJSONRPCResponse(response interface{}, session *Session) {
 	responseBytes, err := json.Marshal(response)
 	if err != nil {
 		jsonRpcLogger.Warn().Err(err).Msg("Error marshalling JSONRPC response")
 		return
 	}
+	if session == nil || session.RPCChannel == nil {
+		jsonRpcLogger.Info().Msg("RPC channel not available")
+		return
+	}
 	err = session.RPCChannel.SendText(string(responseBytes))
 	if err != nil {
 		jsonRpcLogger.Warn().Err(err).Msg("Error sending JSONRPC response")
@@ -91,27 +132,103 @@ func writeJSONRPCEvent(event string, params interface{}, session *Session) {
 	}
 }
 
-func onRPCMessage(message webrtc.DataChannelMessage, session *Session) {
+func newJSONRPCError(id interface{}, code int, message string, data interface{}) *JSONRPCResponse {
+	rpcError := map[string]interface{}{
+		"code":    code,
+		"message": message,
+	}
+	if data != nil {
+		rpcError["data"] = data
+	}
+	return &JSONRPCResponse{
+		JSONRPC: "2.0",
+		Error:   rpcError,
+		ID:      id,
+	}
+}
+
+// handleRPCRequest dispatches a JSON-RPC request or a batch of requests received from
+// the session, whatever its transport, and sends the responses back.
+func handleRPCRequest(data []byte, session *Session) {
+	data = bytes.TrimSpace(data)
+	if len(data) > 0 && data[0] == '[' {
+		handleRPCBatch(data, session)
+		return
+	}
+
 	var request JSONRPCRequest
-	err := json.Unmarshal(message.Data, &request)
-	if err != nil {
+	if err := json.Unmarshal(data, &request); err != nil {
 		jsonRpcLogger.Warn().
-			Str("data", string(message.Data)).
+			Str("data", string(data)).
 			Err(err).
 			Msg("Error unmarshalling JSONRPC request")
-
-		errorResponse := JSONRPCResponse{
-			JSONRPC: "2.0",
-			Error: map[string]interface{}{
-				"code":    -32700,
-				"message": "Parse error",
-			},
-			ID: 0,
+		if json.Valid(data) {
+			writeJSONRPCResponse(newJSONRPCError(nil, -32600, "Invalid Request", err.Error()), session)
+		} else {
+			writeJSONRPCResponse(newJSONRPCError(nil, -32700, "Parse error", nil), session)
 		}
-		writeJSONRPCResponse(errorResponse, session)
 		return
 	}
 
+	if response := processRPCRequest(request, session); response != nil {
+		writeJSONRPCResponse(response, session)
+	}
+}
+
+// handleRPCBatch runs the requests of a batch concurrently and sends their responses
+// in a single array, nothing is sent when the batch only holds notifications.
+func handleRPCBatch(data []byte, session *Session) {
+	var batch []json.RawMessage
+	if err := json.Unmarshal(data, &batch); err != nil {
+		writeJSONRPCResponse(newJSONRPCError(nil, -32700, "Parse error", nil), session)
+		return
+	}
+	if len(batch) == 0 {
+		writeJSONRPCResponse(newJSONRPCError(nil, -32600, "Invalid Request", "empty batch"), session)
+		return
+	}
+	if len(batch) > maxRPCBatchSize {
+		writeJSONRPCResponse(newJSONRPCError(nil, -32600, "Invalid Request", fmt.Sprintf("batch too large (max %d)", maxRPCBatchSize)), session)
+		return
+	}
+
+	responses := make([]*JSONRPCResponse, len(batch))
+	var wg sync.WaitGroup
+	for i, item := range batch {
+		var request JSONRPCRequest
+		if err := json.Unmarshal(item, &request); err != nil {
+			responses[i] = newJSONRPCError(nil, -32600, "Invalid Request", nil)
+			continue
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			responses[i] = processRPCRequest(request, session)
+		}()
+	}
+	wg.Wait()
+
+	responses = slices.DeleteFunc(responses, func(r *JSONRPCResponse) bool { return r == nil })
+	if len(responses) > 0 {
+		writeJSONRPCResponse(responses, session)
+	}
+}
+
+// processRPCRequest calls the handler of the request and returns its response, or nil
+// for a notification. Only the requests without an id are notifications.
+func processRPCRequest(request JSONRPCRequest, session *Session) *JSONRPCResponse {
+	respond := func(response *JSONRPCResponse) *JSONRPCResponse {
+		if request.notification {
+			return nil
+		}
+		return response
+	}
+
+	if request.JSONRPC != "2.0" || request.Method == "" {
+		return respond(newJSONRPCError(request.ID, -32600, "Invalid Request", nil))
+	}
+
 	scopedLogger := jsonRpcLogger.With().
 		Str("method", request.Method).
 		Interface("params", request.Params).
@@ -121,43 +238,43 @@ func onRPCMessage(message webrtc.DataChannelMessage, session *Session) {
 
 	handler, ok := rpcHandlers[request.Method]
 	if !ok {
-		errorResponse := JSONRPCResponse{
-			JSONRPC: "2.0",
-			Error: map[string]interface{}{
-				"code":    -32601,
-				"message": "Method not found",
-			},
-			ID: request.ID,
-		}
-		writeJSONRPCResponse(errorResponse, session)
-		return
+		return respond(newJSONRPCError(request.ID, -32601, "Method not found", nil))
+	}
+
+	if !session.Role.allows(handler.Permission) {
+		auditRPCCall(session, request.Method, request.Params, "denied", "permission denied")
+		return respond(newJSONRPCError(request.ID, -32002, "Permission denied", nil))
+	}
+
+	if handler.RequiresControl && !isSessionController(session) {
+		return respond(newJSONRPCError(request.ID, -32001, "Session does not have control", nil))
 	}
 
 	scopedLogger.Trace().Msg("Calling RPC handler")
-	result, err := callRPCHandler(handler, request.Params)
-	if err != nil {
+	result, err := invokeRPCHandler(context.Background(), handler, request.Params, session)
+	if shouldAuditRPC(request.Method, handler) {
+		status, errMessage := auditResult(err)
+		auditRPCCall(session, request.Method, request.Params, status, errMessage)
+	}
+	switch {
+	case errors.Is(err, errInvalidParams):
+		scopedLogger.Warn().Err(err).Msg("Invalid RPC params")
+		return respond(newJSONRPCError(request.ID, -32602, "Invalid params", err.Error()))
+	case errors.Is(err, errRPCTimeout):
+		scopedLogger.Warn().Dur("timeout", handler.timeout()).Msg("RPC handler timed out")
+		return respond(newJSONRPCError(request.ID, -32003, "Request timed out", err.Error()))
+	case err != nil:
 		scopedLogger.Error().Err(err).Msg("Error calling RPC handler")
-		errorResponse := JSONRPCResponse{
-			JSONRPC: "2.0",
-			Error: map[string]interface{}{
-				"code":    -32603,
-				"message": "Internal error",
-				"data":    err.Error(),
-			},
-			ID: request.ID,
-		}
-		writeJSONRPCResponse(errorResponse, session)
-		return
+		return respond(newJSONRPCError(request.ID, -32603, "Internal error", err.Error()))
 	}
 
 	scopedLogger.Trace().Interface("result", result).Msg("RPC handler returned")
 
-	response := JSONRPCResponse{
+	return respond(&JSONRPCResponse{
 		JSONRPC: "2.0",
 		Result:  result,
 		ID:      request.ID,
-	}
-	writeJSONRPCResponse(response, session)
+	})
 }
 
 func rpcPing() (string, error) {
@@ -198,9 +315,9 @@ func rpcGetStreamQualityFactor() (float64, error) {
 	return streamFactor, nil
 }
 
-func rpcSetStreamQualityFactor(factor float64) error {
+func rpcSetStreamQualityFactor(ctx context.Context, factor float64) error {
 	logger.Info().Float64("factor", factor).Msg("Setting stream quality factor")
-	var _, err = CallCtrlAction("set_video_quality_factor", map[string]interface{}{"quality_factor": factor})
+	var _, err = CallCtrlActionContext(ctx, "set_video_quality_factor", map[string]interface{}{"quality_factor": factor})
 	if err != nil {
 		return err
 	}
@@ -221,8 +338,8 @@ func rpcSetAutoUpdateState(enabled bool) (bool, error) {
 	return enabled, nil
 }
 
-func rpcGetEDID() (string, error) {
-	resp, err := CallCtrlAction("get_edid", nil)
+func rpcGetEDID(ctx context.Context) (string, error) {
+	resp, err := CallCtrlActionContext(ctx, "get_edid", nil)
 	if err != nil {
 		return "", err
 	}
@@ -233,14 +350,14 @@ func rpcGetEDID() (string, error) {
 	return "", errors.New("EDID not found in response")
 }
 
-func rpcSetEDID(edid string) error {
+func rpcSetEDID(ctx context.Context, edid string) error {
 	if edid == "" {
 		logger.Info().Msg("Restoring EDID to default")
 		edid = "00ffffffffffff0052620188008888881c150103800000780a0dc9a05747982712484c00000001010101010101010101010101010101023a801871382d40582c4500c48e2100001e011d007251d01e206e285500c48e2100001e000000fc00543734392d6648443732300a20000000fd00147801ff1d000a202020202020017b"
 	} else {
 		logger.Info().Str("edid", edid).Msg("Setting EDID")
 	}
-	_, err := CallCtrlAction("set_edid", map[string]interface{}{"edid": edid})
+	_, err := CallCtrlActionContext(ctx, "set_edid", map[string]interface{}{"edid": edid})
 	if err != nil {
 		return err
 	}
@@ -263,9 +380,9 @@ func rpcSetDevChannelState(enabled bool) error {
 	return nil
 }
 
-func rpcGetUpdateStatus() (*UpdateStatus, error) {
+func rpcGetUpdateStatus(ctx context.Context) (*UpdateStatus, error) {
 	includePreRelease := config.IncludePreRelease
-	updateStatus, err := GetUpdateStatus(context.Background(), GetDeviceID(), includePreRelease)
+	updateStatus, err := GetUpdateStatus(ctx, GetDeviceID(), includePreRelease)
 	// to ensure backwards compatibility,
 	// if there's an error, we won't return an error, but we will set the error field
 	if err != nil {
@@ -469,10 +586,69 @@ func rpcSetTLSState(state TLSState) error {
 type RPCHandler struct {
 	Func   interface{}
 	Params []string
+	// RequiresControl restricts the handler to the session holding the control,
+	// viewer sessions get an error instead.
+	RequiresControl bool
+	// Permission is what the role of the session needs to call the handler
+	Permission Permission
+	// Timeout is how long the caller waits for the handler, defaultRPCTimeout when zero.
+	// Handlers taking a context.Context get it cancelled at the deadline.
+	Timeout time.Duration
+}
+
+const (
+	defaultRPCTimeout = 30 * time.Second
+	maxRPCBatchSize   = 64
+)
+
+var (
+	sessionType = reflect.TypeOf((*Session)(nil))
+	contextType = reflect.TypeOf((*context.Context)(nil)).Elem()
+)
+
+var (
+	// errRPCTimeout is returned when the handler didn't return before its deadline,
+	// the -32003 error of JSON-RPC
+	errRPCTimeout = errors.New("request timed out")
+	// errInvalidParams wraps the errors caused by the params of a call rather than by
+	// the handler, the -32602 error of JSON-RPC
+	errInvalidParams = errors.New("invalid params")
+)
+
+func (h RPCHandler) timeout() time.Duration {
+	if h.Timeout > 0 {
+		return h.Timeout
+	}
+	return defaultRPCTimeout
+}
+
+// invokeRPCHandler calls the handler with its deadline. A handler ignoring the context
+// keeps running after the deadline, but the caller gets errRPCTimeout instead of waiting,
+// the handlers calling the native process or the network should take the context.
+func invokeRPCHandler(ctx context.Context, handler RPCHandler, params map[string]interface{}, session *Session) (interface{}, error) {
+	ctx, cancel := context.WithTimeout(ctx, handler.timeout())
+	defer cancel()
+
+	type callResult struct {
+		result interface{}
+		err    error
+	}
+	done := make(chan callResult, 1)
+	go func() {
+		result, err := callRPCHandler(ctx, handler, params, session)
+		done <- callResult{result, err}
+	}()
+
+	select {
+	case r := <-done:
+		return r.result, r.err
+	case <-ctx.Done():
+		return nil, fmt.Errorf("%w after %s", errRPCTimeout, handler.timeout())
+	}
 }
 
 // call the handler but recover from a panic to ensure our RPC thread doesn't collapse on malformed calls
-func callRPCHandler(handler RPCHandler, params map[string]interface{}) (result interface{}, err error) {
+func callRPCHandler(ctx context.Context, handler RPCHandler, params map[string]interface{}, session *Session) (result interface{}, err error) {
 	// Use defer to recover from a panic
 	defer func() {
 		if r := recover(); r != nil {
@@ -486,11 +662,11 @@ func callRPCHandler(handler RPCHandler, params map[string]interface{}) (result i
 	}()
 
 	// Call the handler
-	result, err = riskyCallRPCHandler(handler, params)
+	result, err = riskyCallRPCHandler(ctx, handler, params, session)
 	return result, err
 }
 
-func riskyCallRPCHandler(handler RPCHandler, params map[string]interface{}) (interface{}, error) {
+func riskyCallRPCHandler(ctx context.Context, handler RPCHandler, params map[string]interface{}, session *Session) (interface{}, error) {
 	handlerValue := reflect.ValueOf(handler.Func)
 	handlerType := handlerValue.Type()
 
@@ -499,23 +675,38 @@ func riskyCallRPCHandler(handler RPCHandler, params map[string]interface{}) (int
 	}
 
 	numParams := handlerType.NumIn()
-	args := make([]reflect.Value, numParams)
+	args := make([]reflect.Value, 0, numParams)
+
+	// handlers taking a *Session as first argument get the calling session,
+	// then the ones taking a context.Context get the context of the call
+	if numParams > 0 && handlerType.In(0) == sessionType {
+		args = append(args, reflect.ValueOf(session))
+	}
+	if numParams > len(args) && handlerType.In(len(args)) == contextType {
+		args = append(args, reflect.ValueOf(ctx))
+	}
+	offset := len(args)
+	args = args[:numParams]
+
 	// Get the parameter names from the RPCHandler
 	paramNames := handler.Params
 
-	if len(paramNames) != numParams {
+	if len(paramNames) != numParams-offset {
 		return nil, errors.New("mismatch between handler parameters and defined parameter names")
 	}
 
-	for i := 0; i < numParams; i++ {
+	for i := offset; i < numParams; i++ {
 		paramType := handlerType.In(i)
-		paramName := paramNames[i]
+		paramName := paramNames[i-offset]
 		paramValue, ok := params[paramName]
 		if !ok {
-			return nil, errors.New("missing parameter: " + paramName)
+			return nil, fmt.Errorf("%w: missing parameter: %s", errInvalidParams, paramName)
 		}
 
 		convertedValue := reflect.ValueOf(paramValue)
+		if !convertedValue.IsValid() {
+			return nil, fmt.Errorf("%w: null parameter: %s", errInvalidParams, paramName)
+		}
 		if !convertedValue.Type().ConvertibleTo(paramType) {
 			if paramType.Kind() == reflect.Slice && (convertedValue.Kind() == reflect.Slice || convertedValue.Kind() == reflect.Array) {
 				newSlice := reflect.MakeSlice(paramType, convertedValue.Len(), convertedValue.Len())
@@ -524,18 +715,21 @@ func riskyCallRPCHandler(handler RPCHandler, params map[string]interface{}) (int
 					if elemValue.Kind() == reflect.Interface {
 						elemValue = elemValue.Elem()
 					}
+					if !elemValue.IsValid() {
+						return nil, fmt.Errorf("%w: null element in slice for parameter %s", errInvalidParams, paramName)
+					}
 					if !elemValue.Type().ConvertibleTo(paramType.Elem()) {
 						// Handle float64 to uint8 conversion
 						if elemValue.Kind() == reflect.Float64 && paramType.Elem().Kind() == reflect.Uint8 {
 							intValue := int(elemValue.Float())
 							if intValue < 0 || intValue > 255 {
-								return nil, fmt.Errorf("value out of range for uint8: %v", intValue)
+								return nil, fmt.Errorf("%w: value out of range for uint8: %v", errInvalidParams, intValue)
 							}
 							newSlice.Index(j).SetUint(uint64(intValue))
 						} else {
 							fromType := elemValue.Type()
 							toType := paramType.Elem()
-							return nil, fmt.Errorf("invalid element type in slice for parameter %s: from %v to %v", paramName, fromType, toType)
+							return nil, fmt.Errorf("%w: invalid element type in slice for parameter %s: from %v to %v", errInvalidParams, paramName, fromType, toType)
 						}
 					} else {
 						newSlice.Index(j).Set(elemValue.Convert(paramType.Elem()))
@@ -550,11 +744,11 @@ func riskyCallRPCHandler(handler RPCHandler, params map[string]interface{}) (int
 
 				newStruct := reflect.New(paramType).Interface()
 				if err := json.Unmarshal(jsonData, newStruct); err != nil {
-					return nil, fmt.Errorf("failed to unmarshal JSON into struct: %v", err)
+					return nil, fmt.Errorf("%w: failed to unmarshal JSON into struct: %v", errInvalidParams, err)
 				}
 				args[i] = reflect.ValueOf(newStruct).Elem()
 			} else {
-				return nil, fmt.Errorf("invalid parameter type for: %s, type: %s", paramName, paramType.Kind())
+				return nil, fmt.Errorf("%w: invalid parameter type for: %s, type: %s", errInvalidParams, paramName, paramType.Kind())
 			}
 		} else {
 			args[i] = convertedValue.Convert(paramType)
@@ -718,6 +912,7 @@ func rpcSetActiveExtension(extensionId string) error {
 	if err := SaveConfig(); err != nil {
 		return fmt.Errorf("failed to save config: %w", err)
 	}
+	publishMQTTState("extension", extensionId)
 	switch extensionId {
 	case "atx-power":
 		_ = mountATXControl()
@@ -906,6 +1101,9 @@ func rpcGetKeyboardLayout() (string, error) {
 }
 
 func rpcSetKeyboardLayout(layout string) error {
+	if _, ok := keyboard.Get(layout); !ok {
+		return fmt.Errorf("unknown keyboard layout %s", layout)
+	}
 	config.KeyboardLayout = layout
 	if err := SaveConfig(); err != nil {
 		return fmt.Errorf("failed to save config: %w", err)
@@ -929,6 +1127,10 @@ func setKeyboardMacros(params KeyboardMacrosParams) (interface{}, error) {
 		return nil, fmt.Errorf("missing or invalid macros parameter")
 	}
 
+	if len(params.Macros) > macros.MaxMacrosPerDevice {
+		return nil, fmt.Errorf("too many macros (max %d)", macros.MaxMacrosPerDevice)
+	}
+
 	newMacros := make([]KeyboardMacro, 0, len(params.Macros))
 
 	for i, item := range params.Macros {
@@ -937,59 +1139,22 @@ func setKeyboardMacros(params KeyboardMacrosParams) (interface{}, error) {
 			return nil, fmt.Errorf("invalid macro at index %d", i)
 		}
 
-		id, _ := macroMap["id"].(string)
-		if id == "" {
-			id = fmt.Sprintf("macro-%d", time.Now().UnixNano())
+		// the steps are decoded as stored, so that every step type is kept
+		macroJSON, err := json.Marshal(macroMap)
+		if err != nil {
+			return nil, fmt.Errorf("invalid macro at index %d: %w", i, err)
 		}
-
-		name, _ := macroMap["name"].(string)
-
-		sortOrder := i + 1
-		if sortOrderFloat, ok := macroMap["sortOrder"].(float64); ok {
-			sortOrder = int(sortOrderFloat)
+		var macro KeyboardMacro
+		if err := json.Unmarshal(macroJSON, &macro); err != nil {
+			return nil, fmt.Errorf("invalid macro at index %d: %w", i, err)
 		}
 
-		steps := []KeyboardMacroStep{}
-		if stepsArray, ok := macroMap["steps"].([]interface{}); ok {
-			for _, stepItem := range stepsArray {
-				stepMap, ok := stepItem.(map[string]interface{})
-				if !ok {
-					continue
-				}
-
-				step := KeyboardMacroStep{}
-
-				if keysArray, ok := stepMap["keys"].([]interface{}); ok {
-					for _, k := range keysArray {
-						if keyStr, ok := k.(string); ok {
-							step.Keys = append(step.Keys, keyStr)
-						}
-					}
-				}
-
-				if modsArray, ok := stepMap["modifiers"].([]interface{}); ok {
-					for _, m := range modsArray {
-						if modStr, ok := m.(string); ok {
-							step.Modifiers = append(step.Modifiers, modStr)
-						}
-					}
-				}
-
-				if delay, ok := stepMap["delay"].(float64); ok {
-					step.Delay = int(delay)
-				}
-
-				steps = append(steps, step)
-			}
+		if macro.ID == "" {
+			macro.ID = fmt.Sprintf("macro-%d", time.Now().UnixNano())
 		}
-
-		macro := KeyboardMacro{
-			ID:        id,
-			Name:      name,
-			Steps:     steps,
-			SortOrder: sortOrder,
+		if _, ok := macroMap["sortOrder"].(float64); !ok {
+			macro.SortOrder = i + 1
 		}
-
 		if err := macro.Validate(); err != nil {
 			return nil, fmt.Errorf("invalid macro at index %d: %w", i, err)
 		}
@@ -1026,82 +1191,145 @@ func rpcSetLocalLoopbackOnly(enabled bool) error {
 }
 
 var rpcHandlers = map[string]RPCHandler{
-	"ping":                   {Func: rpcPing},
-	"reboot":                 {Func: rpcReboot, Params: []string{"force"}},
-	"getDeviceID":            {Func: rpcGetDeviceID},
-	"deregisterDevice":       {Func: rpcDeregisterDevice},
-	"getCloudState":          {Func: rpcGetCloudState},
-	"getNetworkState":        {Func: rpcGetNetworkState},
-	"getNetworkSettings":     {Func: rpcGetNetworkSettings},
-	"setNetworkSettings":     {Func: rpcSetNetworkSettings, Params: []string{"settings"}},
-	"renewDHCPLease":         {Func: rpcRenewDHCPLease},
-	"keyboardReport":         {Func: rpcKeyboardReport, Params: []string{"modifier", "keys"}},
-	"getKeyboardLedState":    {Func: rpcGetKeyboardLedState},
-	"absMouseReport":         {Func: rpcAbsMouseReport, Params: []string{"x", "y", "buttons"}},
-	"relMouseReport":         {Func: rpcRelMouseReport, Params: []string{"dx", "dy", "buttons"}},
-	"wheelReport":            {Func: rpcWheelReport, Params: []string{"wheelY"}},
-	"getVideoState":          {Func: rpcGetVideoState},
-	"getUSBState":            {Func: rpcGetUSBState},
-	"unmountImage":           {Func: rpcUnmountImage},
-	"rpcMountBuiltInImage":   {Func: rpcMountBuiltInImage, Params: []string{"filename"}},
-	"setJigglerState":        {Func: rpcSetJigglerState, Params: []string{"enabled"}},
-	"getJigglerState":        {Func: rpcGetJigglerState},
-	"sendWOLMagicPacket":     {Func: rpcSendWOLMagicPacket, Params: []string{"macAddress"}},
-	"getStreamQualityFactor": {Func: rpcGetStreamQualityFactor},
-	"setStreamQualityFactor": {Func: rpcSetStreamQualityFactor, Params: []string{"factor"}},
-	"getAutoUpdateState":     {Func: rpcGetAutoUpdateState},
-	"setAutoUpdateState":     {Func: rpcSetAutoUpdateState, Params: []string{"enabled"}},
-	"getEDID":                {Func: rpcGetEDID},
-	"setEDID":                {Func: rpcSetEDID, Params: []string{"edid"}},
-	"getDevChannelState":     {Func: rpcGetDevChannelState},
-	"setDevChannelState":     {Func: rpcSetDevChannelState, Params: []string{"enabled"}},
-	"getUpdateStatus":        {Func: rpcGetUpdateStatus},
-	"tryUpdate":              {Func: rpcTryUpdate},
-	"getDevModeState":        {Func: rpcGetDevModeState},
-	"setDevModeState":        {Func: rpcSetDevModeState, Params: []string{"enabled"}},
-	"getSSHKeyState":         {Func: rpcGetSSHKeyState},
-	"setSSHKeyState":         {Func: rpcSetSSHKeyState, Params: []string{"sshKey"}},
-	"getTLSState":            {Func: rpcGetTLSState},
-	"setTLSState":            {Func: rpcSetTLSState, Params: []string{"state"}},
-	"setMassStorageMode":     {Func: rpcSetMassStorageMode, Params: []string{"mode"}},
-	"getMassStorageMode":     {Func: rpcGetMassStorageMode},
-	"isUpdatePending":        {Func: rpcIsUpdatePending},
-	"getUsbEmulationState":   {Func: rpcGetUsbEmulationState},
-	"setUsbEmulationState":   {Func: rpcSetUsbEmulationState, Params: []string{"enabled"}},
-	"getUsbConfig":           {Func: rpcGetUsbConfig},
-	"setUsbConfig":           {Func: rpcSetUsbConfig, Params: []string{"usbConfig"}},
-	"checkMountUrl":          {Func: rpcCheckMountUrl, Params: []string{"url"}},
-	"getVirtualMediaState":   {Func: rpcGetVirtualMediaState},
-	"getStorageSpace":        {Func: rpcGetStorageSpace},
-	"mountWithHTTP":          {Func: rpcMountWithHTTP, Params: []string{"url", "mode"}},
-	"mountWithWebRTC":        {Func: rpcMountWithWebRTC, Params: []string{"filename", "size", "mode"}},
-	"mountWithStorage":       {Func: rpcMountWithStorage, Params: []string{"filename", "mode"}},
-	"listStorageFiles":       {Func: rpcListStorageFiles},
-	"deleteStorageFile":      {Func: rpcDeleteStorageFile, Params: []string{"filename"}},
-	"startStorageFileUpload": {Func: rpcStartStorageFileUpload, Params: []string{"filename", "size"}},
-	"getWakeOnLanDevices":    {Func: rpcGetWakeOnLanDevices},
-	"setWakeOnLanDevices":    {Func: rpcSetWakeOnLanDevices, Params: []string{"params"}},
-	"resetConfig":            {Func: rpcResetConfig},
-	"setDisplayRotation":     {Func: rpcSetDisplayRotation, Params: []string{"params"}},
-	"getDisplayRotation":     {Func: rpcGetDisplayRotation},
-	"setBacklightSettings":   {Func: rpcSetBacklightSettings, Params: []string{"params"}},
-	"getBacklightSettings":   {Func: rpcGetBacklightSettings},
-	"getDCPowerState":        {Func: rpcGetDCPowerState},
-	"setDCPowerState":        {Func: rpcSetDCPowerState, Params: []string{"enabled"}},
-	"getActiveExtension":     {Func: rpcGetActiveExtension},
-	"setActiveExtension":     {Func: rpcSetActiveExtension, Params: []string{"extensionId"}},
-	"getATXState":            {Func: rpcGetATXState},
-	"setATXPowerAction":      {Func: rpcSetATXPowerAction, Params: []string{"action"}},
-	"getSerialSettings":      {Func: rpcGetSerialSettings},
-	"setSerialSettings":      {Func: rpcSetSerialSettings, Params: []string{"settings"}},
-	"getUsbDevices":          {Func: rpcGetUsbDevices},
-	"setUsbDevices":          {Func: rpcSetUsbDevices, Params: []string{"devices"}},
-	"setUsbDeviceState":      {Func: rpcSetUsbDeviceState, Params: []string{"device", "enabled"}},
-	"setCloudUrl":            {Func: rpcSetCloudUrl, Params: []string{"apiUrl", "appUrl"}},
-	"getKeyboardLayout":      {Func: rpcGetKeyboardLayout},
-	"setKeyboardLayout":      {Func: rpcSetKeyboardLayout, Params: []string{"layout"}},
-	"getKeyboardMacros":      {Func: getKeyboardMacros},
-	"setKeyboardMacros":      {Func: setKeyboardMacros, Params: []string{"params"}},
-	"getLocalLoopbackOnly":   {Func: rpcGetLocalLoopbackOnly},
-	"setLocalLoopbackOnly":   {Func: rpcSetLocalLoopbackOnly, Params: []string{"enabled"}},
+	"ping":                   {Func: rpcPing, Permission: PermissionView},
+	"reboot":                 {Func: rpcReboot, Params: []string{"force"}, Permission: PermissionAdmin},
+	"getDeviceID":            {Func: rpcGetDeviceID, Permission: PermissionView},
+	"deregisterDevice":       {Func: rpcDeregisterDevice, Permission: PermissionAdmin},
+	"getCloudState":          {Func: rpcGetCloudState, Permission: PermissionView},
+	"getNetworkState":        {Func: rpcGetNetworkState, Permission: PermissionView},
+	"getNetworkSettings":     {Func: rpcGetNetworkSettings, Permission: PermissionView},
+	"setNetworkSettings":     {Func: rpcSetNetworkSettings, Params: []string{"settings"}, Permission: PermissionAdmin},
+	"renewDHCPLease":         {Func: rpcRenewDHCPLease, Permission: PermissionAdmin},
+	"keyboardReport":         {Func: rpcKeyboardReport, Params: []string{"modifier", "keys"}, RequiresControl: true, Permission: PermissionControl},
+	"getKeyboardLedState":    {Func: rpcGetKeyboardLedState, Permission: PermissionView},
+	"absMouseReport":         {Func: rpcAbsMouseReport, Params: []string{"x", "y", "buttons"}, RequiresControl: true, Permission: PermissionControl},
+	"relMouseReport":         {Func: rpcRelMouseReport, Params: []string{"dx", "dy", "buttons"}, RequiresControl: true, Permission: PermissionControl},
+	"wheelReport":            {Func: rpcWheelReport, Params: []string{"wheelY"}, RequiresControl: true, Permission: PermissionControl},
+	"getVideoState":          {Func: rpcGetVideoState, Permission: PermissionView},
+	"getUSBState":            {Func: rpcGetUSBState, Permission: PermissionView},
+	"unmountImage":           {Func: rpcUnmountImage, RequiresControl: true, Permission: PermissionControl},
+	"rpcMountBuiltInImage":   {Func: rpcMountBuiltInImage, Params: []string{"filename"}, RequiresControl: true, Permission: PermissionControl},
+	"setJigglerState":        {Func: rpcSetJigglerState, Params: []string{"enabled"}, RequiresControl: true, Permission: PermissionControl},
+	"getJigglerState":        {Func: rpcGetJigglerState, Permission: PermissionView},
+	"sendWOLMagicPacket":     {Func: rpcSendWOLMagicPacket, Params: []string{"macAddress"}, RequiresControl: true, Permission: PermissionControl},
+	"getStreamQualityFactor": {Func: rpcGetStreamQualityFactor, Permission: PermissionView},
+	"setStreamQualityFactor": {Func: rpcSetStreamQualityFactor, Params: []string{"factor"}, RequiresControl: true, Permission: PermissionControl},
+	"getAutoUpdateState":     {Func: rpcGetAutoUpdateState, Permission: PermissionView},
+	"setAutoUpdateState":     {Func: rpcSetAutoUpdateState, Params: []string{"enabled"}, Permission: PermissionAdmin},
+	"getEDID":                {Func: rpcGetEDID, Permission: PermissionView},
+	"setEDID":                {Func: rpcSetEDID, Params: []string{"edid"}, Permission: PermissionAdmin},
+	"getDevChannelState":     {Func: rpcGetDevChannelState, Permission: PermissionView},
+	"setDevChannelState":     {Func: rpcSetDevChannelState, Params: []string{"enabled"}, Permission: PermissionAdmin},
+	"getUpdateStatus":        {Func: rpcGetUpdateStatus, Permission: PermissionView},
+	"tryUpdate":              {Func: rpcTryUpdate, Permission: PermissionAdmin},
+	"getDevModeState":        {Func: rpcGetDevModeState, Permission: PermissionView},
+	"setDevModeState":        {Func: rpcSetDevModeState, Params: []string{"enabled"}, Permission: PermissionAdmin},
+	"getSSHKeyState":         {Func: rpcGetSSHKeyState, Permission: PermissionAdmin},
+	"setSSHKeyState":         {Func: rpcSetSSHKeyState, Params: []string{"sshKey"}, Permission: PermissionAdmin},
+	"getTLSState":            {Func: rpcGetTLSState, Permission: PermissionAdmin},
+	"setTLSState":            {Func: rpcSetTLSState, Params: []string{"state"}, Permission: PermissionAdmin},
+	"setMassStorageMode":     {Func: rpcSetMassStorageMode, Params: []string{"mode"}, RequiresControl: true, Permission: PermissionControl},
+	"getMassStorageMode":     {Func: rpcGetMassStorageMode, Permission: PermissionView},
+	"isUpdatePending":        {Func: rpcIsUpdatePending, Permission: PermissionView},
+	"getUsbEmulationState":   {Func: rpcGetUsbEmulationState, Permission: PermissionView},
+	"setUsbEmulationState":   {Func: rpcSetUsbEmulationState, Params: []string{"enabled"}, Permission: PermissionAdmin},
+	"getUsbConfig":           {Func: rpcGetUsbConfig, Permission: PermissionView},
+	"setUsbConfig":           {Func: rpcSetUsbConfig, Params: []string{"usbConfig"}, Permission: PermissionAdmin},
+	"checkMountUrl":          {Func: rpcCheckMountUrl, Params: []string{"url"}, Permission: PermissionControl},
+	"getVirtualMediaState":   {Func: rpcGetVirtualMediaState, Permission: PermissionView},
+	"getStorageSpace":        {Func: rpcGetStorageSpace, Permission: PermissionView},
+	"mountWithHTTP":          {Func: rpcMountWithHTTP, Params: []string{"url", "mode"}, RequiresControl: true, Permission: PermissionControl, Timeout: 2 * httpimage.FetchTimeout},
+	"mountWithWebRTC":        {Func: rpcMountWithWebRTC, Params: []string{"filename", "size", "mode"}, RequiresControl: true, Permission: PermissionControl},
+	"mountWithStorage":       {Func: rpcMountWithStorage, Params: []string{"filename", "mode"}, RequiresControl: true, Permission: PermissionControl},
+	"listStorageFiles":       {Func: rpcListStorageFiles, Permission: PermissionView},
+	"deleteStorageFile":      {Func: rpcDeleteStorageFile, Params: []string{"filename"}, RequiresControl: true, Permission: PermissionControl},
+	"startStorageFileUpload": {Func: rpcStartStorageFileUpload, Params: []string{"filename", "size"}, RequiresControl: true, Permission: PermissionControl},
+	"getWakeOnLanDevices":    {Func: rpcGetWakeOnLanDevices, Permission: PermissionView},
+	"setWakeOnLanDevices":    {Func: rpcSetWakeOnLanDevices, Params: []string{"params"}, Permission: PermissionAdmin},
+	"resetConfig":            {Func: rpcResetConfig, Permission: PermissionAdmin},
+	"setDisplayRotation":     {Func: rpcSetDisplayRotation, Params: []string{"params"}, Permission: PermissionAdmin},
+	"getDisplayRotation":     {Func: rpcGetDisplayRotation, Permission: PermissionView},
+	"setBacklightSettings":   {Func: rpcSetBacklightSettings, Params: []string{"params"}, Permission: PermissionAdmin},
+	"getBacklightSettings":   {Func: rpcGetBacklightSettings, Permission: PermissionView},
+	"getDCPowerState":        {Func: rpcGetDCPowerState, Permission: PermissionView},
+	"setDCPowerState":        {Func: rpcSetDCPowerState, Params: []string{"enabled"}, RequiresControl: true, Permission: PermissionControl},
+	"getActiveExtension":     {Func: rpcGetActiveExtension, Permission: PermissionView},
+	"setActiveExtension":     {Func: rpcSetActiveExtension, Params: []string{"extensionId"}, Permission: PermissionAdmin},
+	"getATXState":            {Func: rpcGetATXState, Permission: PermissionView},
+	"setATXPowerAction":      {Func: rpcSetATXPowerAction, Params: []string{"action"}, RequiresControl: true, Permission: PermissionControl},
+	"getSerialSettings":      {Func: rpcGetSerialSettings, Permission: PermissionView},
+	"setSerialSettings":      {Func: rpcSetSerialSettings, Params: []string{"settings"}, Permission: PermissionAdmin},
+	"getUsbDevices":          {Func: rpcGetUsbDevices, Permission: PermissionView},
+	"setUsbDevices":          {Func: rpcSetUsbDevices, Params: []string{"devices"}, Permission: PermissionAdmin},
+	"setUsbDeviceState":      {Func: rpcSetUsbDeviceState, Params: []string{"device", "enabled"}, Permission: PermissionAdmin},
+	"setCloudUrl":            {Func: rpcSetCloudUrl, Params: []string{"apiUrl", "appUrl"}, Permission: PermissionAdmin},
+	"getKeyboardLayout":      {Func: rpcGetKeyboardLayout, Permission: PermissionView},
+	"setKeyboardLayout":      {Func: rpcSetKeyboardLayout, Params: []string{"layout"}, Permission: PermissionAdmin},
+	"getKeyboardLayouts":     {Func: rpcGetKeyboardLayouts, Permission: PermissionView},
+	"typeText":               {Func: rpcTypeText, Params: []string{"params"}, RequiresControl: true, Permission: PermissionControl},
+	"cancelTypeText":         {Func: rpcCancelTypeText, RequiresControl: true, Permission: PermissionControl},
+	"getTypeTextState":       {Func: rpcGetTypeTextState, Permission: PermissionView},
+	"getKeyboardMacros":      {Func: getKeyboardMacros, Permission: PermissionView},
+	"setKeyboardMacros":      {Func: setKeyboardMacros, Params: []string{"params"}, Permission: PermissionControl},
+	"executeKeyboardMacro":   {Func: rpcExecuteKeyboardMacro, Params: []string{"id"}, RequiresControl: true, Permission: PermissionControl},
+	"cancelMacro":            {Func: rpcCancelMacro, RequiresControl: true, Permission: PermissionControl},
+	"getKeyboardMacroState":  {Func: rpcGetKeyboardMacroState, Permission: PermissionView},
+	"exportKeyboardMacros":   {Func: rpcExportKeyboardMacros, Permission: PermissionView},
+	"importKeyboardMacros":   {Func: rpcImportKeyboardMacros, Params: []string{"params"}, Permission: PermissionControl},
+	"getBootKeyPresets":      {Func: rpcGetBootKeyPresets, Permission: PermissionView},
+	"startBootKeyAssist":     {Func: rpcStartBootKeyAssist, Params: []string{"params"}, RequiresControl: true, Permission: PermissionControl},
+	"cancelBootKeyAssist":    {Func: rpcCancelBootKeyAssist, RequiresControl: true, Permission: PermissionControl},
+	"getBootKeyAssistState":  {Func: rpcGetBootKeyAssistState, Permission: PermissionView},
+	"getLocalLoopbackOnly":   {Func: rpcGetLocalLoopbackOnly, Permission: PermissionView},
+	"setLocalLoopbackOnly":   {Func: rpcSetLocalLoopbackOnly, Params: []string{"enabled"}, Permission: PermissionAdmin},
+	"getSessionState":        {Func: rpcGetSessionState, Permission: PermissionView},
+	"requestControl":         {Func: rpcRequestControl, Permission: PermissionControl},
+	"grantControl":           {Func: rpcGrantControl, Params: []string{"sessionId"}, Permission: PermissionControl},
+	"denyControl":            {Func: rpcDenyControl, Params: []string{"sessionId"}, Permission: PermissionControl},
+	"releaseControl":         {Func: rpcReleaseControl, Permission: PermissionControl},
+	"getAPITokens":           {Func: rpcGetAPITokens, Permission: PermissionAdmin},
+	"createAPIToken":         {Func: rpcCreateAPIToken, Params: []string{"name", "expiresInDays"}, Permission: PermissionAdmin},
+	"revokeAPIToken":         {Func: rpcRevokeAPIToken, Params: []string{"id"}, Permission: PermissionAdmin},
+	"getLoginSessions":       {Func: rpcGetLoginSessions, Permission: PermissionAdmin},
+	"revokeLoginSession":     {Func: rpcRevokeLoginSession, Params: []string{"id"}, Permission: PermissionAdmin},
+	"revokeAllLoginSessions": {Func: rpcRevokeAllLoginSessions, Permission: PermissionAdmin},
+	"getLoginLockouts":       {Func: rpcGetLoginLockouts, Permission: PermissionAdmin},
+	"clearLoginLockouts":     {Func: rpcClearLoginLockouts, Params: []string{"clientIp"}, Permission: PermissionAdmin},
+	"getTOTPState":           {Func: rpcGetTOTPState, Permission: PermissionAdmin},
+	"enrollTOTP":             {Func: rpcEnrollTOTP, Permission: PermissionAdmin},
+	"confirmTOTP":            {Func: rpcConfirmTOTP, Params: []string{"code"}, Permission: PermissionAdmin},
+	"disableTOTP":            {Func: rpcDisableTOTP, Params: []string{"code"}, Permission: PermissionAdmin},
+	"resetTOTPRecoveryCodes": {Func: rpcResetTOTPRecoveryCodes, Params: []string{"code"}, Permission: PermissionAdmin},
+	"getUserTOTPState":       {Func: rpcGetUserTOTPState, Permission: PermissionView},
+	"enrollUserTOTP":         {Func: rpcEnrollUserTOTP, Permission: PermissionView},
+	"confirmUserTOTP":        {Func: rpcConfirmUserTOTP, Params: []string{"code"}, Permission: PermissionView},
+	"disableUserTOTP":        {Func: rpcDisableUserTOTP, Params: []string{"code"}, Permission: PermissionView},
+	"resetUserRecoveryCodes": {Func: rpcResetUserRecoveryCodes, Params: []string{"code"}, Permission: PermissionView},
+	"getOIDCConfig":          {Func: rpcGetOIDCConfig, Permission: PermissionAdmin},
+	"setOIDCConfig":          {Func: rpcSetOIDCConfig, Params: []string{"oidcConfig"}, Permission: PermissionAdmin},
+	"getLocalUsers":          {Func: rpcGetLocalUsers, Permission: PermissionAdmin},
+	"createLocalUser":        {Func: rpcCreateLocalUser, Params: []string{"username", "password", "role"}, Permission: PermissionAdmin},
+	"setLocalUserRole":       {Func: rpcSetLocalUserRole, Params: []string{"id", "role"}, Permission: PermissionAdmin},
+	"setLocalUserPassword":   {Func: rpcSetLocalUserPassword, Params: []string{"id", "password"}, Permission: PermissionAdmin},
+	"deleteLocalUser":        {Func: rpcDeleteLocalUser, Params: []string{"id"}, Permission: PermissionAdmin},
+	"resetLocalUserTOTP":     {Func: rpcResetLocalUserTOTP, Params: []string{"id"}, Permission: PermissionAdmin},
+	"changeUserPassword":     {Func: rpcChangeUserPassword, Params: []string{"oldPassword", "newPassword"}, Permission: PermissionView},
+	"getAuditLog":            {Func: rpcGetAuditLog, Params: []string{"limit", "beforeSeq"}, Permission: PermissionAdmin},
+	"verifyAuditLog":         {Func: rpcVerifyAuditLog, Permission: PermissionAdmin},
+	"getLogSinks":            {Func: rpcGetLogSinks, Permission: PermissionAdmin},
+	"setLogSinks":            {Func: rpcSetLogSinks, Params: []string{"sinks"}, Permission: PermissionAdmin},
+	"getLogScopes":           {Func: rpcGetLogScopes, Permission: PermissionAdmin},
+	"setLogScopeLevel":       {Func: rpcSetLogScopeLevel, Params: []string{"scope", "level", "persist"}, Permission: PermissionAdmin},
+	"getEventHistory":        {Func: rpcGetEventHistory, Params: []string{"filter"}, Permission: PermissionView},
+	"clearEventHistory":      {Func: rpcClearEventHistory, Permission: PermissionAdmin},
+	"getEventHistoryPersist": {Func: rpcGetEventHistoryPersist, Permission: PermissionAdmin},
+	"setEventHistoryPersist": {Func: rpcSetEventHistoryPersist, Params: []string{"enabled"}, Permission: PermissionAdmin},
+	"getWebhooks":            {Func: rpcGetWebhooks, Permission: PermissionAdmin},
+	"createWebhook":          {Func: rpcCreateWebhook, Params: []string{"webhook"}, Permission: PermissionAdmin},
+	"updateWebhook":          {Func: rpcUpdateWebhook, Params: []string{"id", "webhook"}, Permission: PermissionAdmin},
+	"deleteWebhook":          {Func: rpcDeleteWebhook, Params: []string{"id"}, Permission: PermissionAdmin},
+	"testWebhook":            {Func: rpcTestWebhook, Params: []string{"id"}, Permission: PermissionAdmin},
+	"getMQTTConfig":          {Func: rpcGetMQTTConfig, Permission: PermissionAdmin},
+	"setMQTTConfig":          {Func: rpcSetMQTTConfig, Params: []string{"mqttConfig"}, Permission: PermissionAdmin},
+	"getMQTTStatus":          {Func: rpcGetMQTTStatus, Permission: PermissionAdmin},
 }
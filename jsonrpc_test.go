@@ -0,0 +1,58 @@
+package kvm
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCallRPCHandlerInvalidParams(t *testing.T) {
+	handler := RPCHandler{
+		Func:   func(name string, data []uint8) error { return nil },
+		Params: []string{"name", "data"},
+	}
+
+	tests := []struct {
+		name   string
+		params string
+	}{
+		{name: "missing parameter", params: `{"name":"a"}`},
+		{name: "null parameter", params: `{"name":null,"data":[1]}`},
+		{name: "null slice", params: `{"name":"a","data":null}`},
+		{name: "null slice element", params: `{"name":"a","data":[1,null]}`},
+		{name: "invalid slice element", params: `{"name":"a","data":["x"]}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var params map[string]interface{}
+			require.NoError(t, json.Unmarshal([]byte(tt.params), &params))
+
+			_, err := callRPCHandler(context.Background(), handler, params, nil)
+			assert.ErrorIs(t, err, errInvalidParams)
+		})
+	}
+}
+
+func TestCallRPCHandlerValidParams(t *testing.T) {
+	var gotName string
+	var gotData []uint8
+	handler := RPCHandler{
+		Func: func(name string, data []uint8) error {
+			gotName, gotData = name, data
+			return nil
+		},
+		Params: []string{"name", "data"},
+	}
+
+	var params map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(`{"name":"a","data":[1,255]}`), &params))
+
+	_, err := callRPCHandler(context.Background(), handler, params, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "a", gotName)
+	assert.Equal(t, []uint8{1, 255}, gotData)
+}
@@ -0,0 +1,346 @@
+package kvm
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Role is the access level of a local user, or of a login without a user.
+type Role string
+
+const (
+	RoleAdmin    Role = "admin"
+	RoleOperator Role = "operator"
+	RoleViewer   Role = "viewer"
+)
+
+// Permission is what a RPC handler or a data channel needs from the role of the session.
+type Permission string
+
+const (
+	// PermissionView allows watching the video and reading the device state
+	PermissionView Permission = "view"
+	// PermissionControl allows sending input, power actions and mounting media
+	PermissionControl Permission = "control"
+	// PermissionAdmin allows changing the device configuration and the credentials
+	PermissionAdmin Permission = "admin"
+)
+
+const maxLocalUsers = 32
+
+// roleContextKey is the gin context key holding the role of the request.
+const roleContextKey = "role"
+
+var localUsernameRegexp = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9._@-]{0,63}$`)
+
+// dummyPasswordHash is compared against when the username doesn't exist, so that
+// the response time doesn't tell which usernames exist.
+var dummyPasswordHash = sync.OnceValue(func() []byte {
+	hash, _ := bcrypt.GenerateFromPassword([]byte("jetkvm"), bcrypt.DefaultCost)
+	return hash
+})
+
+// LocalUser is a named local account, the password set during the setup stays
+// the admin account without a username.
+type LocalUser struct {
+	ID             string    `json:"id"`
+	Username       string    `json:"username"`
+	HashedPassword string    `json:"hashed_password"`
+	Role           Role      `json:"role"`
+	CreatedAt      time.Time `json:"created_at"`
+	// the second factor of the user, the device password has its own
+	TOTPEnabled       bool     `json:"totp_enabled,omitempty"`
+	TOTPSecret        string   `json:"totp_secret,omitempty"`
+	TOTPRecoveryCodes []string `json:"totp_recovery_codes,omitempty"` // hashed
+}
+
+// LocalUserInfo is the user as returned over JSON-RPC, without the hash.
+type LocalUserInfo struct {
+	ID          string    `json:"id"`
+	Username    string    `json:"username"`
+	Role        Role      `json:"role"`
+	CreatedAt   time.Time `json:"createdAt"`
+	TOTPEnabled bool      `json:"totpEnabled"`
+}
+
+var localUsersLock = &sync.Mutex{}
+
+func (r Role) isValid() bool {
+	return r == RoleAdmin || r == RoleOperator || r == RoleViewer
+}
+
+// allows reports whether the role grants the permission, an unknown permission
+// is treated as an admin one.
+func (r Role) allows(permission Permission) bool {
+	switch r {
+	case RoleAdmin:
+		return true
+	case RoleOperator:
+		return permission == PermissionView || permission == PermissionControl
+	case RoleViewer:
+		return permission == PermissionView
+	}
+	return false
+}
+
+func (u *LocalUser) info() LocalUserInfo {
+	return LocalUserInfo{
+		ID:          u.ID,
+		Username:    u.Username,
+		Role:        u.Role,
+		CreatedAt:   u.CreatedAt,
+		TOTPEnabled: u.TOTPEnabled && u.TOTPSecret != "",
+	}
+}
+
+// authenticateLocalUser checks the credentials of a named user.
+func authenticateLocalUser(username string, password string) (*LocalUser, bool) {
+	localUsersLock.Lock()
+	var user *LocalUser
+	if idx := slices.IndexFunc(config.LocalUsers, func(u LocalUser) bool {
+		return strings.EqualFold(u.Username, username)
+	}); idx != -1 {
+		found := config.LocalUsers[idx]
+		user = &found
+	}
+	localUsersLock.Unlock()
+
+	if user == nil {
+		_ = bcrypt.CompareHashAndPassword(dummyPasswordHash(), []byte(password))
+		return nil, false
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(user.HashedPassword), []byte(password)); err != nil {
+		return nil, false
+	}
+	return user, true
+}
+
+// getRequestRole returns the role of the HTTP request, requests without a login
+// session come from the noPassword mode or from an API token and are admins.
+func getRequestRole(c *gin.Context) Role {
+	if role, ok := c.Get(roleContextKey); ok {
+		return role.(Role)
+	}
+	return RoleAdmin
+}
+
+// requirePermission rejects the requests whose role doesn't grant the permission.
+func requirePermission(permission Permission) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !getRequestRole(c).allows(permission) {
+			sendErrorJsonThenAbort(c, http.StatusForbidden, "Permission denied")
+			return
+		}
+		c.Next()
+	}
+}
+
+func validateLocalUserInput(username string, role Role) error {
+	if !localUsernameRegexp.MatchString(username) {
+		return errors.New("username must be 1 to 64 letters, digits or . _ @ -")
+	}
+	if !role.isValid() {
+		return fmt.Errorf("invalid role %q", role)
+	}
+	return nil
+}
+
+// revokeLoginSessionsOfUser logs the user out everywhere, so that a role change
+// or a removal applies to the open sessions right away.
+func revokeLoginSessionsOfUser(userID string) {
+	for _, id := range loginSessions.idsOfUser(userID) {
+		if err := loginSessions.revoke(id); err != nil {
+			logger.Warn().Err(err).Str("userId", userID).Msg("failed to revoke login session of user")
+		}
+	}
+}
+
+func rpcGetLocalUsers() ([]LocalUserInfo, error) {
+	localUsersLock.Lock()
+	defer localUsersLock.Unlock()
+
+	users := make([]LocalUserInfo, 0, len(config.LocalUsers))
+	for i := range config.LocalUsers {
+		users = append(users, config.LocalUsers[i].info())
+	}
+	return users, nil
+}
+
+func rpcCreateLocalUser(username string, password string, role Role) (*LocalUserInfo, error) {
+	username = strings.TrimSpace(username)
+	if err := validateLocalUserInput(username, role); err != nil {
+		return nil, err
+	}
+	if password == "" {
+		return nil, errors.New("password cannot be empty")
+	}
+	if config.HashedPassword == "" {
+		return nil, errors.New("set the admin password before adding users")
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash password: %w", err)
+	}
+
+	user := LocalUser{
+		ID:             uuid.New().String(),
+		Username:       username,
+		HashedPassword: string(hashedPassword),
+		Role:           role,
+		CreatedAt:      time.Now(),
+	}
+
+	localUsersLock.Lock()
+	if len(config.LocalUsers) >= maxLocalUsers {
+		localUsersLock.Unlock()
+		return nil, fmt.Errorf("too many users (max %d)", maxLocalUsers)
+	}
+	if slices.ContainsFunc(config.LocalUsers, func(u LocalUser) bool { return strings.EqualFold(u.Username, username) }) {
+		localUsersLock.Unlock()
+		return nil, fmt.Errorf("a user named %q already exists", username)
+	}
+	config.LocalUsers = append(config.LocalUsers, user)
+	localUsersLock.Unlock()
+
+	if err := SaveConfig(); err != nil {
+		return nil, fmt.Errorf("failed to save config: %w", err)
+	}
+
+	logger.Info().Str("id", user.ID).Str("username", user.Username).Str("role", string(user.Role)).Msg("local user created")
+
+	info := user.info()
+	return &info, nil
+}
+
+// updateLocalUser applies the update to the user with the given id and saves the config.
+func updateLocalUser(id string, update func(user *LocalUser)) (*LocalUser, error) {
+	localUsersLock.Lock()
+	idx := slices.IndexFunc(config.LocalUsers, func(u LocalUser) bool { return u.ID == id })
+	if idx == -1 {
+		localUsersLock.Unlock()
+		return nil, fmt.Errorf("user %s not found", id)
+	}
+	update(&config.LocalUsers[idx])
+	user := config.LocalUsers[idx]
+	localUsersLock.Unlock()
+
+	if err := SaveConfig(); err != nil {
+		return nil, fmt.Errorf("failed to save config: %w", err)
+	}
+	return &user, nil
+}
+
+func rpcSetLocalUserRole(id string, role Role) error {
+	if !role.isValid() {
+		return fmt.Errorf("invalid role %q", role)
+	}
+
+	user, err := updateLocalUser(id, func(user *LocalUser) { user.Role = role })
+	if err != nil {
+		return err
+	}
+	revokeLoginSessionsOfUser(id)
+
+	logger.Info().Str("id", id).Str("username", user.Username).Str("role", string(role)).Msg("local user role changed")
+	return nil
+}
+
+func rpcSetLocalUserPassword(id string, password string) error {
+	if password == "" {
+		return errors.New("password cannot be empty")
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return fmt.Errorf("failed to hash password: %w", err)
+	}
+
+	user, err := updateLocalUser(id, func(user *LocalUser) { user.HashedPassword = string(hashedPassword) })
+	if err != nil {
+		return err
+	}
+	revokeLoginSessionsOfUser(id)
+
+	logger.Info().Str("id", id).Str("username", user.Username).Msg("local user password reset")
+	return nil
+}
+
+func rpcDeleteLocalUser(id string) error {
+	localUsersLock.Lock()
+	idx := slices.IndexFunc(config.LocalUsers, func(u LocalUser) bool { return u.ID == id })
+	if idx == -1 {
+		localUsersLock.Unlock()
+		return fmt.Errorf("user %s not found", id)
+	}
+	username := config.LocalUsers[idx].Username
+	config.LocalUsers = slices.Delete(config.LocalUsers, idx, idx+1)
+	localUsersLock.Unlock()
+
+	if err := SaveConfig(); err != nil {
+		return fmt.Errorf("failed to save config: %w", err)
+	}
+	revokeLoginSessionsOfUser(id)
+	forgetUserTOTP(id)
+
+	logger.Info().Str("id", id).Str("username", username).Msg("local user deleted")
+	return nil
+}
+
+// rpcChangeUserPassword lets a named user change their own password.
+func rpcChangeUserPassword(session *Session, oldPassword string, newPassword string) error {
+	if session == nil || session.UserID == "" {
+		return errors.New("only named users can change their password here")
+	}
+	if newPassword == "" {
+		return errors.New("password cannot be empty")
+	}
+
+	localUsersLock.Lock()
+	idx := slices.IndexFunc(config.LocalUsers, func(u LocalUser) bool { return u.ID == session.UserID })
+	var hashed string
+	if idx != -1 {
+		hashed = config.LocalUsers[idx].HashedPassword
+	}
+	localUsersLock.Unlock()
+
+	if idx == -1 {
+		return errors.New("user not found")
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(hashed), []byte(oldPassword)); err != nil {
+		return errors.New("incorrect old password")
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(newPassword), bcrypt.DefaultCost)
+	if err != nil {
+		return fmt.Errorf("failed to hash password: %w", err)
+	}
+
+	if _, err := updateLocalUser(session.UserID, func(user *LocalUser) { user.HashedPassword = string(hashedPassword) }); err != nil {
+		return err
+	}
+
+	// log out the other browsers of the user, the current one stays logged in
+	for _, id := range loginSessions.idsOfUser(session.UserID) {
+		if id == session.LoginSessionID {
+			continue
+		}
+		if err := loginSessions.revoke(id); err != nil {
+			logger.Warn().Err(err).Str("userId", session.UserID).Msg("failed to revoke login session of user")
+		}
+	}
+
+	logger.Info().Str("id", session.UserID).Msg("local user changed their password")
+	return nil
+}
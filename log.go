@@ -27,6 +27,9 @@ var (
 	displayLogger   = logging.GetSubsystemLogger("display")
 	wolLogger       = logging.GetSubsystemLogger("wol")
 	usbLogger       = logging.GetSubsystemLogger("usb")
+	webhookLogger   = logging.GetSubsystemLogger("webhook")
+	mqttLogger      = logging.GetSubsystemLogger("mqtt")
+	keyboardLogger  = logging.GetSubsystemLogger("keyboard")
 	// external components
 	ginLogger = logging.GetSubsystemLogger("gin")
 )
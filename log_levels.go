@@ -0,0 +1,43 @@
+package kvm
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jetkvm/kvm/internal/logging"
+)
+
+func rpcGetLogScopes() ([]logging.ScopeLevel, error) {
+	return logging.GetRootLogger().GetScopeLevels(), nil
+}
+
+// rpcSetLogScopeLevel changes the level of a scope until the next reboot, or for good
+// when persist is set. An empty level goes back to the default level.
+func rpcSetLogScopeLevel(scope string, level string, persist bool) error {
+	rootLogger := logging.GetRootLogger()
+	if !rootLogger.HasScope(scope) {
+		return fmt.Errorf("unknown log scope %q", scope)
+	}
+
+	level = strings.ToUpper(level)
+	if err := rootLogger.SetScopeLevel(scope, level); err != nil {
+		return err
+	}
+
+	if persist {
+		if level == "" {
+			delete(config.LogScopeLevels, scope)
+		} else {
+			if config.LogScopeLevels == nil {
+				config.LogScopeLevels = make(map[string]string)
+			}
+			config.LogScopeLevels[scope] = level
+		}
+		if err := SaveConfig(); err != nil {
+			return fmt.Errorf("failed to save config: %w", err)
+		}
+	}
+
+	logger.Info().Str("scope", scope).Str("level", level).Bool("persist", persist).Msg("log scope level updated")
+	return nil
+}
@@ -0,0 +1,41 @@
+package kvm
+
+import (
+	"fmt"
+
+	"github.com/jetkvm/kvm/internal/logging"
+)
+
+const logFilePath = "/userdata/jetkvm/logs/jetkvm.log"
+
+// initLogSinks starts the configured log sinks, it runs early so that the startup is logged.
+func initLogSinks() {
+	if config.LogSinks == nil {
+		return
+	}
+
+	if err := logging.ConfigureSinks(*config.LogSinks, logFilePath); err != nil {
+		logger.Warn().Err(err).Msg("failed to configure log sinks")
+	}
+}
+
+func rpcGetLogSinks() (logging.SinksConfig, error) {
+	if config.LogSinks == nil {
+		return logging.SinksConfig{}, nil
+	}
+	return *config.LogSinks, nil
+}
+
+func rpcSetLogSinks(sinks logging.SinksConfig) error {
+	if err := logging.ConfigureSinks(sinks, logFilePath); err != nil {
+		return err
+	}
+
+	config.LogSinks = &sinks
+	if err := SaveConfig(); err != nil {
+		return fmt.Errorf("failed to save config: %w", err)
+	}
+
+	logger.Info().Interface("sinks", sinks).Msg("log sinks updated")
+	return nil
+}
@@ -0,0 +1,326 @@
+package kvm
+
+import (
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const (
+	// failures allowed before the backoff kicks in
+	loginFreeAttempts = 3
+	loginBackoffBase  = 1 * time.Second
+	loginBackoffMax   = 5 * time.Minute
+
+	// failures from a single client before it's locked out
+	loginLockoutThreshold = 10
+	loginLockoutDuration  = 15 * time.Minute
+
+	// failures from all clients within the window before every login is locked out,
+	// this protects against attacks spread over many addresses. The clients that logged
+	// in successfully within loginTrustedRetention aren't locked out by it.
+	loginGlobalWindow            = 1 * time.Minute
+	loginGlobalLockoutThreshold  = 50
+	loginGlobalLockoutDuration   = 5 * time.Minute
+	loginAttemptsRetention       = 1 * time.Hour
+	loginTrustedRetention        = 24 * time.Hour
+	loginAttemptsMaxTrackedHosts = 1024
+)
+
+var (
+	metricLoginFailuresCount = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "jetkvm_login_failures_total",
+			Help: "The total number of failed login attempts",
+		},
+		[]string{"endpoint"},
+	)
+	metricLoginLockoutsCount = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "jetkvm_login_lockouts_total",
+			Help: "The total number of login lockouts",
+		},
+		[]string{"scope"},
+	)
+)
+
+type loginAttempts struct {
+	failures int
+	// pending is the number of attempts let through by allow and not done yet
+	pending       int
+	lastFailure   time.Time
+	nextAttemptAt time.Time
+	lockedUntil   time.Time
+}
+
+type loginLimiter struct {
+	lock              sync.Mutex
+	clients           map[string]*loginAttempts
+	globalFailures    []time.Time
+	globalLockedUntil time.Time
+	// trusted are the clients that logged in successfully, by the time of their last login
+	trusted map[string]time.Time
+}
+
+var loginRateLimiter = &loginLimiter{
+	clients: make(map[string]*loginAttempts),
+	trusted: make(map[string]time.Time),
+}
+
+type LoginLockout struct {
+	ClientIP      string     `json:"clientIp"`
+	Failures      int        `json:"failures"`
+	LastFailure   time.Time  `json:"lastFailure"`
+	NextAttemptAt *time.Time `json:"nextAttemptAt,omitempty"`
+	LockedUntil   *time.Time `json:"lockedUntil,omitempty"`
+}
+
+type LoginLockoutState struct {
+	GlobalLockedUntil *time.Time     `json:"globalLockedUntil,omitempty"`
+	Clients           []LoginLockout `json:"clients"`
+}
+
+// allow returns how long the client has to wait before its next attempt. When the
+// attempt can go ahead, it's reserved until release is called, so that concurrent
+// requests can't all get in before the failure of the first one is recorded.
+func (l *loginLimiter) allow(clientIP string) (wait time.Duration, release func()) {
+	now := time.Now()
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if now.Before(l.globalLockedUntil) && !l.isTrustedLocked(clientIP, now) {
+		return l.globalLockedUntil.Sub(now), nil
+	}
+
+	attempts, ok := l.clients[clientIP]
+	if !ok {
+		attempts = &loginAttempts{}
+		l.clients[clientIP] = attempts
+	}
+
+	if now.Before(attempts.lockedUntil) {
+		return attempts.lockedUntil.Sub(now), nil
+	}
+
+	if now.Before(attempts.nextAttemptAt) {
+		return attempts.nextAttemptAt.Sub(now), nil
+	}
+
+	// the attempts in flight count as failures, once the free attempts are used up
+	// the client gets one attempt at a time
+	if attempts.pending > 0 && attempts.failures+attempts.pending > loginFreeAttempts {
+		return loginBackoffBase, nil
+	}
+
+	attempts.pending++
+	var once sync.Once
+	return 0, func() {
+		once.Do(func() {
+			l.lock.Lock()
+			defer l.lock.Unlock()
+
+			attempts.pending--
+			// nothing to remember of a client whose attempts all succeeded
+			if attempts.pending == 0 && attempts.failures == 0 && l.clients[clientIP] == attempts {
+				delete(l.clients, clientIP)
+			}
+		})
+	}
+}
+
+func (l *loginLimiter) isTrustedLocked(clientIP string, now time.Time) bool {
+	lastLogin, ok := l.trusted[clientIP]
+	return ok && now.Sub(lastLogin) < loginTrustedRetention
+}
+
+func (l *loginLimiter) recordFailure(clientIP string, endpoint string) {
+	metricLoginFailuresCount.WithLabelValues(endpoint).Inc()
+
+	now := time.Now()
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	l.pruneLocked(now)
+
+	attempts, ok := l.clients[clientIP]
+	if !ok {
+		attempts = &loginAttempts{}
+		l.clients[clientIP] = attempts
+	}
+
+	// a client whose lockout ran out starts over with the backoff
+	if !attempts.lockedUntil.IsZero() && now.After(attempts.lockedUntil) {
+		attempts.failures = 0
+		attempts.lockedUntil = time.Time{}
+	}
+
+	attempts.failures++
+	attempts.lastFailure = now
+
+	if attempts.failures > loginFreeAttempts {
+		exp := float64(attempts.failures - loginFreeAttempts - 1)
+		backoff := time.Duration(float64(loginBackoffBase) * math.Pow(2, exp))
+		attempts.nextAttemptAt = now.Add(min(backoff, loginBackoffMax))
+	}
+
+	if attempts.failures >= loginLockoutThreshold && attempts.lockedUntil.IsZero() {
+		attempts.lockedUntil = now.Add(loginLockoutDuration)
+		metricLoginLockoutsCount.WithLabelValues("client").Inc()
+		logger.Warn().Str("clientIp", clientIP).Int("failures", attempts.failures).Msg("too many failed logins, client locked out")
+	}
+
+	l.globalFailures = append(l.globalFailures, now)
+	if len(l.globalFailures) >= loginGlobalLockoutThreshold && !now.Before(l.globalLockedUntil) {
+		l.globalLockedUntil = now.Add(loginGlobalLockoutDuration)
+		l.globalFailures = l.globalFailures[:0]
+		metricLoginLockoutsCount.WithLabelValues("global").Inc()
+		logger.Warn().Msg("too many failed logins from all clients, logins locked out")
+	}
+}
+
+func (l *loginLimiter) recordSuccess(clientIP string) {
+	now := time.Now()
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	// the reservations of the attempts in flight are kept, they're released on the
+	// removed attempts
+	delete(l.clients, clientIP)
+	l.trusted[clientIP] = now
+}
+
+func (l *loginLimiter) pruneLocked(now time.Time) {
+	cutoff := now.Add(-loginGlobalWindow)
+	idx := sort.Search(len(l.globalFailures), func(i int) bool {
+		return l.globalFailures[i].After(cutoff)
+	})
+	l.globalFailures = l.globalFailures[idx:]
+
+	for clientIP, attempts := range l.clients {
+		if attempts.pending == 0 && now.Sub(attempts.lastFailure) > loginAttemptsRetention && now.After(attempts.lockedUntil) {
+			delete(l.clients, clientIP)
+		}
+	}
+
+	for clientIP, lastLogin := range l.trusted {
+		if now.Sub(lastLogin) >= loginTrustedRetention {
+			delete(l.trusted, clientIP)
+		}
+	}
+	for len(l.trusted) > loginAttemptsMaxTrackedHosts {
+		var oldestIP string
+		var oldest time.Time
+		for clientIP, lastLogin := range l.trusted {
+			if oldestIP == "" || lastLogin.Before(oldest) {
+				oldestIP = clientIP
+				oldest = lastLogin
+			}
+		}
+		delete(l.trusted, oldestIP)
+	}
+
+	// keep the memory bounded when the failures come from many addresses
+	for len(l.clients) >= loginAttemptsMaxTrackedHosts {
+		var oldestIP string
+		var oldest time.Time
+		for clientIP, attempts := range l.clients {
+			if oldestIP == "" || attempts.lastFailure.Before(oldest) {
+				oldestIP = clientIP
+				oldest = attempts.lastFailure
+			}
+		}
+		delete(l.clients, oldestIP)
+	}
+}
+
+func (l *loginLimiter) state() LoginLockoutState {
+	now := time.Now()
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	l.pruneLocked(now)
+
+	state := LoginLockoutState{
+		Clients: make([]LoginLockout, 0, len(l.clients)),
+	}
+	if now.Before(l.globalLockedUntil) {
+		lockedUntil := l.globalLockedUntil
+		state.GlobalLockedUntil = &lockedUntil
+	}
+
+	for clientIP, attempts := range l.clients {
+		// a client with an attempt in flight and no failure isn't throttled
+		if attempts.failures == 0 {
+			continue
+		}
+		lockout := LoginLockout{
+			ClientIP:    clientIP,
+			Failures:    attempts.failures,
+			LastFailure: attempts.lastFailure,
+		}
+		if now.Before(attempts.nextAttemptAt) {
+			nextAttemptAt := attempts.nextAttemptAt
+			lockout.NextAttemptAt = &nextAttemptAt
+		}
+		if now.Before(attempts.lockedUntil) {
+			lockedUntil := attempts.lockedUntil
+			lockout.LockedUntil = &lockedUntil
+		}
+		state.Clients = append(state.Clients, lockout)
+	}
+	sort.Slice(state.Clients, func(i, j int) bool {
+		return state.Clients[i].LastFailure.After(state.Clients[j].LastFailure)
+	})
+
+	return state
+}
+
+// clear removes the lockout of the client, or every lockout when clientIP is empty.
+func (l *loginLimiter) clear(clientIP string) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if clientIP != "" {
+		delete(l.clients, clientIP)
+		return
+	}
+
+	l.clients = make(map[string]*loginAttempts)
+	l.globalFailures = l.globalFailures[:0]
+	l.globalLockedUntil = time.Time{}
+}
+
+// abortIfLoginThrottled rejects the request with 429 when the client has to wait,
+// this runs before bcrypt so that a brute-force attempt can't pin the CPU. Otherwise
+// the attempt is reserved, release must be called once its outcome is recorded.
+func abortIfLoginThrottled(c *gin.Context) (release func(), aborted bool) {
+	wait, release := loginRateLimiter.allow(c.ClientIP())
+	if wait <= 0 {
+		return release, false
+	}
+
+	c.Header("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+	sendErrorJsonThenAbort(c, http.StatusTooManyRequests, "Too many failed login attempts, try again later")
+	return nil, true
+}
+
+func rpcGetLoginLockouts() (LoginLockoutState, error) {
+	return loginRateLimiter.state(), nil
+}
+
+func rpcClearLoginLockouts(clientIP string) error {
+	loginRateLimiter.clear(clientIP)
+	logger.Info().Str("clientIp", clientIP).Msg("login lockouts cleared")
+	return nil
+}
@@ -0,0 +1,428 @@
+package kvm
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const loginSessionsPath = "/userdata/kvm_login_sessions.json"
+
+const (
+	authTokenCookieName = "authToken"
+
+	// a login session expires when it isn't used for loginSessionIdleTimeout,
+	// or loginSessionMaxAge after the login, whichever comes first
+	loginSessionIdleTimeout = 7 * 24 * time.Hour
+	loginSessionMaxAge      = 30 * 24 * time.Hour
+
+	// the last use of a session is written to flash at most once per interval
+	loginSessionSaveInterval = 5 * time.Minute
+
+	maxLoginSessions = 64
+)
+
+// loginSessionContextKey is the gin context key holding the login session of the request.
+const loginSessionContextKey = "loginSession"
+
+// LoginSession is a logged in browser. Only the SHA-256 of the cookie token is stored.
+type LoginSession struct {
+	ID          string    `json:"id"`
+	HashedToken string    `json:"hashedToken"`
+	CreatedAt   time.Time `json:"createdAt"`
+	LastUsedAt  time.Time `json:"lastUsedAt"`
+	ClientIP    string    `json:"clientIp"`
+	UserAgent   string    `json:"userAgent"`
+	// UserID is the local user that logged in, empty for the admin password and OIDC
+	UserID   string `json:"userId,omitempty"`
+	Username string `json:"username,omitempty"`
+	// Role is empty for the sessions created before roles existed, those are admins
+	Role Role `json:"role,omitempty"`
+}
+
+// LoginSessionInfo is the login session as returned over JSON-RPC, without the hash.
+type LoginSessionInfo struct {
+	ID         string    `json:"id"`
+	CreatedAt  time.Time `json:"createdAt"`
+	LastUsedAt time.Time `json:"lastUsedAt"`
+	ClientIP   string    `json:"clientIp"`
+	UserAgent  string    `json:"userAgent"`
+	Username   string    `json:"username,omitempty"`
+	Role       Role      `json:"role"`
+	Current    bool      `json:"current"`
+}
+
+// loginIdentity is who logged in, it's recorded in the login session.
+type loginIdentity struct {
+	UserID   string
+	Username string
+	Role     Role
+}
+
+type loginSessionStore struct {
+	lock     sync.Mutex
+	sessions map[string]*LoginSession // keyed by hashed token
+	dirty    bool
+	lastSave time.Time
+}
+
+var loginSessions = &loginSessionStore{
+	sessions: make(map[string]*LoginSession),
+}
+
+func (s *LoginSession) isExpired(now time.Time) bool {
+	return now.Sub(s.LastUsedAt) > loginSessionIdleTimeout || now.Sub(s.CreatedAt) > loginSessionMaxAge
+}
+
+func (s *LoginSession) role() Role {
+	if s.Role == "" {
+		return RoleAdmin
+	}
+	return s.Role
+}
+
+func (s *LoginSession) info(currentID string) LoginSessionInfo {
+	return LoginSessionInfo{
+		ID:         s.ID,
+		CreatedAt:  s.CreatedAt,
+		LastUsedAt: s.LastUsedAt,
+		ClientIP:   s.ClientIP,
+		UserAgent:  s.UserAgent,
+		Username:   s.Username,
+		Role:       s.role(),
+		Current:    s.ID == currentID,
+	}
+}
+
+// loadLoginSessions loads the persisted login sessions, the token of the previous
+// single-session login is migrated so that an upgrade doesn't log the user out.
+func loadLoginSessions() {
+	loginSessions.lock.Lock()
+	defer loginSessions.lock.Unlock()
+
+	data, err := os.ReadFile(loginSessionsPath)
+	if err != nil && !os.IsNotExist(err) {
+		logger.Warn().Err(err).Msg("failed to read login sessions")
+	}
+
+	if len(data) > 0 {
+		var stored []*LoginSession
+		if err := json.Unmarshal(data, &stored); err != nil {
+			logger.Warn().Err(err).Msg("failed to parse login sessions, starting with an empty store")
+		}
+
+		now := time.Now()
+		for _, session := range stored {
+			if session.isExpired(now) {
+				continue
+			}
+			loginSessions.sessions[session.HashedToken] = session
+		}
+	}
+
+	if config.LocalAuthToken != "" {
+		now := time.Now()
+		session := &LoginSession{
+			ID:          uuid.New().String(),
+			HashedToken: hashSecretToken(config.LocalAuthToken),
+			CreatedAt:   now,
+			LastUsedAt:  now,
+		}
+		loginSessions.sessions[session.HashedToken] = session
+
+		config.LocalAuthToken = ""
+		if err := loginSessions.saveLocked(); err != nil {
+			logger.Warn().Err(err).Msg("failed to save migrated login session")
+		} else if err := SaveConfig(); err != nil {
+			logger.Warn().Err(err).Msg("failed to save config after migrating the login session")
+		}
+	}
+
+	logger.Info().Int("count", len(loginSessions.sessions)).Msg("login sessions loaded")
+}
+
+func (s *loginSessionStore) saveLocked() error {
+	stored := make([]*LoginSession, 0, len(s.sessions))
+	for _, session := range s.sessions {
+		stored = append(stored, session)
+	}
+
+	data, err := json.MarshalIndent(stored, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode login sessions: %w", err)
+	}
+
+	if err := os.WriteFile(loginSessionsPath, data, 0600); err != nil {
+		return fmt.Errorf("failed to write login sessions: %w", err)
+	}
+
+	s.dirty = false
+	s.lastSave = time.Now()
+	return nil
+}
+
+func (s *loginSessionStore) saveAndLogLocked() {
+	if err := s.saveLocked(); err != nil {
+		logger.Warn().Err(err).Msg("failed to save login sessions")
+	}
+}
+
+// create starts a new login session for the request and returns its token.
+func (s *loginSessionStore) create(c *gin.Context, identity loginIdentity) (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", fmt.Errorf("failed to generate token: %w", err)
+	}
+	token := base64.RawURLEncoding.EncodeToString(secret)
+
+	now := time.Now()
+	session := &LoginSession{
+		ID:          uuid.New().String(),
+		HashedToken: hashSecretToken(token),
+		CreatedAt:   now,
+		LastUsedAt:  now,
+		ClientIP:    c.ClientIP(),
+		UserAgent:   c.Request.UserAgent(),
+		UserID:      identity.UserID,
+		Username:    identity.Username,
+		Role:        identity.Role,
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.pruneLocked(now)
+
+	// drop the least recently used sessions when the store is full
+	for len(s.sessions) >= maxLoginSessions {
+		var oldest *LoginSession
+		for _, existing := range s.sessions {
+			if oldest == nil || existing.LastUsedAt.Before(oldest.LastUsedAt) {
+				oldest = existing
+			}
+		}
+		delete(s.sessions, oldest.HashedToken)
+	}
+
+	s.sessions[session.HashedToken] = session
+	if err := s.saveLocked(); err != nil {
+		return "", err
+	}
+
+	logger.Info().
+		Str("id", session.ID).
+		Str("clientIp", session.ClientIP).
+		Str("username", session.Username).
+		Str("role", string(session.role())).
+		Msg("login session created")
+	return token, nil
+}
+
+// validate returns the login session of the token and records its use.
+func (s *loginSessionStore) validate(token string, c *gin.Context) (*LoginSession, bool) {
+	if token == "" {
+		return nil, false
+	}
+
+	hashed := hashSecretToken(token)
+	now := time.Now()
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	session, ok := s.sessions[hashed]
+	if !ok {
+		return nil, false
+	}
+
+	if session.isExpired(now) {
+		delete(s.sessions, hashed)
+		s.saveAndLogLocked()
+		return nil, false
+	}
+
+	session.LastUsedAt = now
+	session.ClientIP = c.ClientIP()
+	session.UserAgent = c.Request.UserAgent()
+	s.dirty = true
+
+	if now.Sub(s.lastSave) > loginSessionSaveInterval {
+		s.saveAndLogLocked()
+	}
+
+	return session, true
+}
+
+func (s *loginSessionStore) pruneLocked(now time.Time) {
+	for hashed, session := range s.sessions {
+		if session.isExpired(now) {
+			delete(s.sessions, hashed)
+		}
+	}
+}
+
+// get returns a copy of the login session with the given id.
+func (s *loginSessionStore) get(id string) (LoginSession, bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for _, session := range s.sessions {
+		if session.ID == id && !session.isExpired(time.Now()) {
+			return *session, true
+		}
+	}
+	return LoginSession{}, false
+}
+
+// idsOfUser returns the ids of the login sessions of a local user.
+func (s *loginSessionStore) idsOfUser(userID string) []string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	var ids []string
+	for _, session := range s.sessions {
+		if session.UserID == userID {
+			ids = append(ids, session.ID)
+		}
+	}
+	return ids
+}
+
+func (s *loginSessionStore) list(currentID string) []LoginSessionInfo {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.pruneLocked(time.Now())
+
+	infos := make([]LoginSessionInfo, 0, len(s.sessions))
+	for _, session := range s.sessions {
+		infos = append(infos, session.info(currentID))
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].LastUsedAt.After(infos[j].LastUsedAt)
+	})
+
+	return infos
+}
+
+// revoke removes the login session with the given id and closes its WebRTC sessions.
+func (s *loginSessionStore) revoke(id string) error {
+	s.lock.Lock()
+	found := false
+	for hashed, session := range s.sessions {
+		if session.ID == id {
+			delete(s.sessions, hashed)
+			found = true
+			break
+		}
+	}
+	if !found {
+		s.lock.Unlock()
+		return fmt.Errorf("login session %s not found", id)
+	}
+	err := s.saveLocked()
+	s.lock.Unlock()
+
+	closeSessionsOfLogin(func(loginSessionID string) bool { return loginSessionID == id })
+
+	logger.Info().Str("id", id).Msg("login session revoked")
+	return err
+}
+
+// revokeAll removes every login session but the one with the given id, if any.
+func (s *loginSessionStore) revokeAll(exceptID string) error {
+	s.lock.Lock()
+	for hashed, session := range s.sessions {
+		if session.ID != exceptID {
+			delete(s.sessions, hashed)
+		}
+	}
+	err := s.saveLocked()
+	s.lock.Unlock()
+
+	closeSessionsOfLogin(func(loginSessionID string) bool {
+		return loginSessionID != "" && loginSessionID != exceptID
+	})
+
+	logger.Info().Str("except", exceptID).Msg("all login sessions revoked")
+	return err
+}
+
+// clear removes every login session, without closing the WebRTC sessions.
+func (s *loginSessionStore) clear() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.sessions = make(map[string]*LoginSession)
+	s.saveAndLogLocked()
+}
+
+// flush persists the pending last use updates.
+func (s *loginSessionStore) flush() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.dirty {
+		s.saveAndLogLocked()
+	}
+}
+
+// closeSessionsOfLogin closes the sessions opened with a revoked login.
+func closeSessionsOfLogin(match func(loginSessionID string) bool) {
+	for _, session := range getSessions() {
+		if match(session.LoginSessionID) {
+			session.close()
+		}
+	}
+}
+
+// startLoginSession creates a login session and sets its cookie.
+func startLoginSession(c *gin.Context, identity loginIdentity) error {
+	token, err := loginSessions.create(c, identity)
+	if err != nil {
+		return err
+	}
+
+	c.SetCookie(authTokenCookieName, token, int(loginSessionIdleTimeout.Seconds()), "/", "", false, true)
+	return nil
+}
+
+func getRequestLoginSessionID(c *gin.Context) string {
+	if session, ok := c.Get(loginSessionContextKey); ok {
+		return session.(*LoginSession).ID
+	}
+	return ""
+}
+
+func rpcGetLoginSessions(session *Session) ([]LoginSessionInfo, error) {
+	var currentID string
+	if session != nil {
+		currentID = session.LoginSessionID
+	}
+	return loginSessions.list(currentID), nil
+}
+
+func rpcRevokeLoginSession(session *Session, id string) error {
+	if session != nil && session.LoginSessionID == id {
+		return errors.New("cannot revoke the current login session, log out instead")
+	}
+	return loginSessions.revoke(id)
+}
+
+// rpcRevokeAllLoginSessions logs out every other browser.
+func rpcRevokeAllLoginSessions(session *Session) error {
+	var currentID string
+	if session != nil {
+		currentID = session.LoginSessionID
+	}
+	return loginSessions.revokeAll(currentID)
+}
@@ -0,0 +1,363 @@
+package kvm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/jetkvm/kvm/internal/keyboard"
+	"github.com/jetkvm/kvm/internal/macros"
+)
+
+const (
+	// macroStepPause is the pause between two steps, so that the host sees the keys of a
+	// step released before the ones of the next step are pressed
+	macroStepPause = 10 * time.Millisecond
+	// macroClickDuration is how long the buttons of the mouse steps are held
+	macroClickDuration = 50 * time.Millisecond
+	// macroVideoPollInterval is how often waitVideo checks for a signal
+	macroVideoPollInterval = 100 * time.Millisecond
+)
+
+const (
+	MacroRunning   = "running"
+	MacroDone      = "done"
+	MacroCancelled = "cancelled"
+	MacroFailed    = "failed"
+)
+
+// KeyboardMacroState is the progress of a macro, sent in the keyboardMacroState events.
+type KeyboardMacroState struct {
+	// RunID identifies the execution, MacroID the macro executed
+	RunID   string `json:"runId"`
+	MacroID string `json:"macroId"`
+	Name    string `json:"name"`
+	State   string `json:"state"`
+	// Step is the number of steps started so far
+	Step       int    `json:"step"`
+	TotalSteps int    `json:"totalSteps"`
+	Error      string `json:"error,omitempty"`
+}
+
+type macroRun struct {
+	state  KeyboardMacroState
+	cancel context.CancelFunc
+}
+
+var (
+	macroLock    sync.Mutex
+	currentMacro *macroRun
+)
+
+// macroStepKeys resolves the names of the keys and modifiers of a keys step.
+func macroStepKeys(step KeyboardMacroStep) (uint8, []uint8, error) {
+	var modifier uint8
+	keys := make([]uint8, 0, len(step.Keys))
+	for _, name := range step.Keys {
+		code, ok := keyboard.KeyCode(name)
+		if !ok {
+			return 0, nil, fmt.Errorf("unknown key %s", name)
+		}
+		keys = append(keys, code)
+	}
+	for _, name := range step.Modifiers {
+		bit, ok := keyboard.ModifierBit(name)
+		if !ok {
+			return 0, nil, fmt.Errorf("unknown modifier %s", name)
+		}
+		modifier |= bit
+	}
+	return modifier, keys, nil
+}
+
+// checkMacroSteps makes sure that the steps can be played, so that a macro with an
+// unknown key or a character missing from the layout fails before typing anything.
+func checkMacroSteps(steps []KeyboardMacroStep, layout *keyboard.Layout) error {
+	for i, step := range steps {
+		var err error
+		switch step.StepType() {
+		case macros.StepKeys:
+			_, _, err = macroStepKeys(step)
+		case macros.StepText:
+			if unsupported := layout.Unsupported(step.Text); len(unsupported) > 0 {
+				err = fmt.Errorf("characters not available on the %s layout: %q", layout.Code, string(unsupported))
+			}
+		case macros.StepRepeat:
+			err = checkMacroSteps(step.Steps, layout)
+		}
+		if err != nil {
+			return fmt.Errorf("step %d: %w", i+1, err)
+		}
+	}
+	return nil
+}
+
+// rpcExecuteKeyboardMacro starts the macro and returns right away, the progress is
+// sent in keyboardMacroState events. Only one macro runs at a time.
+func rpcExecuteKeyboardMacro(id string) (*KeyboardMacroState, error) {
+	idx := slices.IndexFunc(config.KeyboardMacros, func(m KeyboardMacro) bool { return m.ID == id })
+	if idx == -1 {
+		return nil, fmt.Errorf("macro %s not found", id)
+	}
+	macro := config.KeyboardMacros[idx]
+
+	layout, err := resolveKeyboardLayout("")
+	if err != nil {
+		return nil, err
+	}
+	if err := checkMacroSteps(macro.Steps, layout); err != nil {
+		return nil, err
+	}
+
+	macroLock.Lock()
+	defer macroLock.Unlock()
+	if currentMacro != nil && currentMacro.state.State == MacroRunning {
+		return nil, fmt.Errorf("macro %s is already running", currentMacro.state.Name)
+	}
+	if err := claimHIDAutomation(fmt.Sprintf("macro %s", macro.Name)); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	run := &macroRun{
+		state: KeyboardMacroState{
+			RunID:      uuid.New().String(),
+			MacroID:    macro.ID,
+			Name:       macro.Name,
+			State:      MacroRunning,
+			TotalSteps: macros.CountSteps(macro.Steps),
+		},
+		cancel: cancel,
+	}
+	currentMacro = run
+
+	go runKeyboardMacro(ctx, run, macro.Steps, layout)
+
+	state := run.state
+	return &state, nil
+}
+
+func runKeyboardMacro(ctx context.Context, run *macroRun, steps []KeyboardMacroStep, layout *keyboard.Layout) {
+	defer run.cancel()
+
+	scopedLogger := keyboardLogger.With().
+		Str("runId", run.state.RunID).
+		Str("macroId", run.state.MacroID).
+		Logger()
+	scopedLogger.Info().Str("name", run.state.Name).Msg("executing keyboard macro")
+
+	player := &macroPlayer{ctx: ctx, run: run, layout: layout}
+	err := player.playSteps(steps)
+
+	// the keys of a step stay pressed during its delay, never leave them pressed
+	if releaseErr := gadget.KeyboardReport(0, nil); releaseErr != nil {
+		scopedLogger.Warn().Err(releaseErr).Msg("failed to release the keys")
+	}
+	releaseHIDAutomation()
+
+	macroLock.Lock()
+	switch {
+	case errors.Is(err, context.Canceled):
+		run.state.State = MacroCancelled
+	case err != nil:
+		run.state.State = MacroFailed
+		run.state.Error = err.Error()
+	default:
+		run.state.State = MacroDone
+	}
+	macroLock.Unlock()
+
+	scopedLogger.Info().Err(err).Str("state", run.state.State).Msg("keyboard macro stopped")
+	broadcastKeyboardMacroState(run)
+}
+
+// macroPlayer plays the steps of a macro, the repeats included.
+type macroPlayer struct {
+	ctx     context.Context
+	run     *macroRun
+	layout  *keyboard.Layout
+	started bool
+}
+
+func (p *macroPlayer) playSteps(steps []KeyboardMacroStep) error {
+	for _, step := range steps {
+		if step.StepType() == macros.StepRepeat {
+			for range step.Count {
+				if err := p.playSteps(step.Steps); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+
+		if p.started {
+			if err := sleepContext(p.ctx, macroStepPause); err != nil {
+				return err
+			}
+		}
+		p.started = true
+
+		macroLock.Lock()
+		p.run.state.Step++
+		stepNumber := p.run.state.Step
+		macroLock.Unlock()
+		broadcastKeyboardMacroState(p.run)
+
+		if err := p.playStep(step); err != nil {
+			if errors.Is(err, context.Canceled) {
+				return err
+			}
+			return fmt.Errorf("step %d (%s): %w", stepNumber, step.StepType(), err)
+		}
+	}
+	return nil
+}
+
+func (p *macroPlayer) playStep(step KeyboardMacroStep) error {
+	delay := time.Duration(step.Delay) * time.Millisecond
+
+	var err error
+	switch step.StepType() {
+	case macros.StepKeys:
+		// the delay is how long the keys are held, there's no pause after them
+		return p.pressKeys(step, delay)
+	case macros.StepText:
+		err = p.typeText(step.Text)
+	case macros.StepAbsMouse:
+		err = p.click(func(buttons uint8) error { return gadget.AbsMouseReport(step.X, step.Y, buttons) }, step.Buttons)
+	case macros.StepRelMouse:
+		// the release doesn't move the mouse again
+		moved := false
+		err = p.click(func(buttons uint8) error {
+			if moved {
+				return gadget.RelMouseReport(0, 0, buttons)
+			}
+			moved = true
+			return gadget.RelMouseReport(int8(step.X), int8(step.Y), buttons)
+		}, step.Buttons)
+	case macros.StepATX:
+		err = rpcSetATXPowerAction(step.Action)
+	case macros.StepWaitVideo:
+		err = p.waitVideo(time.Duration(step.Timeout) * time.Millisecond)
+	default:
+		err = fmt.Errorf("unknown step type %q", step.Type)
+	}
+	if err != nil {
+		return err
+	}
+	return sleepContext(p.ctx, delay)
+}
+
+// pressKeys holds the keys of the step for the delay, a step without keys only waits.
+func (p *macroPlayer) pressKeys(step KeyboardMacroStep, delay time.Duration) error {
+	modifier, keys, err := macroStepKeys(step)
+	if err != nil {
+		return err
+	}
+	if len(keys) == 0 && modifier == 0 {
+		return sleepContext(p.ctx, delay)
+	}
+
+	if err := gadget.KeyboardReport(modifier, keys); err != nil {
+		return fmt.Errorf("failed to press the keys: %w", err)
+	}
+	if err := sleepContext(p.ctx, delay); err != nil {
+		return err
+	}
+	if err := gadget.KeyboardReport(0, nil); err != nil {
+		return fmt.Errorf("failed to release the keys: %w", err)
+	}
+	return nil
+}
+
+func (p *macroPlayer) typeText(text string) error {
+	for _, r := range normalizeTypeText(text) {
+		strokes, _ := p.layout.Strokes(r)
+		for _, stroke := range strokes {
+			if err := typeStroke(p.ctx, stroke, defaultTypeTextDelay); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// click sends the mouse report, and when buttons are pressed, releases them once
+// clicked. The buttons are released even when the macro is cancelled.
+func (p *macroPlayer) click(report func(buttons uint8) error, buttons uint8) error {
+	if err := report(buttons); err != nil {
+		return fmt.Errorf("failed to send the mouse report: %w", err)
+	}
+	if buttons == 0 {
+		return nil
+	}
+
+	holdErr := sleepContext(p.ctx, macroClickDuration)
+	if err := report(0); err != nil {
+		return fmt.Errorf("failed to release the mouse buttons: %w", err)
+	}
+	return holdErr
+}
+
+// waitVideo returns once the host outputs a video signal.
+func (p *macroPlayer) waitVideo(timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for !getVideoState().Ready {
+		if time.Now().After(deadline) {
+			return fmt.Errorf("no video signal after %s", timeout)
+		}
+		if err := sleepContext(p.ctx, macroVideoPollInterval); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sleepContext waits for the duration, or until the context is done.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+func broadcastKeyboardMacroState(run *macroRun) {
+	macroLock.Lock()
+	state := run.state
+	macroLock.Unlock()
+
+	broadcastJSONRPCEvent("keyboardMacroState", state)
+}
+
+// rpcCancelMacro stops the running macro and releases its keys.
+func rpcCancelMacro() error {
+	macroLock.Lock()
+	defer macroLock.Unlock()
+
+	if currentMacro == nil || currentMacro.state.State != MacroRunning {
+		return errors.New("no macro is running")
+	}
+	currentMacro.cancel()
+	return nil
+}
+
+// rpcGetKeyboardMacroState returns the progress of the last macro executed, nil when none was.
+func rpcGetKeyboardMacroState() *KeyboardMacroState {
+	macroLock.Lock()
+	defer macroLock.Unlock()
+
+	if currentMacro == nil {
+		return nil
+	}
+	state := currentMacro.state
+	return &state
+}
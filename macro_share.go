@@ -0,0 +1,33 @@
+package kvm
+
+import (
+	"fmt"
+
+	"github.com/jetkvm/kvm/internal/macros"
+)
+
+// The export format and the import are defined in internal/macros.
+type (
+	KeyboardMacrosExport       = macros.KeyboardMacrosExport
+	ImportKeyboardMacrosParams = macros.ImportKeyboardMacrosParams
+	MacroImportResult          = macros.MacroImportResult
+)
+
+func rpcExportKeyboardMacros() KeyboardMacrosExport {
+	return macros.NewExport(config.KeyboardMacros, GetDeviceID(), builtAppVersion)
+}
+
+// rpcImportKeyboardMacros imports the macros of an export. Every macro is validated
+// first, the import is all or nothing.
+func rpcImportKeyboardMacros(params ImportKeyboardMacrosParams) (*MacroImportResult, error) {
+	imported, result, err := macros.Import(config.KeyboardMacros, params)
+	if err != nil || !result.Applied {
+		return result, err
+	}
+
+	config.KeyboardMacros = imported
+	if err := SaveConfig(); err != nil {
+		return nil, fmt.Errorf("failed to save config: %w", err)
+	}
+	return result, nil
+}
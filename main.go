@@ -15,6 +15,10 @@ var appCtx context.Context
 
 func Main() {
 	LoadConfig()
+	initLogSinks()
+	loadLoginSessions()
+	initAuditLog()
+	initEventHistory()
 
 	var cancel context.CancelFunc
 	appCtx, cancel = context.WithCancel(context.Background())
@@ -89,6 +93,8 @@ func Main() {
 	// initialize display
 	initDisplay()
 
+	initMQTT()
+
 	go func() {
 		time.Sleep(15 * time.Minute)
 		for {
@@ -96,7 +102,7 @@ func Main() {
 			if !config.AutoUpdateEnabled {
 				return
 			}
-			if currentSession != nil {
+			if getSessionCount() > 0 {
 				logger.Debug().Msg("skipping update since a session is active")
 				time.Sleep(1 * time.Minute)
 				continue
@@ -126,6 +132,7 @@ func Main() {
 	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
 	<-sigs
 	logger.Info().Msg("JetKVM Shutting Down")
+	loginSessions.flush()
 	//if fuseServer != nil {
 	//	err := setMassStorageImage(" ")
 	//	if err != nil {
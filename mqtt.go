@@ -0,0 +1,436 @@
+package kvm
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+const (
+	mqttConnectTimeout    = 10 * time.Second
+	mqttPublishTimeout    = 5 * time.Second
+	mqttDisconnectQuiesce = 250 // ms
+
+	defaultMQTTDiscoveryPrefix = "homeassistant"
+)
+
+// MQTTConfig is the MQTT client configuration. The state is published under BaseTopic,
+// and the commands are read from BaseTopic/cmd/<command> when EnableCommands is set.
+//
+// The commands carry no credentials, the ACLs of the broker are their only
+// authorization: anyone allowed to publish on BaseTopic/cmd/# can power the host
+// on and off. They are refused while a session has the control of the device.
+type MQTTConfig struct {
+	Enabled bool `json:"enabled"`
+	// Broker is the broker URL, tcp://, ssl://, ws:// or wss://
+	Broker   string `json:"broker"`
+	Username string `json:"username"`
+	Password string `json:"password"`
+	ClientID string `json:"client_id"`
+	// BaseTopic defaults to jetkvm/<device id>
+	BaseTopic      string `json:"base_topic"`
+	EnableCommands bool   `json:"enable_commands"`
+	// HADiscovery publishes the Home Assistant discovery payloads under HADiscoveryPrefix
+	HADiscovery       bool   `json:"ha_discovery"`
+	HADiscoveryPrefix string `json:"ha_discovery_prefix"`
+	// CACertificate is a PEM bundle for brokers using a private CA
+	CACertificate string `json:"ca_certificate,omitempty"`
+}
+
+type MQTTStatus struct {
+	Enabled   bool   `json:"enabled"`
+	Connected bool   `json:"connected"`
+	BaseTopic string `json:"baseTopic,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// mqttCommands maps the command topics onto the RPCs doing the same, the payload is
+// the only argument.
+var mqttCommands = map[string]func(payload string) error{
+	"atx": rpcSetATXPowerAction,
+	"dc": func(payload string) error {
+		switch strings.ToUpper(payload) {
+		case "ON":
+			return rpcSetDCPowerState(true)
+		case "OFF":
+			return rpcSetDCPowerState(false)
+		}
+		return fmt.Errorf("invalid DC power state %q, expected ON or OFF", payload)
+	},
+	"wol": rpcSendWOLMagicPacket,
+}
+
+var (
+	mqttLock      = &sync.Mutex{}
+	mqttClient    mqtt.Client
+	mqttBaseTopic string
+	mqttLastError string
+	// mqttState holds the last payload of every state topic, it is published again
+	// on every (re)connection
+	mqttState = map[string][]byte{}
+)
+
+func (c *MQTTConfig) baseTopic() string {
+	if c.BaseTopic != "" {
+		return strings.TrimSuffix(c.BaseTopic, "/")
+	}
+	return "jetkvm/" + GetDeviceID()
+}
+
+func (c *MQTTConfig) discoveryPrefix() string {
+	if c.HADiscoveryPrefix != "" {
+		return strings.TrimSuffix(c.HADiscoveryPrefix, "/")
+	}
+	return defaultMQTTDiscoveryPrefix
+}
+
+func validateMQTTConfig(cfg *MQTTConfig) error {
+	if !cfg.Enabled {
+		return nil
+	}
+
+	broker, err := url.Parse(cfg.Broker)
+	if err != nil || broker.Host == "" {
+		return errors.New("broker must be an URL such as tcp://broker:1883")
+	}
+	switch broker.Scheme {
+	case "tcp", "mqtt", "ssl", "tls", "mqtts", "ws", "wss":
+	default:
+		return fmt.Errorf("unsupported broker scheme %q", broker.Scheme)
+	}
+
+	if strings.ContainsAny(cfg.BaseTopic, "+#") || strings.ContainsAny(cfg.HADiscoveryPrefix, "+#") {
+		return errors.New("topics cannot contain wildcards")
+	}
+	if cfg.CACertificate != "" && !x509.NewCertPool().AppendCertsFromPEM([]byte(cfg.CACertificate)) {
+		return errors.New("no valid certificate found in the CA certificate")
+	}
+	return nil
+}
+
+func newMQTTClientOptions(cfg *MQTTConfig, baseTopic string) *mqtt.ClientOptions {
+	clientID := cfg.ClientID
+	if clientID == "" {
+		clientID = "jetkvm-" + GetDeviceID()
+	}
+
+	opts := mqtt.NewClientOptions().
+		AddBroker(cfg.Broker).
+		SetClientID(clientID).
+		SetUsername(cfg.Username).
+		SetPassword(cfg.Password).
+		SetConnectTimeout(mqttConnectTimeout).
+		SetAutoReconnect(true).
+		SetConnectRetry(true).
+		SetMaxReconnectInterval(time.Minute).
+		// the command handlers can take a few seconds, like a long power button press
+		SetOrderMatters(false).
+		SetWill(baseTopic+"/status", "offline", 1, true)
+
+	if cfg.CACertificate != "" {
+		pool, err := x509.SystemCertPool()
+		if err != nil || pool == nil {
+			pool = x509.NewCertPool()
+		}
+		pool.AppendCertsFromPEM([]byte(cfg.CACertificate))
+		opts.SetTLSConfig(&tls.Config{RootCAs: pool})
+	}
+
+	opts.SetOnConnectHandler(func(client mqtt.Client) {
+		onMQTTConnect(client, cfg, baseTopic)
+	})
+	opts.SetConnectionLostHandler(func(_ mqtt.Client, err error) {
+		mqttLock.Lock()
+		mqttLastError = err.Error()
+		mqttLock.Unlock()
+		mqttLogger.Warn().Err(err).Msg("MQTT connection lost")
+	})
+	return opts
+}
+
+// onMQTTConnect announces the device, then publishes the discovery payloads and the
+// current state, the broker may have lost the retained messages.
+func onMQTTConnect(client mqtt.Client, cfg *MQTTConfig, baseTopic string) {
+	mqttLogger.Info().Str("broker", cfg.Broker).Str("baseTopic", baseTopic).Msg("MQTT connected")
+
+	mqttLock.Lock()
+	mqttLastError = ""
+	state := make(map[string][]byte, len(mqttState))
+	for name, payload := range mqttState {
+		state[name] = payload
+	}
+	mqttLock.Unlock()
+
+	if cfg.EnableCommands {
+		token := client.Subscribe(baseTopic+"/cmd/+", 1, func(_ mqtt.Client, msg mqtt.Message) {
+			handleMQTTCommand(baseTopic, msg)
+		})
+		if token.WaitTimeout(mqttPublishTimeout) && token.Error() != nil {
+			mqttLogger.Warn().Err(token.Error()).Msg("failed to subscribe to the MQTT command topics")
+		}
+	}
+
+	client.Publish(baseTopic+"/status", 1, true, "online")
+	if cfg.HADiscovery {
+		publishMQTTDiscovery(client, cfg, baseTopic)
+	}
+	for name, payload := range state {
+		client.Publish(baseTopic+"/"+name, 0, true, payload)
+	}
+}
+
+func handleMQTTCommand(baseTopic string, msg mqtt.Message) {
+	name := strings.TrimPrefix(msg.Topic(), baseTopic+"/cmd/")
+	payload := strings.TrimSpace(string(msg.Payload()))
+
+	scopedLogger := mqttLogger.With().Str("command", name).Str("payload", payload).Logger()
+
+	handle, ok := mqttCommands[name]
+	if !ok {
+		scopedLogger.Warn().Msg("unknown MQTT command")
+		return
+	}
+
+	var err error
+	result, errMessage := "denied", ""
+	if controller := getControllerSession(); controller != nil {
+		// the user at the keyboard shouldn't see the host power off under them
+		err = fmt.Errorf("session %s has the control of the device", controller.ID)
+		errMessage = err.Error()
+	} else {
+		err = handle(payload)
+		result, errMessage = auditResult(err)
+	}
+	appendAuditEntry(AuditEntry{
+		Time:   time.Now().UTC(),
+		Origin: "mqtt",
+		Action: "mqtt:" + name,
+		Params: sanitizeAuditParams(map[string]any{"payload": payload}),
+		Result: result,
+		Error:  errMessage,
+	})
+
+	if err != nil {
+		scopedLogger.Warn().Err(err).Msg("MQTT command failed")
+		return
+	}
+	scopedLogger.Info().Msg("MQTT command executed")
+}
+
+// publishMQTTState publishes a retained state topic, strings are sent as is and
+// anything else as JSON. Unchanged payloads aren't sent again.
+func publishMQTTState(name string, value any) {
+	var payload []byte
+	switch v := value.(type) {
+	case string:
+		payload = []byte(v)
+	case bool:
+		payload = []byte("OFF")
+		if v {
+			payload = []byte("ON")
+		}
+	default:
+		data, err := json.Marshal(v)
+		if err != nil {
+			mqttLogger.Warn().Err(err).Str("topic", name).Msg("failed to encode MQTT state")
+			return
+		}
+		payload = data
+	}
+
+	mqttLock.Lock()
+	if string(mqttState[name]) == string(payload) {
+		mqttLock.Unlock()
+		return
+	}
+	mqttState[name] = payload
+	client, baseTopic := mqttClient, mqttBaseTopic
+	mqttLock.Unlock()
+
+	if client == nil || !client.IsConnectionOpen() {
+		return
+	}
+	client.Publish(baseTopic+"/"+name, 0, true, payload)
+}
+
+// publishMQTTNetworkState publishes the addresses, without the LLDP neighbors which
+// change too often to be worth a retained message.
+func publishMQTTNetworkState() {
+	if networkState == nil {
+		return
+	}
+	state := networkState.RpcGetNetworkState()
+	state.LLDPNeighbors = nil
+	publishMQTTState("network", state)
+}
+
+type mqttDiscoveryEntity struct {
+	component string
+	objectID  string
+	config    map[string]any
+}
+
+// publishMQTTDiscovery publishes the Home Assistant discovery payloads, the command
+// entities are only announced when the commands are enabled.
+func publishMQTTDiscovery(client mqtt.Client, cfg *MQTTConfig, baseTopic string) {
+	deviceID := GetDeviceID()
+	name := "JetKVM"
+	if networkState != nil {
+		name = "JetKVM " + networkState.GetHostname()
+	}
+	device := map[string]any{
+		"identifiers":  []string{"jetkvm_" + deviceID},
+		"name":         name,
+		"manufacturer": "JetKVM",
+		"model":        "JetKVM",
+		"sw_version":   builtAppVersion,
+	}
+
+	entities := []mqttDiscoveryEntity{
+		{"binary_sensor", "atx_power", map[string]any{"name": "Host power", "state_topic": baseTopic + "/atx/power", "device_class": "power"}},
+		{"binary_sensor", "atx_hdd", map[string]any{"name": "Host disk activity", "state_topic": baseTopic + "/atx/hdd"}},
+		{"binary_sensor", "dc_power", map[string]any{"name": "DC power", "state_topic": baseTopic + "/dc", "device_class": "power", "value_template": "{{ 'ON' if value_json.isOn else 'OFF' }}"}},
+		{"sensor", "dc_voltage", map[string]any{"name": "DC voltage", "state_topic": baseTopic + "/dc", "device_class": "voltage", "unit_of_measurement": "V", "value_template": "{{ value_json.voltage }}"}},
+		{"sensor", "dc_current", map[string]any{"name": "DC current", "state_topic": baseTopic + "/dc", "device_class": "current", "unit_of_measurement": "A", "value_template": "{{ value_json.current }}"}},
+		{"sensor", "dc_power_draw", map[string]any{"name": "DC power draw", "state_topic": baseTopic + "/dc", "device_class": "power", "unit_of_measurement": "W", "value_template": "{{ value_json.power }}"}},
+		{"binary_sensor", "video_signal", map[string]any{"name": "Video signal", "state_topic": baseTopic + "/video", "device_class": "connectivity", "value_template": "{{ 'ON' if value_json.ready else 'OFF' }}"}},
+		{"sensor", "usb_state", map[string]any{"name": "USB state", "state_topic": baseTopic + "/usb"}},
+		{"sensor", "ip_address", map[string]any{"name": "IP address", "state_topic": baseTopic + "/network", "value_template": "{{ value_json.ipv4 }}", "entity_category": "diagnostic"}},
+		{"sensor", "extension", map[string]any{"name": "Active extension", "state_topic": baseTopic + "/extension", "entity_category": "diagnostic"}},
+	}
+	if cfg.EnableCommands {
+		entities = append(entities,
+			mqttDiscoveryEntity{"button", "power_short", map[string]any{"name": "Press power button", "command_topic": baseTopic + "/cmd/atx", "payload_press": "power-short"}},
+			mqttDiscoveryEntity{"button", "power_long", map[string]any{"name": "Hold power button", "command_topic": baseTopic + "/cmd/atx", "payload_press": "power-long"}},
+			mqttDiscoveryEntity{"button", "reset", map[string]any{"name": "Press reset button", "command_topic": baseTopic + "/cmd/atx", "payload_press": "reset", "device_class": "restart"}},
+			mqttDiscoveryEntity{"switch", "dc_power_switch", map[string]any{"name": "DC power", "command_topic": baseTopic + "/cmd/dc", "state_topic": baseTopic + "/dc", "value_template": "{{ 'ON' if value_json.isOn else 'OFF' }}"}},
+		)
+	}
+
+	nodeID := "jetkvm_" + deviceID
+	for _, entity := range entities {
+		entity.config["unique_id"] = nodeID + "_" + entity.objectID
+		entity.config["object_id"] = nodeID + "_" + entity.objectID
+		entity.config["availability_topic"] = baseTopic + "/status"
+		entity.config["device"] = device
+
+		payload, err := json.Marshal(entity.config)
+		if err != nil {
+			continue
+		}
+		topic := fmt.Sprintf("%s/%s/%s/%s/config", cfg.discoveryPrefix(), entity.component, nodeID, entity.objectID)
+		client.Publish(topic, 1, true, payload)
+	}
+}
+
+// startMQTT connects to the broker in the background, the client keeps retrying
+// until the broker is reachable.
+func startMQTT(cfg MQTTConfig) {
+	stopMQTT()
+	if !cfg.Enabled {
+		return
+	}
+
+	baseTopic := cfg.baseTopic()
+	client := mqtt.NewClient(newMQTTClientOptions(&cfg, baseTopic))
+
+	mqttLock.Lock()
+	mqttClient = client
+	mqttBaseTopic = baseTopic
+	mqttLastError = ""
+	mqttLock.Unlock()
+
+	token := client.Connect()
+	go func() {
+		token.Wait()
+		if err := token.Error(); err != nil {
+			mqttLock.Lock()
+			mqttLastError = err.Error()
+			mqttLock.Unlock()
+			mqttLogger.Warn().Err(err).Msg("failed to connect to the MQTT broker")
+		}
+	}()
+}
+
+func stopMQTT() {
+	mqttLock.Lock()
+	client, baseTopic := mqttClient, mqttBaseTopic
+	mqttClient = nil
+	mqttLock.Unlock()
+
+	if client == nil {
+		return
+	}
+	if client.IsConnectionOpen() {
+		client.Publish(baseTopic+"/status", 1, true, "offline").WaitTimeout(mqttPublishTimeout)
+	}
+	client.Disconnect(mqttDisconnectQuiesce)
+}
+
+// initMQTT starts the MQTT client, if it is enabled.
+func initMQTT() {
+	publishMQTTState("extension", config.ActiveExtension)
+	publishMQTTState("usb", usbState)
+
+	if config.MQTTConfig == nil {
+		return
+	}
+	startMQTT(*config.MQTTConfig)
+}
+
+func rpcGetMQTTConfig() (MQTTConfig, error) {
+	if config.MQTTConfig == nil {
+		return MQTTConfig{}, nil
+	}
+
+	cfg := *config.MQTTConfig
+	cfg.Password = ""
+	return cfg, nil
+}
+
+// rpcSetMQTTConfig stores the MQTT config and reconnects, an empty password keeps
+// the current one.
+func rpcSetMQTTConfig(mqttConfig MQTTConfig) error {
+	mqttConfig.Broker = strings.TrimSpace(mqttConfig.Broker)
+	mqttConfig.BaseTopic = strings.TrimSpace(mqttConfig.BaseTopic)
+	if mqttConfig.Password == "" && config.MQTTConfig != nil {
+		mqttConfig.Password = config.MQTTConfig.Password
+	}
+
+	if err := validateMQTTConfig(&mqttConfig); err != nil {
+		return err
+	}
+
+	config.MQTTConfig = &mqttConfig
+	if err := SaveConfig(); err != nil {
+		return fmt.Errorf("failed to save config: %w", err)
+	}
+
+	startMQTT(mqttConfig)
+
+	mqttLogger.Info().Bool("enabled", mqttConfig.Enabled).Str("broker", mqttConfig.Broker).Msg("MQTT config updated")
+	return nil
+}
+
+func rpcGetMQTTStatus() (MQTTStatus, error) {
+	mqttLock.Lock()
+	defer mqttLock.Unlock()
+
+	status := MQTTStatus{
+		Enabled: mqttClient != nil,
+		Error:   mqttLastError,
+	}
+	if mqttClient != nil {
+		status.Connected = mqttClient.IsConnectionOpen()
+		status.BaseTopic = mqttBaseTopic
+	}
+	return status, nil
+}
@@ -2,6 +2,7 @@ package kvm
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -47,22 +48,47 @@ var (
 	nativeCmdLock = &sync.Mutex{}
 )
 
+// ctrlActionTimeout is how long a ctrl action waits for the response of the native process
+const ctrlActionTimeout = 5 * time.Second
+
+// ctrlActionSlot lets a single ctrl action wait for its response at a time
+var ctrlActionSlot = make(chan struct{}, 1)
+
 func CallCtrlAction(action string, params map[string]interface{}) (*CtrlResponse, error) {
+	return CallCtrlActionContext(context.Background(), action, params)
+}
+
+// CallCtrlActionContext sends the action to the native process and waits for its
+// response, for ctrlActionTimeout at most or until the context is done.
+func CallCtrlActionContext(ctx context.Context, action string, params map[string]interface{}) (*CtrlResponse, error) {
+	select {
+	case ctrlActionSlot <- struct{}{}:
+		defer func() { <-ctrlActionSlot }()
+	case <-ctx.Done():
+		return nil, fmt.Errorf("gave up waiting to send ctrl action %s: %w", action, ctx.Err())
+	}
+
 	lock.Lock()
-	defer lock.Unlock()
 	ctrlAction := CtrlAction{
 		Action: action,
 		Seq:    seq,
 		Params: params,
 	}
 
-	responseChan := make(chan *CtrlResponse)
-	ongoingRequests[seq] = responseChan
+	// buffered, the reader of the socket never blocks on a caller that gave up
+	responseChan := make(chan *CtrlResponse, 1)
+	ongoingRequests[ctrlAction.Seq] = responseChan
 	seq++
+	lock.Unlock()
+
+	defer func() {
+		lock.Lock()
+		delete(ongoingRequests, ctrlAction.Seq)
+		lock.Unlock()
+	}()
 
 	jsonData, err := json.Marshal(ctrlAction)
 	if err != nil {
-		delete(ongoingRequests, ctrlAction.Seq)
 		return nil, fmt.Errorf("error marshaling ctrl action: %w", err)
 	}
 
@@ -74,13 +100,14 @@ func CallCtrlAction(action string, params map[string]interface{}) (*CtrlResponse
 
 	err = WriteCtrlMessage(jsonData)
 	if err != nil {
-		delete(ongoingRequests, ctrlAction.Seq)
 		return nil, ErrorfL(&scopedLogger, "error writing ctrl message", err)
 	}
 
+	timer := time.NewTimer(ctrlActionTimeout)
+	defer timer.Stop()
+
 	select {
 	case response := <-responseChan:
-		delete(ongoingRequests, seq)
 		if response.Error != "" {
 			return nil, ErrorfL(
 				&scopedLogger,
@@ -89,9 +116,9 @@ func CallCtrlAction(action string, params map[string]interface{}) (*CtrlResponse
 			)
 		}
 		return response, nil
-	case <-time.After(5 * time.Second):
-		close(responseChan)
-		delete(ongoingRequests, seq)
+	case <-ctx.Done():
+		return nil, ErrorfL(&scopedLogger, "gave up waiting for response", ctx.Err())
+	case <-timer.C:
 		return nil, ErrorfL(&scopedLogger, "timeout waiting for response", nil)
 	}
 }
@@ -207,9 +234,15 @@ func handleCtrlClient(conn net.Conn) {
 		scopedLogger.Trace().Interface("data", ctrlResp).Msg("ctrl sock msg")
 
 		if ctrlResp.Seq != 0 {
+			lock.Lock()
 			responseChan, ok := ongoingRequests[ctrlResp.Seq]
+			lock.Unlock()
 			if ok {
-				responseChan <- &ctrlResp
+				// a second response to the same request is dropped
+				select {
+				case responseChan <- &ctrlResp:
+				default:
+				}
 			}
 		}
 		switch ctrlResp.Event {
@@ -242,12 +275,7 @@ func handleVideoClient(conn net.Conn) {
 		now := time.Now()
 		sinceLastFrame := now.Sub(lastFrame)
 		lastFrame = now
-		if currentSession != nil {
-			err := currentSession.VideoTrack.WriteSample(media.Sample{Data: inboundPacket[:n], Duration: sinceLastFrame})
-			if err != nil {
-				scopedLogger.Warn().Err(err).Msg("error writing sample")
-			}
-		}
+		writeVideoSample(media.Sample{Data: inboundPacket[:n], Duration: sinceLastFrame}, &scopedLogger)
 	}
 }
 
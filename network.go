@@ -18,6 +18,7 @@ var (
 func networkStateChanged() {
 	// do not block the main thread
 	go waitCtrlAndRequestDisplayUpdate(true)
+	go publishMQTTNetworkState()
 
 	// always restart mDNS when the network state changes
 	if mDNS != nil {
@@ -33,10 +34,11 @@ func initNetwork() error {
 	ensureConfigLoaded()
 
 	state, err := network.NewNetworkInterfaceState(&network.NetworkInterfaceOptions{
-		DefaultHostname: GetDefaultHostname(),
-		InterfaceName:   NetIfName,
-		NetworkConfig:   config.NetworkConfig,
-		Logger:          networkLogger,
+		DefaultHostname:   GetDefaultHostname(),
+		InterfaceName:     NetIfName,
+		NetworkConfig:     config.NetworkConfig,
+		Logger:            networkLogger,
+		SystemDescription: fmt.Sprintf("JetKVM %s", builtAppVersion),
 		OnStateChange: func(state *network.NetworkInterfaceState) {
 			networkStateChanged()
 		},
@@ -46,16 +48,22 @@ func initNetwork() error {
 		OnDhcpLeaseChange: func(lease *udhcpc.Lease) {
 			networkStateChanged()
 
-			if currentSession == nil {
-				return
-			}
-
-			writeJSONRPCEvent("networkState", networkState.RpcGetNetworkState(), currentSession)
+			broadcastJSONRPCEvent("networkState", networkState.RpcGetNetworkState())
 		},
 		OnConfigChange: func(networkConfig *network.NetworkConfig) {
 			config.NetworkConfig = networkConfig
+			if timeSync != nil {
+				timeSync.SetNetworkConfig(networkConfig)
+			}
 			networkStateChanged()
 		},
+		OnLLDPNeighborsChange: func(neighbors []network.LLDPNeighbor) {
+			if networkState == nil {
+				return
+			}
+
+			broadcastJSONRPCEvent("networkState", networkState.RpcGetNetworkState())
+		},
 	})
 
 	if state == nil {
@@ -0,0 +1,531 @@
+package kvm
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/gin-gonic/gin"
+	"golang.org/x/oauth2"
+)
+
+const (
+	oidcCallbackPath = "/auth/oidc/callback"
+	oidcStateCookie  = "oidcState"
+
+	// OIDCRequestTimeout is the timeout for the requests to the identity provider
+	OIDCRequestTimeout = 10 * time.Second
+
+	// an authorization has to be completed within oidcPendingTTL
+	oidcPendingTTL   = 10 * time.Minute
+	oidcMaxPending   = 32
+	oidcDefaultGroup = "groups"
+)
+
+// OIDCConfig is the identity provider used for the "oidc" local auth mode.
+// A login is only accepted when the identity matches one of the allow-lists.
+type OIDCConfig struct {
+	Enabled      bool     `json:"enabled"`
+	Issuer       string   `json:"issuer"`
+	ClientID     string   `json:"client_id"`
+	ClientSecret string   `json:"client_secret,omitempty"`
+	Scopes       []string `json:"scopes,omitempty"`
+	// RedirectURL defaults to the address the browser used to reach the device
+	RedirectURL string `json:"redirect_url,omitempty"`
+	// CACertificate is a PEM bundle for identity providers using a private CA
+	CACertificate string `json:"ca_certificate,omitempty"`
+	GroupsClaim   string `json:"groups_claim,omitempty"`
+	// Role is given to every OIDC login, defaults to admin
+	Role Role `json:"role,omitempty"`
+
+	AllowedSubjects []string `json:"allowed_subjects"`
+	AllowedEmails   []string `json:"allowed_emails"`
+	AllowedGroups   []string `json:"allowed_groups"`
+
+	// PasswordFallback keeps the local password usable, in case the identity provider is unreachable
+	PasswordFallback bool `json:"password_fallback"`
+}
+
+type pendingOIDCLogin struct {
+	nonce       string
+	verifier    string
+	redirectURL string
+	createdAt   time.Time
+}
+
+type oidcIdentityClaims struct {
+	Email         string `json:"email"`
+	EmailVerified *bool  `json:"email_verified"`
+}
+
+var (
+	oidcLock = &sync.Mutex{}
+	// oidcProvider is the discovered provider of the configured issuer, reset when the config changes
+	oidcProvider *oidc.Provider
+	oidcClient   *http.Client
+	oidcPending  = make(map[string]*pendingOIDCLogin)
+)
+
+func isOIDCLoginEnabled() bool {
+	return config.LocalAuthMode == "oidc" && config.OIDCConfig != nil && config.OIDCConfig.Enabled
+}
+
+// isPasswordLoginEnabled reports whether the local password can be used to log in.
+func isPasswordLoginEnabled() bool {
+	switch config.LocalAuthMode {
+	case "password":
+		return true
+	case "oidc":
+		return config.HashedPassword != "" && config.OIDCConfig != nil && config.OIDCConfig.PasswordFallback
+	}
+	return false
+}
+
+func randomURLToken() (string, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
+
+func newOIDCHTTPClient(cfg *OIDCConfig) (*http.Client, error) {
+	if cfg.CACertificate == "" {
+		return &http.Client{Timeout: OIDCRequestTimeout}, nil
+	}
+
+	pool, err := x509.SystemCertPool()
+	if err != nil || pool == nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM([]byte(cfg.CACertificate)) {
+		return nil, errors.New("no valid certificate found in the CA certificate")
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = &tls.Config{RootCAs: pool}
+
+	return &http.Client{Timeout: OIDCRequestTimeout, Transport: transport}, nil
+}
+
+// getOIDCProvider discovers the issuer on first use, the result is kept until the config changes.
+func getOIDCProvider(ctx context.Context) (*oidc.Provider, *http.Client, error) {
+	oidcLock.Lock()
+	defer oidcLock.Unlock()
+
+	if oidcProvider != nil {
+		return oidcProvider, oidcClient, nil
+	}
+
+	cfg := config.OIDCConfig
+	if cfg == nil || cfg.Issuer == "" {
+		return nil, nil, errors.New("OIDC is not configured")
+	}
+
+	client, err := newOIDCHTTPClient(cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	provider, err := oidc.NewProvider(oidc.ClientContext(ctx, client), cfg.Issuer)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to discover the OIDC issuer: %w", err)
+	}
+
+	oidcProvider = provider
+	oidcClient = client
+	return provider, client, nil
+}
+
+func resetOIDCProvider() {
+	oidcLock.Lock()
+	defer oidcLock.Unlock()
+
+	oidcProvider = nil
+	oidcClient = nil
+	oidcPending = make(map[string]*pendingOIDCLogin)
+}
+
+func oidcRedirectURL(c *gin.Context) string {
+	if config.OIDCConfig.RedirectURL != "" {
+		return config.OIDCConfig.RedirectURL
+	}
+
+	scheme := "http"
+	if c.Request.TLS != nil {
+		scheme = "https"
+	}
+	return (&url.URL{Scheme: scheme, Host: c.Request.Host, Path: oidcCallbackPath}).String()
+}
+
+func oidcOAuth2Config(provider *oidc.Provider, redirectURL string) *oauth2.Config {
+	scopes := []string{oidc.ScopeOpenID, "profile", "email"}
+	for _, scope := range config.OIDCConfig.Scopes {
+		if !slices.Contains(scopes, scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+
+	return &oauth2.Config{
+		ClientID:     config.OIDCConfig.ClientID,
+		ClientSecret: config.OIDCConfig.ClientSecret,
+		Endpoint:     provider.Endpoint(),
+		RedirectURL:  redirectURL,
+		Scopes:       scopes,
+	}
+}
+
+func addPendingOIDCLogin(state string, pending *pendingOIDCLogin) {
+	oidcLock.Lock()
+	defer oidcLock.Unlock()
+
+	now := time.Now()
+	for key, existing := range oidcPending {
+		if now.Sub(existing.createdAt) > oidcPendingTTL {
+			delete(oidcPending, key)
+		}
+	}
+
+	// drop the oldest authorizations when too many were started and never completed
+	for len(oidcPending) >= oidcMaxPending {
+		var oldestKey string
+		var oldest time.Time
+		for key, existing := range oidcPending {
+			if oldestKey == "" || existing.createdAt.Before(oldest) {
+				oldestKey = key
+				oldest = existing.createdAt
+			}
+		}
+		delete(oidcPending, oldestKey)
+	}
+
+	oidcPending[state] = pending
+}
+
+// takePendingOIDCLogin returns the authorization of the state, it can only be used once.
+func takePendingOIDCLogin(state string) (*pendingOIDCLogin, bool) {
+	oidcLock.Lock()
+	defer oidcLock.Unlock()
+
+	pending, ok := oidcPending[state]
+	if !ok {
+		return nil, false
+	}
+	delete(oidcPending, state)
+
+	if time.Since(pending.createdAt) > oidcPendingTTL {
+		return nil, false
+	}
+	return pending, true
+}
+
+// extractOIDCGroups reads the groups claim, which providers send as a list or a single string.
+func extractOIDCGroups(idToken *oidc.IDToken, claim string) []string {
+	var claims map[string]any
+	if err := idToken.Claims(&claims); err != nil {
+		return nil
+	}
+
+	switch value := claims[claim].(type) {
+	case string:
+		return []string{value}
+	case []any:
+		groups := make([]string, 0, len(value))
+		for _, group := range value {
+			if s, ok := group.(string); ok {
+				groups = append(groups, s)
+			}
+		}
+		return groups
+	}
+	return nil
+}
+
+// isOIDCIdentityAllowed checks the identity against the allow-lists, nobody is allowed when they're all empty.
+func isOIDCIdentityAllowed(cfg *OIDCConfig, subject string, email string, groups []string) bool {
+	if slices.Contains(cfg.AllowedSubjects, subject) {
+		return true
+	}
+
+	if email != "" && slices.ContainsFunc(cfg.AllowedEmails, func(allowed string) bool {
+		return strings.EqualFold(allowed, email)
+	}) {
+		return true
+	}
+
+	return slices.ContainsFunc(groups, func(group string) bool {
+		return slices.Contains(cfg.AllowedGroups, group)
+	})
+}
+
+func handleOIDCLogin(c *gin.Context) {
+	if !isOIDCLoginEnabled() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "OIDC login is not enabled"})
+		return
+	}
+
+	release, throttled := abortIfLoginThrottled(c)
+	if throttled {
+		return
+	}
+	defer release()
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), OIDCRequestTimeout)
+	defer cancel()
+
+	provider, _, err := getOIDCProvider(ctx)
+	if err != nil {
+		logger.Warn().Err(err).Msg("failed to start OIDC login")
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Identity provider is unavailable"})
+		return
+	}
+
+	state, err := randomURLToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate state"})
+		return
+	}
+	nonce, err := randomURLToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate nonce"})
+		return
+	}
+
+	pending := &pendingOIDCLogin{
+		nonce:       nonce,
+		verifier:    oauth2.GenerateVerifier(),
+		redirectURL: oidcRedirectURL(c),
+		createdAt:   time.Now(),
+	}
+	addPendingOIDCLogin(state, pending)
+
+	// the state is also bound to this browser, so that a login can't be completed in another one
+	c.SetCookie(oidcStateCookie, state, int(oidcPendingTTL.Seconds()), oidcCallbackPath, "", false, true)
+
+	authURL := oidcOAuth2Config(provider, pending.redirectURL).AuthCodeURL(
+		state,
+		oidc.Nonce(nonce),
+		oauth2.S256ChallengeOption(pending.verifier),
+	)
+	c.Redirect(http.StatusFound, authURL)
+}
+
+func handleOIDCCallback(c *gin.Context) {
+	if !isOIDCLoginEnabled() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "OIDC login is not enabled"})
+		return
+	}
+
+	release, throttled := abortIfLoginThrottled(c)
+	if throttled {
+		return
+	}
+	defer release()
+
+	if errCode := c.Query("error"); errCode != "" {
+		logger.Warn().Str("error", errCode).Str("description", c.Query("error_description")).Msg("OIDC login rejected by the identity provider")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Login was rejected by the identity provider"})
+		return
+	}
+
+	state := c.Query("state")
+	cookieState, _ := c.Cookie(oidcStateCookie)
+	c.SetCookie(oidcStateCookie, "", -1, oidcCallbackPath, "", false, true)
+
+	if state == "" || state != cookieState {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid OIDC state"})
+		return
+	}
+
+	pending, ok := takePendingOIDCLogin(state)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "OIDC login expired, please try again"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), OIDCRequestTimeout)
+	defer cancel()
+
+	provider, client, err := getOIDCProvider(ctx)
+	if err != nil {
+		logger.Warn().Err(err).Msg("failed to complete OIDC login")
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Identity provider is unavailable"})
+		return
+	}
+	ctx = oidc.ClientContext(ctx, client)
+
+	token, err := oidcOAuth2Config(provider, pending.redirectURL).Exchange(
+		ctx,
+		c.Query("code"),
+		oauth2.VerifierOption(pending.verifier),
+	)
+	if err != nil {
+		logger.Warn().Err(err).Msg("failed to exchange the OIDC authorization code")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Failed to exchange the authorization code"})
+		return
+	}
+
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "No ID token in the response"})
+		return
+	}
+
+	idToken, err := provider.Verifier(&oidc.Config{ClientID: config.OIDCConfig.ClientID}).Verify(ctx, rawIDToken)
+	if err != nil {
+		logger.Warn().Err(err).Msg("invalid OIDC ID token")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid ID token"})
+		return
+	}
+
+	if idToken.Nonce != pending.nonce {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid ID token nonce"})
+		return
+	}
+
+	var claims oidcIdentityClaims
+	if err := idToken.Claims(&claims); err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid ID token claims"})
+		return
+	}
+
+	// an unverified address could be set to anything by the user
+	email := claims.Email
+	if claims.EmailVerified != nil && !*claims.EmailVerified {
+		email = ""
+	}
+
+	groupsClaim := config.OIDCConfig.GroupsClaim
+	if groupsClaim == "" {
+		groupsClaim = oidcDefaultGroup
+	}
+	groups := extractOIDCGroups(idToken, groupsClaim)
+
+	l := logger.With().Str("subject", idToken.Subject).Str("email", claims.Email).Strs("groups", groups).Logger()
+
+	if !isOIDCIdentityAllowed(config.OIDCConfig, idToken.Subject, email, groups) {
+		loginRateLimiter.recordFailure(c.ClientIP(), "login-oidc")
+		l.Warn().Msg("OIDC identity is not allowed to log in")
+		auditHTTP(c, "loginOIDC", idToken.Subject, "denied", "identity not allowed")
+		c.JSON(http.StatusForbidden, gin.H{"error": "This account is not allowed to access the device"})
+		return
+	}
+	loginRateLimiter.recordSuccess(c.ClientIP())
+
+	role := config.OIDCConfig.Role
+	if role == "" {
+		role = RoleAdmin
+	}
+	username := claims.Email
+	if username == "" {
+		username = idToken.Subject
+	}
+
+	if err := startLoginSession(c, loginIdentity{Username: username, Role: role}); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create login session"})
+		return
+	}
+
+	auditHTTP(c, "loginOIDC", username, "ok", "")
+	l.Info().Msg("OIDC login successful")
+	c.Redirect(http.StatusFound, "/")
+}
+
+func validateOIDCConfig(cfg *OIDCConfig) error {
+	issuer, err := url.Parse(cfg.Issuer)
+	if err != nil || issuer.Host == "" || (issuer.Scheme != "https" && issuer.Scheme != "http") {
+		return errors.New("issuer must be a http or https URL")
+	}
+
+	if cfg.ClientID == "" {
+		return errors.New("client id cannot be empty")
+	}
+
+	if cfg.RedirectURL != "" {
+		redirect, err := url.Parse(cfg.RedirectURL)
+		if err != nil || redirect.Host == "" {
+			return errors.New("redirect URL must be an absolute URL")
+		}
+	}
+
+	if cfg.Role != "" && !cfg.Role.isValid() {
+		return fmt.Errorf("invalid role %q", cfg.Role)
+	}
+
+	if cfg.Enabled && len(cfg.AllowedSubjects) == 0 && len(cfg.AllowedEmails) == 0 && len(cfg.AllowedGroups) == 0 {
+		return errors.New("at least one allowed subject, email or group is required")
+	}
+
+	if _, err := newOIDCHTTPClient(cfg); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// rpcGetOIDCConfig returns the OIDC config, the client secret is never sent back.
+func rpcGetOIDCConfig() (OIDCConfig, error) {
+	if config.OIDCConfig == nil {
+		return OIDCConfig{}, nil
+	}
+
+	cfg := *config.OIDCConfig
+	cfg.ClientSecret = ""
+	return cfg, nil
+}
+
+// rpcSetOIDCConfig stores the OIDC config, an empty client secret keeps the current one.
+// Enabling it switches the local auth mode to "oidc" once the issuer could be discovered.
+func rpcSetOIDCConfig(oidcConfig OIDCConfig) error {
+	oidcConfig.Issuer = strings.TrimSuffix(strings.TrimSpace(oidcConfig.Issuer), "/")
+	oidcConfig.ClientID = strings.TrimSpace(oidcConfig.ClientID)
+	if oidcConfig.ClientSecret == "" && config.OIDCConfig != nil {
+		oidcConfig.ClientSecret = config.OIDCConfig.ClientSecret
+	}
+
+	if err := validateOIDCConfig(&oidcConfig); err != nil {
+		return err
+	}
+
+	if oidcConfig.Enabled {
+		client, err := newOIDCHTTPClient(&oidcConfig)
+		if err != nil {
+			return err
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), OIDCRequestTimeout)
+		defer cancel()
+		if _, err := oidc.NewProvider(oidc.ClientContext(ctx, client), oidcConfig.Issuer); err != nil {
+			return fmt.Errorf("failed to discover the OIDC issuer: %w", err)
+		}
+	}
+
+	config.OIDCConfig = &oidcConfig
+	switch {
+	case oidcConfig.Enabled:
+		config.LocalAuthMode = "oidc"
+	case config.LocalAuthMode == "oidc" && config.HashedPassword != "":
+		config.LocalAuthMode = "password"
+	case config.LocalAuthMode == "oidc":
+		config.LocalAuthMode = "noPassword"
+	}
+	resetOIDCProvider()
+
+	if err := SaveConfig(); err != nil {
+		return fmt.Errorf("failed to save config: %w", err)
+	}
+
+	logger.Info().Str("issuer", oidcConfig.Issuer).Bool("enabled", oidcConfig.Enabled).Msg("OIDC config updated")
+	return nil
+}
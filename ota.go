@@ -290,11 +290,7 @@ var otaState = OTAState{}
 
 func triggerOTAStateUpdate() {
 	go func() {
-		if currentSession == nil {
-			logger.Info().Msg("No active RPC session, skipping update state update")
-			return
-		}
-		writeJSONRPCEvent("otaState", otaState, currentSession)
+		broadcastJSONRPCEvent("otaState", otaState)
 	}()
 }
 
@@ -317,6 +313,9 @@ func TryUpdate(ctx context.Context, deviceId string, includePreRelease bool) err
 	defer func() {
 		otaState.Updating = false
 		triggerOTAStateUpdate()
+		if otaState.Error != "" {
+			recordEvent(EventOTAFailed, "", "update failed", map[string]any{"error": otaState.Error})
+		}
 	}()
 
 	updateStatus, err := GetUpdateStatus(ctx, deviceId, includePreRelease)
@@ -475,6 +474,11 @@ func TryUpdate(ctx context.Context, deviceId string, includePreRelease bool) err
 	}
 
 	if rebootNeeded {
+		recordEvent(EventOTACompleted, "", "update installed, rebooting", map[string]any{
+			"appVersion":    remote.AppVersion,
+			"systemVersion": remote.SystemVersion,
+		})
+
 		scopedLogger.Info().Msg("System Rebooting in 10s")
 		time.Sleep(10 * time.Second)
 		cmd := exec.Command("reboot")
@@ -0,0 +1,245 @@
+package kvm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+
+	"github.com/jetkvm/kvm/internal/keyboard"
+)
+
+// typeText types text on the host from here, the browser only sends the text instead of
+// streaming a pair of keyboard reports per character, so a long paste doesn't depend on
+// the latency of the network nor get garbled by a lost report.
+
+const (
+	defaultTypeTextDelay = 20 * time.Millisecond
+	maxTypeTextDelay     = time.Second
+	// maxTypeTextLength is in characters, a cloud-init file or a script fits easily
+	maxTypeTextLength = 64 * 1024
+	// typeTextProgressInterval is how often the progress is sent while typing
+	typeTextProgressInterval = 250 * time.Millisecond
+)
+
+const (
+	TypeTextRunning   = "running"
+	TypeTextDone      = "done"
+	TypeTextCancelled = "cancelled"
+	TypeTextFailed    = "failed"
+)
+
+type TypeTextParams struct {
+	Text string `json:"text"`
+	// Layout is the keyboard layout of the host, Config.KeyboardLayout when empty
+	Layout string `json:"layout,omitempty"`
+	// Delay is the pause between two keys in milliseconds, 20 when zero
+	Delay int `json:"delay,omitempty"`
+}
+
+// TypeTextState is the progress of a paste, sent in the typeTextState events.
+type TypeTextState struct {
+	ID     string `json:"id"`
+	State  string `json:"state"`
+	Layout string `json:"layout"`
+	Typed  int    `json:"typed"`
+	Total  int    `json:"total"`
+	Error  string `json:"error,omitempty"`
+}
+
+type typeTextJob struct {
+	state  TypeTextState
+	cancel context.CancelFunc
+}
+
+var (
+	typeTextLock    sync.Mutex
+	currentTypeText *typeTextJob
+)
+
+// normalizeTypeText turns the line endings into \n, the Enter key.
+func normalizeTypeText(text string) string {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	return strings.ReplaceAll(text, "\r", "\n")
+}
+
+func resolveKeyboardLayout(code string) (*keyboard.Layout, error) {
+	if code == "" {
+		code = config.KeyboardLayout
+	}
+	if code == "" {
+		code = keyboard.DefaultLayout
+	}
+	layout, ok := keyboard.Get(code)
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown keyboard layout %s", errInvalidParams, code)
+	}
+	return layout, nil
+}
+
+// rpcTypeText starts typing the text and returns right away, the progress is sent in
+// typeTextState events. Only one text is typed at a time.
+func rpcTypeText(params TypeTextParams) (*TypeTextState, error) {
+	text := normalizeTypeText(params.Text)
+	total := utf8.RuneCountInString(text)
+	if total == 0 {
+		return nil, fmt.Errorf("%w: the text is empty", errInvalidParams)
+	}
+	if total > maxTypeTextLength {
+		return nil, fmt.Errorf("%w: the text is longer than %d characters", errInvalidParams, maxTypeTextLength)
+	}
+
+	delay := time.Duration(params.Delay) * time.Millisecond
+	if delay == 0 {
+		delay = defaultTypeTextDelay
+	}
+	if delay < 0 || delay > maxTypeTextDelay {
+		return nil, fmt.Errorf("%w: the delay must be between 0 and %d ms", errInvalidParams, maxTypeTextDelay.Milliseconds())
+	}
+
+	layout, err := resolveKeyboardLayout(params.Layout)
+	if err != nil {
+		return nil, err
+	}
+	if unsupported := layout.Unsupported(text); len(unsupported) > 0 {
+		return nil, fmt.Errorf("%w: characters not available on the %s layout: %q", errInvalidParams, layout.Code, string(unsupported))
+	}
+
+	typeTextLock.Lock()
+	defer typeTextLock.Unlock()
+	if currentTypeText != nil && currentTypeText.state.State == TypeTextRunning {
+		return nil, errors.New("another text is being typed")
+	}
+	if err := claimHIDAutomation("a paste"); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	job := &typeTextJob{
+		state: TypeTextState{
+			ID:     uuid.New().String(),
+			State:  TypeTextRunning,
+			Layout: layout.Code,
+			Total:  total,
+		},
+		cancel: cancel,
+	}
+	currentTypeText = job
+
+	go runTypeText(ctx, job, layout, text, delay)
+
+	state := job.state
+	return &state, nil
+}
+
+func runTypeText(ctx context.Context, job *typeTextJob, layout *keyboard.Layout, text string, delay time.Duration) {
+	defer job.cancel()
+
+	scopedLogger := keyboardLogger.With().
+		Str("id", job.state.ID).
+		Str("layout", layout.Code).
+		Int("total", job.state.Total).
+		Logger()
+	scopedLogger.Info().Msg("typing text")
+
+	broadcastTypeTextState(job)
+	lastProgress := time.Now()
+
+	err := func() error {
+		for _, r := range text {
+			strokes, _ := layout.Strokes(r)
+			for _, stroke := range strokes {
+				if err := typeStroke(ctx, stroke, delay); err != nil {
+					return err
+				}
+			}
+
+			typeTextLock.Lock()
+			job.state.Typed++
+			typeTextLock.Unlock()
+
+			if time.Since(lastProgress) >= typeTextProgressInterval {
+				broadcastTypeTextState(job)
+				lastProgress = time.Now()
+			}
+		}
+		return nil
+	}()
+
+	// never leave a key pressed on the host
+	if releaseErr := gadget.KeyboardReport(0, nil); releaseErr != nil {
+		scopedLogger.Warn().Err(releaseErr).Msg("failed to release the keys")
+	}
+	releaseHIDAutomation()
+
+	typeTextLock.Lock()
+	switch {
+	case errors.Is(err, context.Canceled):
+		job.state.State = TypeTextCancelled
+	case err != nil:
+		job.state.State = TypeTextFailed
+		job.state.Error = err.Error()
+	default:
+		job.state.State = TypeTextDone
+	}
+	typeTextLock.Unlock()
+
+	scopedLogger.Info().Err(err).Int("typed", job.state.Typed).Str("state", job.state.State).Msg("stopped typing text")
+	broadcastTypeTextState(job)
+}
+
+// typeStroke presses and releases the key, then waits for the delay.
+func typeStroke(ctx context.Context, stroke keyboard.Stroke, delay time.Duration) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := gadget.KeyboardReport(stroke.Modifier, []uint8{stroke.Key}); err != nil {
+		return fmt.Errorf("failed to press the key: %w", err)
+	}
+	if err := gadget.KeyboardReport(0, nil); err != nil {
+		return fmt.Errorf("failed to release the key: %w", err)
+	}
+
+	return sleepContext(ctx, delay)
+}
+
+func broadcastTypeTextState(job *typeTextJob) {
+	typeTextLock.Lock()
+	state := job.state
+	typeTextLock.Unlock()
+
+	broadcastJSONRPCEvent("typeTextState", state)
+}
+
+// rpcCancelTypeText stops the text being typed, the keys typed so far stay typed.
+func rpcCancelTypeText() error {
+	typeTextLock.Lock()
+	defer typeTextLock.Unlock()
+
+	if currentTypeText == nil || currentTypeText.state.State != TypeTextRunning {
+		return errors.New("no text is being typed")
+	}
+	currentTypeText.cancel()
+	return nil
+}
+
+// rpcGetTypeTextState returns the progress of the last text typed, nil when none was.
+func rpcGetTypeTextState() *TypeTextState {
+	typeTextLock.Lock()
+	defer typeTextLock.Unlock()
+
+	if currentTypeText == nil {
+		return nil
+	}
+	state := currentTypeText.state
+	return &state
+}
+
+func rpcGetKeyboardLayouts() map[string]string {
+	return keyboard.Layouts()
+}
@@ -40,12 +40,13 @@ func (w *WebRTCDiskReader) Read(ctx context.Context, offset int64, size int64) (
 		return nil, err
 	}
 
-	if currentSession == nil || currentSession.DiskChannel == nil {
+	session := getVirtualMediaSession()
+	if session == nil || session.DiskChannel == nil {
 		return nil, errors.New("not active session")
 	}
 
 	logger.Debug().Str("request", string(jsonBytes)).Msg("reading from webrtc")
-	err = currentSession.DiskChannel.SendText(string(jsonBytes))
+	err = session.DiskChannel.SendText(string(jsonBytes))
 	if err != nil {
 		return nil, err
 	}
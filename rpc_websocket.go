@@ -0,0 +1,104 @@
+package kvm
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/coder/websocket"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// maxRPCWebSocketMessage is the largest JSON-RPC request accepted over the websocket
+	maxRPCWebSocketMessage = 1024 * 1024
+	rpcWebSocketWriteWait  = 10 * time.Second
+)
+
+// rpcWebSocketTransport sends the JSON-RPC messages of a session as websocket text frames.
+type rpcWebSocketTransport struct {
+	ctx  context.Context
+	conn *websocket.Conn
+}
+
+func (t *rpcWebSocketTransport) SendText(message string) error {
+	ctx, cancel := context.WithTimeout(t.ctx, rpcWebSocketWriteWait)
+	defer cancel()
+
+	return t.conn.Write(ctx, websocket.MessageText, []byte(message))
+}
+
+// handleRPCWebSocket speaks the JSON-RPC protocol of the "rpc" data channel over a
+// websocket, for the tools that don't want to negotiate a WebRTC session. The
+// connection is a session of its own: it receives the events and shows up in the
+// session list, but doesn't take the control unless it requests it.
+func handleRPCWebSocket(c *gin.Context) {
+	if err := canAddSession(); err != nil {
+		sendErrorJsonThenAbort(c, http.StatusServiceUnavailable, err.Error())
+		return
+	}
+
+	loginSessionID := getRequestLoginSessionID(c)
+	role, userID, err := resolveSessionIdentity(loginSessionID)
+	if err != nil {
+		sendErrorJsonThenAbort(c, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	conn, err := websocket.Accept(c.Writer, c.Request, nil)
+	if err != nil {
+		websocketLogger.Warn().Err(err).Msg("failed to accept the JSON-RPC websocket")
+		return
+	}
+	defer conn.CloseNow()
+	conn.SetReadLimit(maxRPCWebSocketMessage)
+
+	ctx, cancel := context.WithCancel(c.Request.Context())
+	defer cancel()
+
+	session := &Session{
+		Source:         c.ClientIP(),
+		LoginSessionID: loginSessionID,
+		UserID:         userID,
+		Role:           role,
+		RPCChannel:     &rpcWebSocketTransport{ctx: ctx, conn: conn},
+		closeWebSocket: cancel,
+	}
+	if err := addSession(session); err != nil {
+		_ = conn.Close(websocket.StatusTryAgainLater, err.Error())
+		return
+	}
+	defer removeSession(session)
+
+	scopedLogger := websocketLogger.With().
+		Str("component", "rpc").
+		Str("source", session.Source).
+		Str("sessionId", session.ID).
+		Logger()
+	scopedLogger.Info().Msg("JSON-RPC websocket connected")
+
+	triggerOTAStateUpdate()
+	triggerVideoStateUpdate()
+	triggerUSBStateUpdate()
+	go writeJSONRPCEvent("sessionState", getSessionState(session), session)
+
+	for {
+		messageType, data, err := conn.Read(ctx)
+		if err != nil {
+			status := websocket.CloseStatus(err)
+			if status == websocket.StatusNormalClosure || status == websocket.StatusGoingAway || errors.Is(err, context.Canceled) {
+				scopedLogger.Info().Msg("JSON-RPC websocket closed")
+			} else {
+				scopedLogger.Warn().Err(err).Msg("JSON-RPC websocket read failed")
+			}
+			return
+		}
+
+		if messageType != websocket.MessageText {
+			scopedLogger.Warn().Msg("ignoring binary message on the JSON-RPC websocket")
+			continue
+		}
+		go handleRPCRequest(data, session)
+	}
+}
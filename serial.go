@@ -38,6 +38,8 @@ func runATXControl() {
 	scopedLogger := serialLogger.With().Str("service", "atx_control").Logger()
 
 	reader := bufio.NewReader(port)
+	// the first line only tells the current state, it isn't a change
+	stateKnown := false
 	for {
 		line, err := reader.ReadString('\n')
 		if err != nil {
@@ -57,12 +59,12 @@ func runATXControl() {
 		newBtnRSTState := line[2] == '1'
 		newBtnPWRState := line[3] == '1'
 
-		if currentSession != nil {
-			writeJSONRPCEvent("atxState", ATXState{
-				Power: newLedPWRState,
-				HDD:   newLedHDDState,
-			}, currentSession)
-		}
+		broadcastJSONRPCEvent("atxState", ATXState{
+			Power: newLedPWRState,
+			HDD:   newLedHDDState,
+		})
+		publishMQTTState("atx/power", newLedPWRState)
+		publishMQTTState("atx/hdd", newLedHDDState)
 
 		if newLedHDDState != ledHDDState ||
 			newLedPWRState != ledPWRState ||
@@ -75,12 +77,21 @@ func runATXControl() {
 				Bool("pwr", newBtnPWRState).
 				Msg("Status changed")
 
+			if stateKnown && newLedPWRState != ledPWRState {
+				message := "host powered off"
+				if newLedPWRState {
+					message = "host powered on"
+				}
+				recordEvent(EventATXPowerChanged, "", message, map[string]any{"power": newLedPWRState})
+			}
+
 			// Update states
 			ledHDDState = newLedHDDState
 			ledPWRState = newLedPWRState
 			btnRSTState = newBtnRSTState
 			btnPWRState = newBtnPWRState
 		}
+		stateKnown = true
 	}
 }
 
@@ -188,9 +199,8 @@ func runDCControl() {
 		dcState.Current = amps
 		dcState.Power = watts
 
-		if currentSession != nil {
-			writeJSONRPCEvent("dcState", dcState, currentSession)
-		}
+		broadcastJSONRPCEvent("dcState", dcState)
+		publishMQTTState("dc", dcState)
 	}
 }
 
@@ -0,0 +1,373 @@
+package kvm
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pion/webrtc/v4/pkg/media"
+	"github.com/rs/zerolog"
+)
+
+// maxSessions is the maximum number of concurrent WebRTC sessions,
+// every session gets its own copy of the video stream.
+const maxSessions = 8
+
+var (
+	sessions     = make([]*Session, 0)
+	sessionsLock = &sync.RWMutex{}
+
+	// controllerSession is the only session allowed to send HID input.
+	controllerSession *Session
+	// controlRequests are the sessions waiting for the controller to hand over control.
+	controlRequests = make([]*Session, 0)
+)
+
+var errTooManySessions = fmt.Errorf("too many active sessions, maximum is %d", maxSessions)
+
+type SessionInfo struct {
+	ID               string    `json:"id"`
+	Source           string    `json:"source"`
+	IsCloud          bool      `json:"isCloud"`
+	Transport        string    `json:"transport"` // "webrtc" or "websocket"
+	Role             Role      `json:"role"`
+	IsController     bool      `json:"isController"`
+	ControlRequested bool      `json:"controlRequested"`
+	ConnectedAt      time.Time `json:"connectedAt"`
+}
+
+type SessionState struct {
+	SessionID    string        `json:"sessionId"`
+	Role         Role          `json:"role"`
+	IsController bool          `json:"isController"`
+	Sessions     []SessionInfo `json:"sessions"`
+}
+
+func canAddSession() error {
+	sessionsLock.RLock()
+	defer sessionsLock.RUnlock()
+
+	if len(sessions) >= maxSessions {
+		return errTooManySessions
+	}
+	return nil
+}
+
+// canControl reports whether the role of the session allows it to hold the control.
+func (s *Session) canControl() bool {
+	return s.Role.allows(PermissionControl)
+}
+
+// isWebSocket reports whether the session is a JSON-RPC websocket, without video.
+func (s *Session) isWebSocket() bool {
+	return s.peerConnection == nil
+}
+
+// takesControl reports whether the session gets the control when nobody holds it,
+// the websocket sessions have to request it as they are mostly used for monitoring.
+func (s *Session) takesControl() bool {
+	return s.canControl() && !s.isWebSocket()
+}
+
+func (s *Session) transport() string {
+	if s.isWebSocket() {
+		return "websocket"
+	}
+	return "webrtc"
+}
+
+// close ends the session, it is removed once its transport is closed.
+func (s *Session) close() {
+	if s.isWebSocket() {
+		if s.closeWebSocket != nil {
+			s.closeWebSocket()
+		}
+		return
+	}
+	_ = s.peerConnection.Close()
+}
+
+// addSession registers a new session, the first session allowed to control gets the control.
+func addSession(session *Session) error {
+	sessionsLock.Lock()
+
+	if len(sessions) >= maxSessions {
+		sessionsLock.Unlock()
+		return errTooManySessions
+	}
+
+	if session.ID == "" {
+		session.ID = uuid.New().String()
+	}
+	session.ConnectedAt = time.Now()
+	sessions = append(sessions, session)
+
+	if controllerSession == nil && session.takesControl() {
+		controllerSession = session
+	}
+	sessionsLock.Unlock()
+
+	webrtcLogger.Info().
+		Str("sessionId", session.ID).
+		Str("source", session.Source).
+		Bool("isController", isSessionController(session)).
+		Msg("session added")
+
+	recordEvent(EventSessionOpened, session.ID, "session opened", map[string]any{
+		"source":  session.Source,
+		"isCloud": session.IsCloud,
+		"role":    session.Role,
+	})
+
+	broadcastSessionState()
+	return nil
+}
+
+// removeSession unregisters a session, if it was holding the control, the control
+// goes to the first pending request or to the longest connected session allowed to control.
+func removeSession(session *Session) {
+	sessionsLock.Lock()
+
+	idx := slices.Index(sessions, session)
+	if idx == -1 {
+		sessionsLock.Unlock()
+		return
+	}
+	sessions = slices.Delete(sessions, idx, idx+1)
+	controlRequests = slices.DeleteFunc(controlRequests, func(s *Session) bool { return s == session })
+
+	if controllerSession == session {
+		controllerSession = nil
+		if len(controlRequests) > 0 {
+			controllerSession = controlRequests[0]
+			controlRequests = controlRequests[1:]
+		} else if idx := slices.IndexFunc(sessions, (*Session).takesControl); idx != -1 {
+			controllerSession = sessions[idx]
+		}
+	}
+	sessionsLock.Unlock()
+
+	webrtcLogger.Info().Str("sessionId", session.ID).Msg("session removed")
+
+	recordEvent(EventSessionClosed, session.ID, "session closed", map[string]any{
+		"duration": time.Since(session.ConnectedAt).Round(time.Second).String(),
+	})
+
+	broadcastSessionState()
+}
+
+func getSessionCount() int {
+	sessionsLock.RLock()
+	defer sessionsLock.RUnlock()
+
+	return len(sessions)
+}
+
+// getSessions returns a snapshot of the active sessions.
+func getSessions() []*Session {
+	sessionsLock.RLock()
+	defer sessionsLock.RUnlock()
+
+	return slices.Clone(sessions)
+}
+
+func getSessionByID(id string) *Session {
+	sessionsLock.RLock()
+	defer sessionsLock.RUnlock()
+
+	for _, s := range sessions {
+		if s.ID == id {
+			return s
+		}
+	}
+	return nil
+}
+
+func isSessionController(session *Session) bool {
+	if session == nil {
+		return false
+	}
+
+	sessionsLock.RLock()
+	defer sessionsLock.RUnlock()
+
+	return controllerSession == session
+}
+
+func getControllerSession() *Session {
+	sessionsLock.RLock()
+	defer sessionsLock.RUnlock()
+
+	return controllerSession
+}
+
+func getSessionState(session *Session) SessionState {
+	sessionsLock.RLock()
+	defer sessionsLock.RUnlock()
+
+	infos := make([]SessionInfo, 0, len(sessions))
+	for _, s := range sessions {
+		infos = append(infos, SessionInfo{
+			ID:               s.ID,
+			Source:           s.Source,
+			IsCloud:          s.IsCloud,
+			Transport:        s.transport(),
+			Role:             s.Role,
+			IsController:     s == controllerSession,
+			ControlRequested: slices.Contains(controlRequests, s),
+			ConnectedAt:      s.ConnectedAt,
+		})
+	}
+
+	return SessionState{
+		SessionID:    session.ID,
+		Role:         session.Role,
+		IsController: session == controllerSession,
+		Sessions:     infos,
+	}
+}
+
+// broadcastJSONRPCEvent sends the event to every session with an open RPC channel.
+func broadcastJSONRPCEvent(event string, params interface{}) {
+	for _, session := range getSessions() {
+		if session.RPCChannel == nil {
+			continue
+		}
+		writeJSONRPCEvent(event, params, session)
+	}
+}
+
+// broadcastSessionState sends every session its own view of the session list.
+func broadcastSessionState() {
+	for _, session := range getSessions() {
+		if session.RPCChannel == nil {
+			continue
+		}
+		writeJSONRPCEvent("sessionState", getSessionState(session), session)
+	}
+}
+
+func setControllerSession(session *Session) {
+	sessionsLock.Lock()
+	controllerSession = session
+	controlRequests = slices.DeleteFunc(controlRequests, func(s *Session) bool { return s == session })
+	sessionsLock.Unlock()
+
+	if session != nil {
+		webrtcLogger.Info().Str("sessionId", session.ID).Msg("session control granted")
+	}
+
+	broadcastSessionState()
+}
+
+func rpcGetSessionState(session *Session) (SessionState, error) {
+	return getSessionState(session), nil
+}
+
+// rpcRequestControl gives the control to the session right away when nobody holds it,
+// otherwise the controller is asked to grant it.
+func rpcRequestControl(session *Session) (bool, error) {
+	sessionsLock.Lock()
+	if controllerSession == session {
+		sessionsLock.Unlock()
+		return true, nil
+	}
+
+	if controllerSession == nil {
+		sessionsLock.Unlock()
+		setControllerSession(session)
+		return true, nil
+	}
+
+	controller := controllerSession
+	if !slices.Contains(controlRequests, session) {
+		controlRequests = append(controlRequests, session)
+	}
+	sessionsLock.Unlock()
+
+	writeJSONRPCEvent("controlRequested", SessionInfo{
+		ID:               session.ID,
+		Source:           session.Source,
+		IsCloud:          session.IsCloud,
+		Transport:        session.transport(),
+		Role:             session.Role,
+		ControlRequested: true,
+		ConnectedAt:      session.ConnectedAt,
+	}, controller)
+	broadcastSessionState()
+
+	return false, nil
+}
+
+func rpcGrantControl(session *Session, sessionID string) error {
+	if !isSessionController(session) {
+		return errors.New("only the controlling session can grant control")
+	}
+
+	target := getSessionByID(sessionID)
+	if target == nil {
+		return fmt.Errorf("session %s not found", sessionID)
+	}
+
+	if !target.canControl() {
+		return fmt.Errorf("session %s is not allowed to control the device", sessionID)
+	}
+
+	setControllerSession(target)
+	return nil
+}
+
+func rpcDenyControl(session *Session, sessionID string) error {
+	if !isSessionController(session) {
+		return errors.New("only the controlling session can deny control")
+	}
+
+	target := getSessionByID(sessionID)
+	if target == nil {
+		return fmt.Errorf("session %s not found", sessionID)
+	}
+
+	sessionsLock.Lock()
+	controlRequests = slices.DeleteFunc(controlRequests, func(s *Session) bool { return s == target })
+	sessionsLock.Unlock()
+
+	writeJSONRPCEvent("controlDenied", nil, target)
+	broadcastSessionState()
+	return nil
+}
+
+// rpcReleaseControl hands the control to the first pending request, if any.
+func rpcReleaseControl(session *Session) error {
+	sessionsLock.Lock()
+	if controllerSession != session {
+		sessionsLock.Unlock()
+		return errors.New("session does not have control")
+	}
+
+	var next *Session
+	if len(controlRequests) > 0 {
+		next = controlRequests[0]
+	}
+	sessionsLock.Unlock()
+
+	setControllerSession(next)
+	return nil
+}
+
+// writeVideoSample fans the video sample out to every session.
+func writeVideoSample(sample media.Sample, l *zerolog.Logger) {
+	sessionsLock.RLock()
+	defer sessionsLock.RUnlock()
+
+	for _, session := range sessions {
+		if session.VideoTrack == nil {
+			continue
+		}
+		if err := session.VideoTrack.WriteSample(sample); err != nil {
+			l.Warn().Err(err).Str("sessionId", session.ID).Msg("error writing sample")
+		}
+	}
+}
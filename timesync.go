@@ -43,6 +43,12 @@ func initTimeSync() {
 	timeSync = timesync.NewTimeSync(&timesync.TimeSyncOptions{
 		Logger:        timesyncLogger,
 		NetworkConfig: config.NetworkConfig,
+		DhcpNtpServers: func() []string {
+			if networkState == nil {
+				return nil
+			}
+			return networkState.NtpAddresses()
+		},
 		PreCheckFunc: func() (bool, error) {
 			if !networkState.IsOnline() {
 				return false, nil
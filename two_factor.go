@@ -0,0 +1,427 @@
+package kvm
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jetkvm/kvm/internal/totp"
+)
+
+const (
+	totpIssuer = "JetKVM"
+	// time steps accepted before and after the current one, for clock drift
+	totpSkew = 1
+
+	totpRecoveryCodeCount = 10
+)
+
+var errTOTPCodeInvalid = errors.New("invalid two-factor code")
+
+type TOTPState struct {
+	Enabled                bool `json:"enabled"`
+	EnrollmentPending      bool `json:"enrollmentPending"`
+	RecoveryCodesRemaining int  `json:"recoveryCodesRemaining"`
+}
+
+type TOTPEnrollment struct {
+	Secret        string   `json:"secret"`
+	URI           string   `json:"uri"`
+	RecoveryCodes []string `json:"recoveryCodes"`
+}
+
+type pendingTOTPEnrollment struct {
+	secret        string
+	recoveryCodes []string
+}
+
+// The device password and each named local user have their own second factor, the
+// maps below are keyed by the user id, the device password being the empty id.
+var (
+	totpLock = &sync.Mutex{}
+	// totpPending are the enrollments waiting for the first code to be confirmed
+	totpPending = map[string]*pendingTOTPEnrollment{}
+	// totpLastUsedStep rejects the replay of a code within its validity window
+	totpLastUsedStep = map[string]int64{}
+)
+
+// totpAccount is the stored second factor of the device password or of a user.
+type totpAccount struct {
+	name          string // shown by the authenticator app
+	enabled       bool
+	secret        string
+	recoveryCodes []string // hashed
+}
+
+// isTOTPEnabled reports whether the device password login requires a second factor,
+// this includes the password fallback of the "oidc" mode.
+func isTOTPEnabled() bool {
+	return config.HashedPassword != "" && config.TOTPEnabled && config.TOTPSecret != ""
+}
+
+// isUserTOTPEnabled reports whether the login of the named user requires a second
+// factor, the users that didn't enroll log in with their password only.
+func isUserTOTPEnabled(userID string) bool {
+	account, err := getTOTPAccount(userID)
+	return err == nil && account.enabled
+}
+
+func getTOTPAccount(userID string) (totpAccount, error) {
+	if userID == "" {
+		return totpAccount{
+			name:          GetDeviceID(),
+			enabled:       isTOTPEnabled(),
+			secret:        config.TOTPSecret,
+			recoveryCodes: config.TOTPRecoveryCodes,
+		}, nil
+	}
+
+	localUsersLock.Lock()
+	defer localUsersLock.Unlock()
+
+	idx := slices.IndexFunc(config.LocalUsers, func(u LocalUser) bool { return u.ID == userID })
+	if idx == -1 {
+		return totpAccount{}, fmt.Errorf("user %s not found", userID)
+	}
+	user := &config.LocalUsers[idx]
+	return totpAccount{
+		name:          user.Username + "@" + GetDeviceID(),
+		enabled:       user.TOTPEnabled && user.TOTPSecret != "",
+		secret:        user.TOTPSecret,
+		recoveryCodes: user.TOTPRecoveryCodes,
+	}, nil
+}
+
+// setTOTPAccount stores the second factor and saves the config.
+func setTOTPAccount(userID string, account totpAccount) error {
+	if userID == "" {
+		config.TOTPEnabled = account.enabled
+		config.TOTPSecret = account.secret
+		config.TOTPRecoveryCodes = account.recoveryCodes
+		if err := SaveConfig(); err != nil {
+			return fmt.Errorf("failed to save config: %w", err)
+		}
+		return nil
+	}
+
+	_, err := updateLocalUser(userID, func(user *LocalUser) {
+		user.TOTPEnabled = account.enabled
+		user.TOTPSecret = account.secret
+		user.TOTPRecoveryCodes = account.recoveryCodes
+	})
+	return err
+}
+
+func generateRecoveryCodes() ([]string, error) {
+	encoding := base32.StdEncoding.WithPadding(base32.NoPadding)
+
+	codes := make([]string, 0, totpRecoveryCodeCount)
+	for i := 0; i < totpRecoveryCodeCount; i++ {
+		raw := make([]byte, 5)
+		if _, err := rand.Read(raw); err != nil {
+			return nil, fmt.Errorf("failed to generate recovery code: %w", err)
+		}
+		code := strings.ToLower(encoding.EncodeToString(raw))
+		codes = append(codes, code[:4]+"-"+code[4:])
+	}
+
+	return codes, nil
+}
+
+func hashRecoveryCodes(codes []string) []string {
+	hashed := make([]string, 0, len(codes))
+	for _, code := range codes {
+		hashed = append(hashed, hashSecretToken(code))
+	}
+	return hashed
+}
+
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(strings.TrimSpace(code))
+	code = strings.ReplaceAll(code, " ", "")
+	if len(code) == 8 && !strings.Contains(code, "-") {
+		code = code[:4] + "-" + code[4:]
+	}
+	return code
+}
+
+// verifyTOTPLocked checks a TOTP code or a recovery code of the account, a recovery
+// code can only be used once.
+func verifyTOTPLocked(userID string, code string) error {
+	account, err := getTOTPAccount(userID)
+	if err != nil {
+		return err
+	}
+	if !account.enabled {
+		return errors.New("two-factor authentication is not enabled")
+	}
+
+	code = strings.TrimSpace(code)
+	if code == "" {
+		return errTOTPCodeInvalid
+	}
+
+	if step, ok := totp.Validate(account.secret, code, time.Now(), totpSkew); ok {
+		if step <= totpLastUsedStep[userID] {
+			return errors.New("two-factor code was already used")
+		}
+		totpLastUsedStep[userID] = step
+		return nil
+	}
+
+	hashed := []byte(hashSecretToken(normalizeRecoveryCode(code)))
+	idx := slices.IndexFunc(account.recoveryCodes, func(stored string) bool {
+		return subtle.ConstantTimeCompare(hashed, []byte(stored)) == 1
+	})
+	if idx == -1 {
+		return errTOTPCodeInvalid
+	}
+
+	account.recoveryCodes = slices.Delete(slices.Clone(account.recoveryCodes), idx, idx+1)
+	if err := setTOTPAccount(userID, account); err != nil {
+		return err
+	}
+
+	logger.Warn().Str("userId", userID).Int("remaining", len(account.recoveryCodes)).Msg("two-factor recovery code used")
+	return nil
+}
+
+// verifyTOTP checks the code of the device password, or of the named user.
+func verifyTOTP(userID string, code string) error {
+	totpLock.Lock()
+	defer totpLock.Unlock()
+
+	return verifyTOTPLocked(userID, code)
+}
+
+func disableTOTPLocked() {
+	config.TOTPEnabled = false
+	config.TOTPSecret = ""
+	config.TOTPRecoveryCodes = nil
+	delete(totpPending, "")
+}
+
+// forgetUserTOTP drops the enrollment and the replay state of a deleted user.
+func forgetUserTOTP(userID string) {
+	totpLock.Lock()
+	defer totpLock.Unlock()
+
+	delete(totpPending, userID)
+	delete(totpLastUsedStep, userID)
+}
+
+func getTOTPState(userID string) (TOTPState, error) {
+	totpLock.Lock()
+	defer totpLock.Unlock()
+
+	account, err := getTOTPAccount(userID)
+	if err != nil {
+		return TOTPState{}, err
+	}
+	return TOTPState{
+		Enabled:                account.enabled,
+		EnrollmentPending:      totpPending[userID] != nil,
+		RecoveryCodesRemaining: len(account.recoveryCodes),
+	}, nil
+}
+
+// enrollTOTP starts the enrollment, it only takes effect once confirmed with a code.
+func enrollTOTP(userID string) (*TOTPEnrollment, error) {
+	totpLock.Lock()
+	defer totpLock.Unlock()
+
+	account, err := getTOTPAccount(userID)
+	if err != nil {
+		return nil, err
+	}
+	if account.enabled {
+		return nil, errors.New("two-factor authentication is already enabled")
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	recoveryCodes, err := generateRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+
+	totpPending[userID] = &pendingTOTPEnrollment{
+		secret:        secret,
+		recoveryCodes: recoveryCodes,
+	}
+
+	return &TOTPEnrollment{
+		Secret:        secret,
+		URI:           totp.URI(totpIssuer, account.name, secret),
+		RecoveryCodes: recoveryCodes,
+	}, nil
+}
+
+func confirmTOTP(userID string, code string) error {
+	totpLock.Lock()
+	defer totpLock.Unlock()
+
+	pending := totpPending[userID]
+	if pending == nil {
+		return errors.New("no two-factor enrollment in progress")
+	}
+
+	step, ok := totp.Validate(pending.secret, code, time.Now(), totpSkew)
+	if !ok {
+		return errTOTPCodeInvalid
+	}
+
+	err := setTOTPAccount(userID, totpAccount{
+		enabled:       true,
+		secret:        pending.secret,
+		recoveryCodes: hashRecoveryCodes(pending.recoveryCodes),
+	})
+	if err != nil {
+		return err
+	}
+	delete(totpPending, userID)
+	totpLastUsedStep[userID] = step
+
+	logger.Info().Str("userId", userID).Msg("two-factor authentication enabled")
+	return nil
+}
+
+func disableTOTP(userID string, code string) error {
+	totpLock.Lock()
+	defer totpLock.Unlock()
+
+	if err := verifyTOTPLocked(userID, code); err != nil {
+		return err
+	}
+
+	if err := setTOTPAccount(userID, totpAccount{}); err != nil {
+		return err
+	}
+	delete(totpPending, userID)
+
+	logger.Info().Str("userId", userID).Msg("two-factor authentication disabled")
+	return nil
+}
+
+func resetTOTPRecoveryCodes(userID string, code string) ([]string, error) {
+	totpLock.Lock()
+	defer totpLock.Unlock()
+
+	if err := verifyTOTPLocked(userID, code); err != nil {
+		return nil, err
+	}
+
+	recoveryCodes, err := generateRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+
+	account, err := getTOTPAccount(userID)
+	if err != nil {
+		return nil, err
+	}
+	account.recoveryCodes = hashRecoveryCodes(recoveryCodes)
+	if err := setTOTPAccount(userID, account); err != nil {
+		return nil, err
+	}
+
+	logger.Info().Str("userId", userID).Msg("two-factor recovery codes regenerated")
+	return recoveryCodes, nil
+}
+
+// The handlers below manage the second factor of the device password.
+
+func rpcGetTOTPState() (TOTPState, error) {
+	return getTOTPState("")
+}
+
+func rpcEnrollTOTP() (*TOTPEnrollment, error) {
+	if !isPasswordLoginEnabled() {
+		return nil, errors.New("two-factor authentication requires a password")
+	}
+	return enrollTOTP("")
+}
+
+func rpcConfirmTOTP(code string) error {
+	return confirmTOTP("", code)
+}
+
+func rpcDisableTOTP(code string) error {
+	return disableTOTP("", code)
+}
+
+func rpcResetTOTPRecoveryCodes(code string) ([]string, error) {
+	return resetTOTPRecoveryCodes("", code)
+}
+
+// The handlers below manage the second factor of the named user of the session.
+
+func sessionUserID(session *Session) (string, error) {
+	if session == nil || session.UserID == "" {
+		return "", errors.New("only named users have their own two-factor authentication")
+	}
+	return session.UserID, nil
+}
+
+func rpcGetUserTOTPState(session *Session) (TOTPState, error) {
+	userID, err := sessionUserID(session)
+	if err != nil {
+		return TOTPState{}, err
+	}
+	return getTOTPState(userID)
+}
+
+func rpcEnrollUserTOTP(session *Session) (*TOTPEnrollment, error) {
+	userID, err := sessionUserID(session)
+	if err != nil {
+		return nil, err
+	}
+	return enrollTOTP(userID)
+}
+
+func rpcConfirmUserTOTP(session *Session, code string) error {
+	userID, err := sessionUserID(session)
+	if err != nil {
+		return err
+	}
+	return confirmTOTP(userID, code)
+}
+
+func rpcDisableUserTOTP(session *Session, code string) error {
+	userID, err := sessionUserID(session)
+	if err != nil {
+		return err
+	}
+	return disableTOTP(userID, code)
+}
+
+func rpcResetUserRecoveryCodes(session *Session, code string) ([]string, error) {
+	userID, err := sessionUserID(session)
+	if err != nil {
+		return nil, err
+	}
+	return resetTOTPRecoveryCodes(userID, code)
+}
+
+// rpcResetLocalUserTOTP lets an admin remove the second factor of a user who lost it.
+func rpcResetLocalUserTOTP(id string) error {
+	totpLock.Lock()
+	defer totpLock.Unlock()
+
+	if err := setTOTPAccount(id, totpAccount{}); err != nil {
+		return err
+	}
+	delete(totpPending, id)
+
+	logger.Info().Str("userId", id).Msg("two-factor authentication of local user reset")
+	return nil
+}
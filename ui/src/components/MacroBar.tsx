@@ -4,12 +4,11 @@ import { LuCommand } from "react-icons/lu";
 import { Button } from "@components/Button";
 import Container from "@components/Container";
 import { useMacrosStore } from "@/hooks/stores";
-import useKeyboard from "@/hooks/useKeyboard";
 import { useJsonRpc } from "@/hooks/useJsonRpc";
+import notifications from "@/notifications";
 
 export default function MacroBar() {
   const { macros, initialized, loadMacros, setSendFn } = useMacrosStore();
-  const { executeMacro } = useKeyboard();
   const [send] = useJsonRpc();
 
   useEffect(() => {
@@ -38,7 +37,13 @@ export default function MacroBar() {
               size="XS"
               theme="light"
               text={macro.name}
-              onClick={() => executeMacro(macro.steps)}
+              onClick={() =>
+                send("executeKeyboardMacro", { id: macro.id }, resp => {
+                  if ("error" in resp) {
+                    notifications.error(`Failed to run macro: ${resp.error.data || resp.error.message}`);
+                  }
+                })
+              }
             />
           ))}
         </div>
@@ -1,7 +1,7 @@
 import { useState } from "react";
 import { LuPlus } from "react-icons/lu";
 
-import { KeySequence } from "@/hooks/stores";
+import { KeySequence, KeySequenceStep } from "@/hooks/stores";
 import { Button } from "@/components/Button";
 import { InputFieldWithLabel, FieldError } from "@/components/InputField";
 import Fieldset from "@/components/Fieldset";
@@ -10,6 +10,8 @@ import {
   DEFAULT_DELAY,
   MAX_STEPS_PER_MACRO,
   MAX_KEYS_PER_STEP,
+  isMacroStepEmpty,
+  validateMacroStep,
 } from "@/constants/macros";
 import FieldLabel from "@/components/FieldLabel";
 
@@ -21,6 +23,7 @@ interface ValidationErrors {
       keys?: string;
       modifiers?: string;
       delay?: string;
+      step?: string;
     }
   >;
 }
@@ -63,14 +66,18 @@ export function MacroForm({
     if (!macro.steps?.length) {
       newErrors.steps = { 0: { keys: "At least one step is required" } };
     } else {
-      const hasKeyOrModifier = macro.steps.some(
-        step => (step.keys?.length || 0) > 0 || (step.modifiers?.length || 0) > 0,
-      );
+      const hasAction = macro.steps.some(step => !isMacroStepEmpty(step));
 
-      if (!hasKeyOrModifier) {
+      if (!hasAction) {
         newErrors.steps = {
-          0: { keys: "At least one step must have keys or modifiers" },
+          0: { keys: "At least one step must have keys, modifiers or another action" },
         };
+      } else {
+        macro.steps.forEach((step, stepIndex) => {
+          const error = validateMacroStep(step);
+          if (!error) return;
+          newErrors.steps = { ...newErrors.steps, [stepIndex]: { step: error } };
+        });
       }
     }
 
@@ -161,6 +168,18 @@ export function MacroForm({
     setMacro({ ...macro, steps: newSteps });
   };
 
+  const handleStepChange = (stepIndex: number, step: KeySequenceStep) => {
+    const newSteps = [...(macro.steps || [])];
+    newSteps[stepIndex] = step;
+    setMacro({ ...macro, steps: newSteps });
+
+    if (errors.steps) {
+      const newErrors = { ...errors };
+      delete newErrors.steps;
+      setErrors(newErrors);
+    }
+  };
+
   const handleStepMove = (stepIndex: number, direction: "up" | "down") => {
     const newSteps = [...(macro.steps || [])];
     const newIndex = direction === "up" ? stepIndex - 1 : stepIndex + 1;
@@ -196,7 +215,7 @@ export function MacroForm({
             <div className="flex items-center gap-1">
               <FieldLabel
                 label="Steps"
-                description={`Keys/modifiers executed in sequence with a delay between each step.`}
+                description={`Keys, text, mouse and power actions executed in sequence with a delay between each step.`}
               />
             </div>
             <span className="text-slate-500 dark:text-slate-400">
@@ -233,7 +252,9 @@ export function MacroForm({
                     handleModifierChange(stepIndex, modifiers)
                   }
                   onDelayChange={delay => handleDelayChange(stepIndex, delay)}
+                  onStepChange={newStep => handleStepChange(stepIndex, newStep)}
                   isLastStep={stepIndex === (macro.steps?.length || 0) - 1}
+                  error={errors.steps?.[stepIndex]?.step}
                 />
               ))}
             </div>
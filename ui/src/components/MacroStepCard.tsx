@@ -1,11 +1,29 @@
-import { LuArrowUp, LuArrowDown, LuX, LuTrash2 } from "react-icons/lu";
+import { useState } from "react";
+import { LuArrowUp, LuArrowDown, LuX, LuTrash2, LuPlus } from "react-icons/lu";
 
 import { Button } from "@/components/Button";
 import { Combobox } from "@/components/Combobox";
 import { SelectMenuBasic } from "@/components/SelectMenuBasic";
 import Card from "@/components/Card";
+import { InputFieldWithLabel, FieldError } from "@/components/InputField";
+import { TextAreaWithLabel } from "@/components/TextArea";
+import { KeySequenceStep, KeySequenceStepType } from "@/hooks/stores";
 import { keys, modifiers, keyDisplayMap } from "@/keyboardMappings";
-import { MAX_KEYS_PER_STEP, DEFAULT_DELAY } from "@/constants/macros";
+import {
+  MAX_KEYS_PER_STEP,
+  DEFAULT_DELAY,
+  MAX_STEPS_PER_MACRO,
+  MAX_TEXT_LENGTH,
+  MAX_REPEAT_COUNT,
+  MAX_REPEAT_DEPTH,
+  MAX_WAIT_VIDEO_TIMEOUT,
+  MAX_ABS_MOUSE_POSITION,
+  MAX_REL_MOUSE_MOVE,
+  STEP_TYPE_OPTIONS,
+  ATX_ACTION_OPTIONS,
+  MOUSE_BUTTON_OPTIONS,
+  newMacroStep,
+} from "@/constants/macros";
 import FieldLabel from "@/components/FieldLabel";
 
 // Filter out modifier keys since they're handled in the modifiers section
@@ -49,14 +67,8 @@ const PRESET_DELAYS = basePresetDelays.map(delay => {
   return delay;
 });
 
-interface MacroStep {
-  keys: string[];
-  modifiers: string[];
-  delay: number;
-}
-
 interface MacroStepCardProps {
-  step: MacroStep;
+  step: KeySequenceStep;
   stepIndex: number;
   onDelete?: () => void;
   onMoveUp?: () => void;
@@ -66,7 +78,12 @@ interface MacroStepCardProps {
   keyQuery: string;
   onModifierChange: (modifiers: string[]) => void;
   onDelayChange: (delay: number) => void;
+  // onStepChange replaces the step, for the fields of the steps that don't press keys
+  onStepChange: (step: KeySequenceStep) => void;
   isLastStep: boolean;
+  // depth is how many repeats the step is nested in
+  depth?: number;
+  error?: string;
 }
 
 const ensureArray = <T,>(arr: T[] | null | undefined): T[] => {
@@ -84,8 +101,13 @@ export function MacroStepCard({
   keyQuery,
   onModifierChange,
   onDelayChange,
-  isLastStep
+  onStepChange,
+  isLastStep,
+  depth = 0,
+  error,
 }: MacroStepCardProps) {
+  const stepType = step.type ?? "keys";
+
   const getFilteredKeys = () => {
     const selectedKeys = ensureArray(step.keys);
     const availableKeys = keyOptions.filter(option => !selectedKeys.includes(option.value));
@@ -137,99 +159,295 @@ export function MacroStepCard({
       </div>
 
       <div className="space-y-4 mt-2">
-        <div className="w-full flex flex-col gap-2">
-          <FieldLabel label="Modifiers" />
-          <div className="inline-flex flex-wrap gap-3">
-            {Object.entries(groupedModifiers).map(([group, mods]) => (
-              <div key={group} className="relative min-w-[120px] rounded-md border border-slate-200 dark:border-slate-700 p-2">
-                <span className="absolute -top-2.5 left-2 px-1 text-xs font-medium bg-white dark:bg-slate-800 text-slate-500 dark:text-slate-400">
-                  {group}
-                </span>
-                <div className="flex flex-wrap gap-4 pt-1">
-                  {mods.map(option => (
-                    <Button
-                      key={option.value}
-                      size="XS"
-                      theme={ensureArray(step.modifiers).includes(option.value) ? "primary" : "light"}
-                      text={option.label.split(' ')[1] || option.label}
-                      onClick={() => {
-                        const modifiersArray = ensureArray(step.modifiers);
-                        const isSelected = modifiersArray.includes(option.value);
-                        const newModifiers = isSelected
-                          ? modifiersArray.filter(m => m !== option.value)
-                          : [...modifiersArray, option.value];
-                        onModifierChange(newModifiers);
-                      }}
-                    />
+        <SelectMenuBasic
+          size="SM"
+          label="Action"
+          fullWidth
+          value={stepType}
+          onChange={e => {
+            const type = e.target.value as KeySequenceStepType;
+            onStepChange({ ...newMacroStep(type), delay: step.delay });
+          }}
+          options={STEP_TYPE_OPTIONS.map(option => ({
+            ...option,
+            disabled: option.value === "repeat" && depth >= MAX_REPEAT_DEPTH,
+          }))}
+        />
+
+        {stepType === "keys" && (
+          <>
+            <div className="w-full flex flex-col gap-2">
+              <FieldLabel label="Modifiers" />
+              <div className="inline-flex flex-wrap gap-3">
+                {Object.entries(groupedModifiers).map(([group, mods]) => (
+                  <div key={group} className="relative min-w-[120px] rounded-md border border-slate-200 dark:border-slate-700 p-2">
+                    <span className="absolute -top-2.5 left-2 px-1 text-xs font-medium bg-white dark:bg-slate-800 text-slate-500 dark:text-slate-400">
+                      {group}
+                    </span>
+                    <div className="flex flex-wrap gap-4 pt-1">
+                      {mods.map(option => (
+                        <Button
+                          key={option.value}
+                          size="XS"
+                          theme={ensureArray(step.modifiers).includes(option.value) ? "primary" : "light"}
+                          text={option.label.split(' ')[1] || option.label}
+                          onClick={() => {
+                            const modifiersArray = ensureArray(step.modifiers);
+                            const isSelected = modifiersArray.includes(option.value);
+                            const newModifiers = isSelected
+                              ? modifiersArray.filter(m => m !== option.value)
+                              : [...modifiersArray, option.value];
+                            onModifierChange(newModifiers);
+                          }}
+                        />
+                      ))}
+                    </div>
+                  </div>
+                ))}
+              </div>
+            </div>
+        
+            <div className="w-full flex flex-col gap-1">
+              <div className="flex items-center gap-1">
+                <FieldLabel label="Keys" description={`Maximum ${MAX_KEYS_PER_STEP} keys per step.`} />
+              </div>
+              {ensureArray(step.keys) && step.keys.length > 0 && (
+                <div className="flex flex-wrap gap-1 pb-2">
+                  {step.keys.map((key, keyIndex) => (
+                    <span
+                      key={keyIndex}
+                      className="inline-flex items-center py-0.5 rounded-md bg-blue-100 px-1 text-xs font-medium text-blue-800 dark:bg-blue-900/40 dark:text-blue-200"
+                    >
+                      <span className="px-1">
+                        {keyDisplayMap[key] || key}
+                      </span>
+                      <Button
+                        size="XS"
+                        className=""
+                        theme="blank"
+                        onClick={() => {
+                          const newKeys = ensureArray(step.keys).filter((_, i) => i !== keyIndex);
+                          onKeySelect({ value: null, keys: newKeys });
+                        }}
+                        LeadingIcon={LuX}
+                      />
+                    </span>
                   ))}
                 </div>
+              )}
+              <div className="relative w-full">
+                <Combobox
+                  onChange={(value: { value: string; label: string }) => {
+                    onKeySelect(value);
+                    onKeyQueryChange('');
+                  }}
+                  displayValue={() => keyQuery}
+                  onInputChange={onKeyQueryChange}
+                  options={getFilteredKeys}
+                  disabledMessage="Max keys reached"
+                  size="SM"
+                  immediate
+                  disabled={ensureArray(step.keys).length >= MAX_KEYS_PER_STEP}
+                  placeholder={ensureArray(step.keys).length >= MAX_KEYS_PER_STEP ? "Max keys reached" : "Search for key..."}
+                  emptyMessage="No matching keys found"
+                />
               </div>
-            ))}
-          </div>
-        </div>
-        
-        <div className="w-full flex flex-col gap-1">
-          <div className="flex items-center gap-1">
-            <FieldLabel label="Keys" description={`Maximum ${MAX_KEYS_PER_STEP} keys per step.`} />
-          </div>
-          {ensureArray(step.keys) && step.keys.length > 0 && (
-            <div className="flex flex-wrap gap-1 pb-2">
-              {step.keys.map((key, keyIndex) => (
-                <span
-                  key={keyIndex}
-                  className="inline-flex items-center py-0.5 rounded-md bg-blue-100 px-1 text-xs font-medium text-blue-800 dark:bg-blue-900/40 dark:text-blue-200"
-                >
-                  <span className="px-1">
-                    {keyDisplayMap[key] || key}
-                  </span>
-                  <Button
-                    size="XS"
-                    className=""
-                    theme="blank"
-                    onClick={() => {
-                      const newKeys = ensureArray(step.keys).filter((_, i) => i !== keyIndex);
-                      onKeySelect({ value: null, keys: newKeys });
-                    }}
-                    LeadingIcon={LuX}
-                  />
-                </span>
+            </div>
+          </>
+        )}
+
+        {stepType === "text" && (
+          <TextAreaWithLabel
+            label="Text"
+            description={`Typed with the keyboard layout of the device, up to ${MAX_TEXT_LENGTH} characters.`}
+            rows={3}
+            maxLength={MAX_TEXT_LENGTH}
+            value={step.text ?? ""}
+            onChange={e => onStepChange({ ...step, text: e.target.value })}
+          />
+        )}
+
+        {(stepType === "absMouse" || stepType === "relMouse") && (
+          <>
+            <div className="flex items-start gap-3">
+              {(["x", "y"] as const).map(axis => (
+                <InputFieldWithLabel
+                  key={axis}
+                  type="number"
+                  size="SM"
+                  label={axis.toUpperCase()}
+                  description={
+                    stepType === "absMouse"
+                      ? `From 0 to ${MAX_ABS_MOUSE_POSITION}, across the screen`
+                      : `From -${MAX_REL_MOUSE_MOVE} to ${MAX_REL_MOUSE_MOVE}`
+                  }
+                  min={stepType === "absMouse" ? 0 : -MAX_REL_MOUSE_MOVE}
+                  max={stepType === "absMouse" ? MAX_ABS_MOUSE_POSITION : MAX_REL_MOUSE_MOVE}
+                  value={step[axis] ?? 0}
+                  onChange={e => {
+                    if (Number.isNaN(e.target.valueAsNumber)) return;
+                    onStepChange({ ...step, [axis]: e.target.valueAsNumber });
+                  }}
+                />
               ))}
             </div>
-          )}
-          <div className="relative w-full">
-            <Combobox
-              onChange={(value: { value: string; label: string }) => {
-                onKeySelect(value);
-                onKeyQueryChange('');
-              }}
-              displayValue={() => keyQuery}
-              onInputChange={onKeyQueryChange}
-              options={getFilteredKeys}
-              disabledMessage="Max keys reached"
-              size="SM"
-              immediate
-              disabled={ensureArray(step.keys).length >= MAX_KEYS_PER_STEP}
-              placeholder={ensureArray(step.keys).length >= MAX_KEYS_PER_STEP ? "Max keys reached" : "Search for key..."}
-              emptyMessage="No matching keys found"
-            />
-          </div>
-        </div>
-        
-        <div className="w-full flex flex-col gap-1">
-          <div className="flex items-center gap-1">
-            <FieldLabel label="Step Duration" description="Time to wait before executing the next step." />
-          </div>
-          <div className="flex items-center gap-3">
             <SelectMenuBasic
               size="SM"
+              label="Click"
               fullWidth
-              value={step.delay.toString()}
-              onChange={(e) => onDelayChange(parseInt(e.target.value, 10))}
-              options={PRESET_DELAYS}
+              value={String(step.buttons ?? 0)}
+              onChange={e => onStepChange({ ...step, buttons: parseInt(e.target.value, 10) })}
+              options={MOUSE_BUTTON_OPTIONS}
             />
+          </>
+        )}
+
+        {stepType === "atx" && (
+          <SelectMenuBasic
+            size="SM"
+            label="Power Action"
+            fullWidth
+            value={step.action ?? "reset"}
+            onChange={e =>
+              onStepChange({ ...step, action: e.target.value as KeySequenceStep["action"] })
+            }
+            options={ATX_ACTION_OPTIONS}
+          />
+        )}
+
+        {stepType === "waitVideo" && (
+          <InputFieldWithLabel
+            type="number"
+            size="SM"
+            label="Timeout (ms)"
+            description="The macro fails when there's still no video signal after the timeout."
+            min={1}
+            max={MAX_WAIT_VIDEO_TIMEOUT}
+            value={step.timeout ?? 0}
+            onChange={e => {
+              if (Number.isNaN(e.target.valueAsNumber)) return;
+              onStepChange({ ...step, timeout: e.target.valueAsNumber });
+            }}
+          />
+        )}
+
+        {stepType === "repeat" && (
+          <>
+            <InputFieldWithLabel
+              type="number"
+              size="SM"
+              label="Count"
+              description={`How many times the steps run, up to ${MAX_REPEAT_COUNT}.`}
+              min={1}
+              max={MAX_REPEAT_COUNT}
+              value={step.count ?? 1}
+              onChange={e => {
+                if (Number.isNaN(e.target.valueAsNumber)) return;
+                onStepChange({ ...step, count: e.target.valueAsNumber });
+              }}
+            />
+            <RepeatedSteps
+              steps={step.steps ?? []}
+              depth={depth + 1}
+              onChange={steps => onStepChange({ ...step, steps })}
+            />
+          </>
+        )}
+
+        {/* the repeat steps don't wait, their steps do */}
+        {stepType !== "repeat" && (
+          <div className="w-full flex flex-col gap-1">
+            <div className="flex items-center gap-1">
+              <FieldLabel
+                label="Step Duration"
+                description={
+                  stepType === "keys"
+                    ? "Time to wait before executing the next step."
+                    : "Time to wait after the action, before executing the next step."
+                }
+              />
+            </div>
+            <div className="flex items-center gap-3">
+              <SelectMenuBasic
+                size="SM"
+                fullWidth
+                value={step.delay.toString()}
+                onChange={(e) => onDelayChange(parseInt(e.target.value, 10))}
+                options={PRESET_DELAYS}
+              />
+            </div>
           </div>
-        </div>
+        )}
+
+        {error && <FieldError error={error} />}
       </div>
     </Card>
   );
+}
+
+interface RepeatedStepsProps {
+  steps: KeySequenceStep[];
+  depth: number;
+  onChange: (steps: KeySequenceStep[]) => void;
+}
+
+// RepeatedSteps edits the steps of a repeat step, with the same cards as the macro
+function RepeatedSteps({ steps, depth, onChange }: RepeatedStepsProps) {
+  const [keyQueries, setKeyQueries] = useState<Record<number, string>>({});
+
+  const updateStep = (stepIndex: number, step: KeySequenceStep) => {
+    onChange(steps.map((s, i) => (i === stepIndex ? step : s)));
+  };
+
+  const moveStep = (stepIndex: number, direction: "up" | "down") => {
+    const newSteps = [...steps];
+    const newIndex = direction === "up" ? stepIndex - 1 : stepIndex + 1;
+    [newSteps[stepIndex], newSteps[newIndex]] = [newSteps[newIndex], newSteps[stepIndex]];
+    onChange(newSteps);
+  };
+
+  const isMaxStepsReached = steps.length >= MAX_STEPS_PER_MACRO;
+
+  return (
+    <div className="w-full flex flex-col gap-2">
+      <FieldLabel label="Repeated Steps" />
+      <div className="space-y-2 border-l-2 border-slate-200 pl-3 dark:border-slate-700">
+        {steps.map((step, stepIndex) => (
+          <MacroStepCard
+            key={stepIndex}
+            step={step}
+            stepIndex={stepIndex}
+            depth={depth}
+            onDelete={
+              steps.length > 1 ? () => onChange(steps.filter((_, i) => i !== stepIndex)) : undefined
+            }
+            onMoveUp={() => moveStep(stepIndex, "up")}
+            onMoveDown={() => moveStep(stepIndex, "down")}
+            onKeySelect={option => {
+              const stepKeys = ensureArray(step.keys);
+              if (option.keys) {
+                updateStep(stepIndex, { ...step, keys: option.keys });
+              } else if (option.value && stepKeys.length < MAX_KEYS_PER_STEP) {
+                updateStep(stepIndex, { ...step, keys: [...stepKeys, option.value] });
+              }
+            }}
+            onKeyQueryChange={query => setKeyQueries(prev => ({ ...prev, [stepIndex]: query }))}
+            keyQuery={keyQueries[stepIndex] || ""}
+            onModifierChange={modifiers => updateStep(stepIndex, { ...step, modifiers })}
+            onDelayChange={delay => updateStep(stepIndex, { ...step, delay })}
+            onStepChange={newStep => updateStep(stepIndex, newStep)}
+            isLastStep={stepIndex === steps.length - 1}
+          />
+        ))}
+        <Button
+          size="XS"
+          theme="light"
+          LeadingIcon={LuPlus}
+          text="Add Repeated Step"
+          disabled={isMaxStepsReached}
+          onClick={() => onChange([...steps, newMacroStep("keys")])}
+        />
+      </div>
+    </div>
+  );
 } 
\ No newline at end of file
@@ -0,0 +1,120 @@
+import { useCallback } from "react";
+import { EyeIcon } from "@heroicons/react/20/solid";
+
+import { SessionInfo, useSessionStore } from "@/hooks/stores";
+import { useJsonRpc } from "@/hooks/useJsonRpc";
+import notifications from "@/notifications";
+
+import { Button } from "./Button";
+import { GridCard } from "./Card";
+
+function sessionLabel(session: SessionInfo) {
+  return `${session.source || "Unknown"}${session.isCloud ? " (cloud)" : ""}`;
+}
+
+export default function SessionControlStatusCard() {
+  const [send] = useJsonRpc();
+  const sessionState = useSessionStore(state => state.sessionState);
+  const controlRequestPending = useSessionStore(state => state.controlRequestPending);
+  const setControlRequestPending = useSessionStore(state => state.setControlRequestPending);
+
+  const requestControl = useCallback(() => {
+    send("requestControl", {}, resp => {
+      if ("error" in resp) {
+        notifications.error(`Failed to request control: ${resp.error.data || resp.error.message}`);
+        return;
+      }
+      // true when nobody was holding the control, otherwise the controller has to grant it
+      setControlRequestPending(resp.result !== true);
+    });
+  }, [send, setControlRequestPending]);
+
+  const answerRequest = useCallback(
+    (method: "grantControl" | "denyControl", sessionId: string) => {
+      send(method, { sessionId }, resp => {
+        if ("error" in resp) {
+          notifications.error(`Failed to answer the request: ${resp.error.data || resp.error.message}`);
+        }
+      });
+    },
+    [send],
+  );
+
+  if (!sessionState) return null;
+
+  if (sessionState.isController) {
+    const requests = sessionState.sessions.filter(s => s.controlRequested);
+    if (requests.length === 0) return null;
+
+    return (
+      <div className="w-full space-y-2 select-none">
+        {requests.map(session => (
+          <GridCard key={session.id} cardClassName="shadow-xl!">
+            <div className="flex items-center justify-between gap-x-3 px-2.5 py-2.5 text-black dark:text-white">
+              <div className="space-y-1">
+                <div className="text-sm leading-none font-semibold">Control Requested</div>
+                <div className="text-sm leading-none">
+                  {sessionLabel(session)} wants to control the keyboard and mouse
+                </div>
+              </div>
+              <div className="flex shrink-0 items-center gap-x-2">
+                <Button
+                  size="SM"
+                  className="pointer-events-auto"
+                  theme="light"
+                  text="Deny"
+                  onClick={() => answerRequest("denyControl", session.id)}
+                />
+                <Button
+                  size="SM"
+                  className="pointer-events-auto"
+                  theme="primary"
+                  text="Grant"
+                  onClick={() => answerRequest("grantControl", session.id)}
+                />
+              </div>
+            </div>
+          </GridCard>
+        ))}
+      </div>
+    );
+  }
+
+  const controller = sessionState.sessions.find(s => s.isController);
+  const canControl = sessionState.role !== "viewer";
+
+  let description = "Nobody is controlling the device";
+  if (!canControl) {
+    description = "Your account can only view the device";
+  } else if (controlRequestPending) {
+    description = "Waiting for the controlling session to answer...";
+  } else if (controller) {
+    description = `${sessionLabel(controller)} is controlling the device`;
+  }
+
+  return (
+    <div className="w-full select-none">
+      <GridCard cardClassName="shadow-xl!">
+        <div className="flex items-center justify-between gap-x-3 px-2.5 py-2.5 text-black dark:text-white">
+          <div className="flex items-center gap-x-3">
+            <EyeIcon className="h-5 w-5 shrink-0 text-blue-700 dark:text-blue-500" />
+            <div className="space-y-1">
+              <div className="text-sm leading-none font-semibold">View Only</div>
+              <div className="text-sm leading-none">{description}</div>
+            </div>
+          </div>
+          {canControl && (
+            <Button
+              size="SM"
+              className="pointer-events-auto"
+              theme="primary"
+              text={controlRequestPending ? "Requested" : "Request Control"}
+              disabled={controlRequestPending}
+              onClick={requestControl}
+            />
+          )}
+        </div>
+      </GridCard>
+    </div>
+  );
+}
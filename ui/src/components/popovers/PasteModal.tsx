@@ -7,28 +7,33 @@ import { Button } from "@components/Button";
 import { GridCard } from "@components/Card";
 import { TextAreaWithLabel } from "@components/TextArea";
 import { SettingsPageHeader } from "@components/SettingsPageheader";
-import { useJsonRpc } from "@/hooks/useJsonRpc";
+import { JsonRpcRequest, useJsonRpc } from "@/hooks/useJsonRpc";
 import { useHidStore, useRTCStore, useUiStore, useSettingsStore } from "@/hooks/stores";
-import { keys, modifiers } from "@/keyboardMappings";
 import { layouts, chars } from "@/keyboardLayouts";
 import notifications from "@/notifications";
 
-const hidKeyboardPayload = (keys: number[], modifier: number) => {
-  return { keys, modifier };
-};
-
-const modifierCode = (shift?: boolean, altRight?: boolean) => {
-  return (shift ? modifiers["ShiftLeft"] : 0)
-       | (altRight ? modifiers["AltRight"] : 0)
+// TypeTextState mirrors the state of paste.go, sent in the typeTextState events
+interface TypeTextState {
+  id: string;
+  state: "running" | "done" | "cancelled" | "failed";
+  layout: string;
+  typed: number;
+  total: number;
+  error?: string;
 }
-const noModifier = 0
 
 export default function PasteModal() {
   const TextAreaRef = useRef<HTMLTextAreaElement>(null);
   const setPasteMode = useHidStore(state => state.setPasteModeEnabled);
   const setDisableVideoFocusTrap = useUiStore(state => state.setDisableVideoFocusTrap);
 
-  const [send] = useJsonRpc();
+  const [typeTextState, setTypeTextState] = useState<TypeTextState | null>(null);
+  const onJsonRpcRequest = useCallback((request: JsonRpcRequest) => {
+    if (request.method !== "typeTextState") return;
+    setTypeTextState(request.params as TypeTextState);
+  }, []);
+
+  const [send] = useJsonRpc(onJsonRpcRequest);
   const rpcDataChannel = useRTCStore(state => state.rpcDataChannel);
 
   const [invalidChars, setInvalidChars] = useState<string[]>([]);
@@ -53,13 +58,31 @@ export default function PasteModal() {
     });
   }, [send, setKeyboardLayout]);
 
+  // a paste started before the modal was opened is still followed
+  useEffect(() => {
+    send("getTypeTextState", {}, resp => {
+      if ("error" in resp) return;
+      setTypeTextState(resp.result as TypeTextState | null);
+    });
+  }, [send]);
+
+  const isTyping = typeTextState?.state === "running";
+
+  const onCancelTypeText = useCallback(() => {
+    send("cancelTypeText", {}, resp => {
+      if ("error" in resp) {
+        notifications.error(`Failed to cancel the paste: ${resp.error.data || resp.error.message}`);
+      }
+    });
+  }, [send]);
+
   const onCancelPasteMode = useCallback(() => {
     setPasteMode(false);
     setDisableVideoFocusTrap(false);
     setInvalidChars([]);
   }, [setDisableVideoFocusTrap, setPasteMode]);
 
-  const onConfirmPaste = useCallback(async () => {
+  const onConfirmPaste = useCallback(() => {
     setPasteMode(false);
     setDisableVideoFocusTrap(false);
     if (rpcDataChannel?.readyState !== "open" || !TextAreaRef.current) return;
@@ -67,43 +90,15 @@ export default function PasteModal() {
     if (!chars[safeKeyboardLayout]) return;
     const text = TextAreaRef.current.value;
 
-    try {
-      for (const char of text) {
-        const { key, shift, altRight, deadKey, accentKey } = chars[safeKeyboardLayout][char]
-        if (!key) continue;
-
-        const keyz = [ keys[key] ];
-        const modz = [ modifierCode(shift, altRight) ];
-
-        if (deadKey) {
-            keyz.push(keys["Space"]);
-            modz.push(noModifier);
-        }
-        if (accentKey) {
-            keyz.unshift(keys[accentKey.key])
-            modz.unshift(modifierCode(accentKey.shift, accentKey.altRight))
-        }
-
-        for (const [index, kei] of keyz.entries()) {
-          await new Promise<void>((resolve, reject) => {
-            send(
-              "keyboardReport",
-              hidKeyboardPayload([kei], modz[index]),
-              params => {
-                if ("error" in params) return reject(params.error);
-                send("keyboardReport", hidKeyboardPayload([], 0), params => {
-                  if ("error" in params) return reject(params.error);
-                  resolve();
-                });
-              },
-            );
-          });
-        }
+    // the device types the text itself, the progress comes in typeTextState events
+    send("typeText", { params: { text, layout: safeKeyboardLayout } }, resp => {
+      if ("error" in resp) {
+        console.error(resp.error);
+        notifications.error(`Failed to paste text: ${resp.error.data || resp.error.message}`);
+        return;
       }
-    } catch (error) {
-      console.error(error);
-      notifications.error("Failed to paste text");
-    }
+      setTypeTextState(resp.result as TypeTextState);
+    });
   }, [rpcDataChannel?.readyState, send, setDisableVideoFocusTrap, setPasteMode, safeKeyboardLayout]);
 
   useEffect(() => {
@@ -178,6 +173,30 @@ export default function PasteModal() {
                     Sending text using keyboard layout: {layouts[safeKeyboardLayout]}
                   </p>
                 </div>
+                {typeTextState && (
+                  <div className="space-y-1">
+                    <div className="flex items-center justify-between text-xs text-slate-600 dark:text-slate-400">
+                      <span>
+                        {isTyping && "Typing..."}
+                        {typeTextState.state === "done" && "Text pasted"}
+                        {typeTextState.state === "cancelled" && "Paste cancelled"}
+                        {typeTextState.state === "failed" &&
+                          `Paste failed: ${typeTextState.error || "unknown error"}`}
+                      </span>
+                      <span>
+                        {typeTextState.typed} / {typeTextState.total}
+                      </span>
+                    </div>
+                    <div className="h-1.5 w-full overflow-hidden rounded-full bg-slate-200 dark:bg-slate-700">
+                      <div
+                        className="h-full bg-blue-700 transition-all duration-300 dark:bg-blue-500"
+                        style={{
+                          width: `${typeTextState.total > 0 ? (typeTextState.typed / typeTextState.total) * 100 : 100}%`,
+                        }}
+                      />
+                    </div>
+                  </div>
+                )}
               </div>
             </div>
           </div>
@@ -198,13 +217,17 @@ export default function PasteModal() {
               close();
             }}
           />
-          <Button
-            size="SM"
-            theme="primary"
-            text="Confirm Paste"
-            onClick={onConfirmPaste}
-            LeadingIcon={LuCornerDownLeft}
-          />
+          {isTyping ? (
+            <Button size="SM" theme="danger" text="Stop Typing" onClick={onCancelTypeText} />
+          ) : (
+            <Button
+              size="SM"
+              theme="primary"
+              text="Confirm Paste"
+              onClick={onConfirmPaste}
+              LeadingIcon={LuCornerDownLeft}
+            />
+          )}
         </div>
       </div>
     </GridCard>
@@ -1,5 +1,145 @@
+import type { KeySequenceStep, KeySequenceStepType } from "@/hooks/stores";
+
 export const DEFAULT_DELAY = 50;
-export const MAX_STEPS_PER_MACRO = 10;
+export const MAX_STEPS_PER_MACRO = 200;
 export const MAX_KEYS_PER_STEP = 10;
-export const MAX_TOTAL_MACROS = 25; 
-export const COPY_SUFFIX = "(copy)";
\ No newline at end of file
+export const MAX_TOTAL_MACROS = 100; 
+export const COPY_SUFFIX = "(copy)";
+
+// the limits of the other step types, as checked by the device in internal/macros/macros.go
+export const MAX_TEXT_LENGTH = 4096;
+export const MAX_REPEAT_COUNT = 100;
+export const MAX_REPEAT_DEPTH = 3;
+export const MAX_WAIT_VIDEO_TIMEOUT = 300000;
+export const DEFAULT_WAIT_VIDEO_TIMEOUT = 30000;
+export const MAX_ABS_MOUSE_POSITION = 32767;
+export const MAX_REL_MOUSE_MOVE = 127;
+
+export const STEP_TYPE_OPTIONS: { value: KeySequenceStepType; label: string }[] = [
+  { value: "keys", label: "Press keys" },
+  { value: "text", label: "Type text" },
+  { value: "absMouse", label: "Move mouse to" },
+  { value: "relMouse", label: "Move mouse by" },
+  { value: "atx", label: "ATX power action" },
+  { value: "waitVideo", label: "Wait for video" },
+  { value: "repeat", label: "Repeat steps" },
+];
+
+export const ATX_ACTION_OPTIONS = [
+  { value: "power-short", label: "Power button (short press)" },
+  { value: "power-long", label: "Power button (long press)" },
+  { value: "reset", label: "Reset button" },
+];
+
+export const MOUSE_BUTTON_OPTIONS = [
+  { value: "0", label: "No click" },
+  { value: "1", label: "Left click" },
+  { value: "2", label: "Right click" },
+  { value: "4", label: "Middle click" },
+];
+
+// newMacroStep returns a step of the type with its default values, the key steps are
+// saved without a type like the macros made before the other types existed
+export const newMacroStep = (type: KeySequenceStepType): KeySequenceStep => {
+  const step: KeySequenceStep = { keys: [], modifiers: [], delay: DEFAULT_DELAY };
+  switch (type) {
+    case "keys":
+      return step;
+    case "text":
+      return { ...step, type, text: "" };
+    case "absMouse":
+      return { ...step, type, x: 16384, y: 16384, buttons: 0 };
+    case "relMouse":
+      return { ...step, type, x: 0, y: 0, buttons: 0 };
+    case "atx":
+      return { ...step, type, action: "reset" };
+    case "waitVideo":
+      return { ...step, type, timeout: DEFAULT_WAIT_VIDEO_TIMEOUT };
+    case "repeat":
+      return { ...step, type, count: 2, steps: [newMacroStep("keys")] };
+  }
+};
+
+// isMacroStepEmpty is true for a key step without keys or modifiers, it only waits
+export const isMacroStepEmpty = (step: KeySequenceStep) =>
+  (step.type ?? "keys") === "keys" &&
+  (step.keys?.length || 0) === 0 &&
+  (step.modifiers?.length || 0) === 0;
+
+// validateMacroStep returns why the device would refuse the step, if it would
+export const validateMacroStep = (step: KeySequenceStep, depth = 0): string | undefined => {
+  switch (step.type ?? "keys") {
+    case "keys":
+      if ((step.keys?.length || 0) > MAX_KEYS_PER_STEP) {
+        return `Maximum of ${MAX_KEYS_PER_STEP} keys per step allowed`;
+      }
+      return;
+    case "text":
+      if (!step.text) return "The text is required";
+      if ([...step.text].length > MAX_TEXT_LENGTH) {
+        return `The text must be at most ${MAX_TEXT_LENGTH} characters`;
+      }
+      return;
+    case "absMouse": {
+      const inRange = (v?: number) => v !== undefined && v >= 0 && v <= MAX_ABS_MOUSE_POSITION;
+      if (!inRange(step.x) || !inRange(step.y)) {
+        return `The position must be between 0 and ${MAX_ABS_MOUSE_POSITION}`;
+      }
+      return;
+    }
+    case "relMouse": {
+      const inRange = (v?: number) =>
+        v !== undefined && v >= -MAX_REL_MOUSE_MOVE && v <= MAX_REL_MOUSE_MOVE;
+      if (!inRange(step.x) || !inRange(step.y)) {
+        return `The move must be between -${MAX_REL_MOUSE_MOVE} and ${MAX_REL_MOUSE_MOVE}`;
+      }
+      return;
+    }
+    case "atx":
+      if (!step.action) return "The action is required";
+      return;
+    case "waitVideo":
+      if (!step.timeout || step.timeout <= 0 || step.timeout > MAX_WAIT_VIDEO_TIMEOUT) {
+        return `The timeout must be between 1 and ${MAX_WAIT_VIDEO_TIMEOUT} ms`;
+      }
+      return;
+    case "repeat":
+      if (depth >= MAX_REPEAT_DEPTH) {
+        return `Repeats can only be nested ${MAX_REPEAT_DEPTH} levels deep`;
+      }
+      if (!step.count || step.count < 1 || step.count > MAX_REPEAT_COUNT) {
+        return `The count must be between 1 and ${MAX_REPEAT_COUNT}`;
+      }
+      if (!step.steps?.length) return "At least one step is required";
+      for (const [i, nested] of step.steps.entries()) {
+        const error = validateMacroStep(nested, depth + 1);
+        if (error) return `Step ${i + 1}: ${error}`;
+      }
+      return;
+  }
+};
+
+// describeMacroStep is a one line summary of the steps that don't press keys
+export const describeMacroStep = (step: KeySequenceStep): string => {
+  const button = MOUSE_BUTTON_OPTIONS.find(o => o.value === String(step.buttons ?? 0));
+  const click = step.buttons ? `, ${button?.label.toLowerCase() ?? "click"}` : "";
+
+  switch (step.type ?? "keys") {
+    case "text": {
+      const text = step.text ?? "";
+      return `Type "${text.length > 20 ? `${text.slice(0, 20)}...` : text}"`;
+    }
+    case "absMouse":
+      return `Move mouse to ${step.x ?? 0}, ${step.y ?? 0}${click}`;
+    case "relMouse":
+      return `Move mouse by ${step.x ?? 0}, ${step.y ?? 0}${click}`;
+    case "atx":
+      return ATX_ACTION_OPTIONS.find(o => o.value === step.action)?.label ?? "ATX action";
+    case "waitVideo":
+      return `Wait for video (${step.timeout ?? 0}ms max)`;
+    case "repeat":
+      return `Repeat ${step.steps?.length ?? 0} steps ${step.count ?? 0} times`;
+    default:
+      return "Press keys";
+  }
+};
@@ -676,6 +676,48 @@ export const useDeviceStore = create<DeviceState>(set => ({
   setSystemVersion: version => set({ systemVersion: version }),
 }));
 
+export type SessionRole = "admin" | "operator" | "viewer";
+
+export interface SessionInfo {
+  id: string;
+  source: string;
+  isCloud: boolean;
+  transport: "webrtc" | "websocket";
+  role: SessionRole;
+  isController: boolean;
+  controlRequested: boolean;
+  connectedAt: string;
+}
+
+// The sessionState event of the device, only one session controls the keyboard and mouse
+export interface SessionState {
+  sessionId: string;
+  role: SessionRole;
+  isController: boolean;
+  sessions: SessionInfo[];
+}
+
+interface SessionStoreState {
+  sessionState: SessionState | null;
+  setSessionState: (state: SessionState) => void;
+
+  // Set once this session asked for the control, until it is granted or denied
+  controlRequestPending: boolean;
+  setControlRequestPending: (pending: boolean) => void;
+}
+
+export const useSessionStore = create<SessionStoreState>(set => ({
+  sessionState: null,
+  setSessionState: state =>
+    set(prev => ({
+      sessionState: state,
+      controlRequestPending: prev.controlRequestPending && !state.isController,
+    })),
+
+  controlRequestPending: false,
+  setControlRequestPending: pending => set({ controlRequestPending: pending }),
+}));
+
 export interface DhcpLease {
   ip?: string;
   netmask?: string;
@@ -776,10 +818,22 @@ export const useNetworkStateStore = create<NetworkState>((set, get) => ({
   },
 }));
 
+export type KeySequenceStepType = "keys" | "text" | "absMouse" | "relMouse" | "atx" | "waitVideo" | "repeat";
+
 export interface KeySequenceStep {
+  // the steps without a type press keys
+  type?: KeySequenceStepType;
   keys: string[];
   modifiers: string[];
   delay: number;
+  text?: string;
+  x?: number;
+  y?: number;
+  buttons?: number;
+  action?: "power-short" | "power-long" | "reset";
+  timeout?: number;
+  count?: number;
+  steps?: KeySequenceStep[];
 }
 
 export interface KeySequence {
@@ -6,7 +6,7 @@ const keyTrema = { key: "Quote", shift: true } // tréma (umlaut), two dots plac
 const keyAcute = { key: "Quote" } // accent aigu (acute accent), mark ´ placed above the letter
 const keyHat = { key: "BracketRight", shift: true } // accent circonflexe (accent hat), mark ^ placed above the letter
 const keyGrave = { key: "BracketRight" } // accent grave, mark ` placed above the letter
-const keyTilde = { key: "Key4", altRight: true } // tilde, mark ~ placed above the letter
+const keyTilde = { key: "Digit4", altRight: true } // tilde, mark ~ placed above the letter
 
 export const chars = {
   A: { key: "KeyA", shift: true },
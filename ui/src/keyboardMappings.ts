@@ -12,7 +12,7 @@ export const keys = {
   BracketRight: 0x30,
   CapsLock: 0x39,
   Comma: 0x36,
-  ContextMenu: 0,
+  ContextMenu: 0x65,
   Delete: 0x4c,
   Digit0: 0x27,
   Digit1: 0x1e,
@@ -16,7 +16,12 @@ import { SettingsPageHeader } from "@/components/SettingsPageheader";
 import { Button } from "@/components/Button";
 import EmptyCard from "@/components/EmptyCard";
 import Card from "@/components/Card";
-import { MAX_TOTAL_MACROS, COPY_SUFFIX, DEFAULT_DELAY } from "@/constants/macros";
+import {
+  MAX_TOTAL_MACROS,
+  COPY_SUFFIX,
+  DEFAULT_DELAY,
+  describeMacroStep,
+} from "@/constants/macros";
 import { keyDisplayMap, modifierDisplayMap } from "@/keyboardMappings";
 import notifications from "@/notifications";
 import { ConfirmDialog } from "@/components/ConfirmDialog";
@@ -177,7 +182,11 @@ export default function SettingsMacrosRoute() {
                         <span key={stepIndex} className="inline-flex items-center">
                           <StepIcon className="mr-1 h-3 w-3 shrink-0 text-slate-400 dark:text-slate-500" />
                           <span className="rounded-md border border-slate-200/50 bg-slate-50 px-2 py-0.5 dark:border-slate-700/50 dark:bg-slate-800">
-                            {(Array.isArray(step.modifiers) &&
+                            {step.type && step.type !== "keys" ? (
+                              <span className="font-medium text-slate-600 dark:text-slate-200">
+                                {describeMacroStep(step)}
+                              </span>
+                            ) : (Array.isArray(step.modifiers) &&
                               step.modifiers.length > 0) ||
                             (Array.isArray(step.keys) && step.keys.length > 0) ? (
                               <>
@@ -21,6 +21,8 @@ import {
   HidState,
   KeyboardLedState,
   NetworkState,
+  SessionInfo,
+  SessionState,
   UpdateState,
   useDeviceStore,
   useHidStore,
@@ -28,6 +30,7 @@ import {
   useNetworkStateStore,
   User,
   useRTCStore,
+  useSessionStore,
   useUiStore,
   useUpdateStore,
   useVideoStore,
@@ -42,6 +45,7 @@ import Terminal from "@components/Terminal";
 import { CLOUD_API, DEVICE_API } from "@/ui.config";
 
 import UpdateInProgressStatusCard from "../components/UpdateInProgressStatusCard";
+import SessionControlStatusCard from "../components/SessionControlStatusCard";
 import api from "../api";
 import Modal from "../components/Modal";
 import { useDeviceUiNavigation } from "../hooks/useAppNavigation";
@@ -592,12 +596,25 @@ export default function KvmIdRoute() {
 
   const setKeyboardLedStateSyncAvailable = useHidStore(state => state.setKeyboardLedStateSyncAvailable);
 
+  const setSessionState = useSessionStore(state => state.setSessionState);
+  const setControlRequestPending = useSessionStore(state => state.setControlRequestPending);
+
   const [hasUpdated, setHasUpdated] = useState(false);
   const { navigateTo } = useDeviceUiNavigation();
 
   function onJsonRpcRequest(resp: JsonRpcRequest) {
-    if (resp.method === "otherSessionConnected") {
-      navigateTo("/other-session");
+    if (resp.method === "sessionState") {
+      setSessionState(resp.params as SessionState);
+    }
+
+    if (resp.method === "controlRequested") {
+      const requester = resp.params as SessionInfo;
+      notifications.success(`${requester.source || "Another session"} requested control`);
+    }
+
+    if (resp.method === "controlDenied") {
+      setControlRequestPending(false);
+      notifications.error("Your request for control was denied");
     }
 
     if (resp.method === "usbState") {
@@ -648,6 +665,14 @@ export default function KvmIdRoute() {
   const rpcDataChannel = useRTCStore(state => state.rpcDataChannel);
   const [send] = useJsonRpc(onJsonRpcRequest);
 
+  useEffect(() => {
+    if (rpcDataChannel?.readyState !== "open") return;
+    send("getSessionState", {}, resp => {
+      if ("error" in resp) return;
+      setSessionState(resp.result as SessionState);
+    });
+  }, [rpcDataChannel?.readyState, send, setSessionState]);
+
   useEffect(() => {
     if (rpcDataChannel?.readyState !== "open") return;
     send("getVideoState", {}, resp => {
@@ -803,6 +828,11 @@ export default function KvmIdRoute() {
           </motion.div>
         </AnimatePresence>
       )}
+      {!outlet && !otaState.updating && peerConnectionState === "connected" && (
+        <div className="pointer-events-none fixed inset-0 top-16 z-10 mx-auto flex h-full w-full max-w-xl translate-y-8 items-start justify-center">
+          <SessionControlStatusCard />
+        </div>
+      )}
       <div className="relative h-full">
         <FocusTrap
           paused={disableKeyboardFocusTrap}
@@ -1,4 +1,10 @@
-import { ActionFunctionArgs, Form, redirect, useActionData } from "react-router-dom";
+import {
+  ActionFunctionArgs,
+  Form,
+  redirect,
+  useActionData,
+  useLoaderData,
+} from "react-router-dom";
 import { useState } from "react";
 import { LuEye, LuEyeOff } from "react-icons/lu";
 
@@ -26,23 +32,41 @@ const loader = async () => {
 
   const deviceRes = await api.GET(`${DEVICE_API}/device`);
   if (deviceRes.ok) return redirect("/");
-  return null;
+  return res;
 };
 
-const action = async ({ request }: ActionFunctionArgs) => {
+interface LoginActionData {
+  error?: string;
+  // Set when the password is right and the two-factor code is missing or invalid
+  totpRequired?: boolean;
+}
+
+const action = async ({ request }: ActionFunctionArgs): Promise<LoginActionData | Response> => {
   const formData = await request.formData();
+  const username = formData.get("username") || undefined;
   const password = formData.get("password");
+  const code = formData.get("code") || undefined;
 
   try {
     const response = await api.POST(`${DEVICE_API}/auth/login-local`, {
+      username,
       password,
+      code,
     });
 
     if (response.ok) {
       return redirect("/");
-    } else {
-      return { error: "Invalid password" };
     }
+
+    const body = (await response.json().catch(() => ({}))) as {
+      error?: string;
+      totpRequired?: boolean;
+    };
+    if (body.totpRequired) {
+      // the first answer only asks for the code
+      return { totpRequired: true, error: code ? body.error : undefined };
+    }
+    return { error: body.error || "Invalid password" };
   } catch (error) {
     console.error(error);
     return { error: "An error occurred while logging in" };
@@ -50,7 +74,11 @@ const action = async ({ request }: ActionFunctionArgs) => {
 };
 
 export default function LoginLocalRoute() {
-  const actionData = useActionData() as { error?: string; success?: boolean };
+  const deviceStatus = useLoaderData() as DeviceStatus;
+  const actionData = useActionData() as LoginActionData | undefined;
+  // devices running an older version don't send the login methods
+  const passwordLogin = deviceStatus.passwordLogin ?? true;
+  const oidcLogin = deviceStatus.oidcLogin ?? false;
   const [showPassword, setShowPassword] = useState(false);
 
   return (
@@ -75,58 +103,104 @@ export default function LoginLocalRoute() {
                   Welcome back to JetKVM
                 </h1>
                 <p className="font-medium text-slate-600 dark:text-slate-400">
-                  Enter your password to access your JetKVM.
+                  {passwordLogin
+                    ? "Enter your password to access your JetKVM."
+                    : "Sign in with your organization account to access your JetKVM."}
                 </p>
               </div>
 
               <Fieldset className="space-y-12">
-                <Form method="POST" className="mx-auto max-w-sm space-y-4">
-                  <div className="space-y-4">
-                    <InputFieldWithLabel
-                      label="Password"
-                      type={showPassword ? "text" : "password"}
-                      name="password"
-                      placeholder="Enter your password"
-                      autoFocus
-                      error={actionData?.error}
-                      TrailingElm={
-                        showPassword ? (
-                          <div
-                            onClick={() => setShowPassword(false)}
-                            className="pointer-events-auto"
-                          >
-                            <LuEye className="h-4 w-4 cursor-pointer text-slate-500 dark:text-slate-400" />
-                          </div>
-                        ) : (
-                          <div
-                            onClick={() => setShowPassword(true)}
-                            className="pointer-events-auto"
-                          >
-                            <LuEyeOff className="h-4 w-4 cursor-pointer text-slate-500 dark:text-slate-400" />
-                          </div>
-                        )
-                      }
+                {oidcLogin && (
+                  <div className="mx-auto max-w-sm space-y-4">
+                    <Button
+                      size="LG"
+                      theme={passwordLogin ? "light" : "primary"}
+                      fullWidth
+                      type="button"
+                      text="Sign in with SSO"
+                      textAlign="center"
+                      onClick={() => {
+                        // a full page load, the identity provider redirects back to the device
+                        window.location.href = `${DEVICE_API}/auth/oidc/login`;
+                      }}
                     />
+                    {passwordLogin && (
+                      <div className="text-center text-sm text-slate-500 dark:text-slate-400">
+                        or
+                      </div>
+                    )}
                   </div>
+                )}
+                {passwordLogin && (
+                  <Form method="POST" className="mx-auto max-w-sm space-y-4">
+                    <div className="space-y-4">
+                      <InputFieldWithLabel
+                        label="Username"
+                        type="text"
+                        name="username"
+                        placeholder="Enter your username"
+                        description="Leave empty to use the device password"
+                        autoComplete="username"
+                      />
+                      <InputFieldWithLabel
+                        label="Password"
+                        type={showPassword ? "text" : "password"}
+                        name="password"
+                        placeholder="Enter your password"
+                        autoComplete="current-password"
+                        autoFocus={!actionData?.totpRequired}
+                        error={actionData?.totpRequired ? undefined : actionData?.error}
+                        TrailingElm={
+                          showPassword ? (
+                            <div
+                              onClick={() => setShowPassword(false)}
+                              className="pointer-events-auto"
+                            >
+                              <LuEye className="h-4 w-4 cursor-pointer text-slate-500 dark:text-slate-400" />
+                            </div>
+                          ) : (
+                            <div
+                              onClick={() => setShowPassword(true)}
+                              className="pointer-events-auto"
+                            >
+                              <LuEyeOff className="h-4 w-4 cursor-pointer text-slate-500 dark:text-slate-400" />
+                            </div>
+                          )
+                        }
+                      />
+                      {actionData?.totpRequired && (
+                        <InputFieldWithLabel
+                          label="Two-Factor Code"
+                          type="text"
+                          name="code"
+                          placeholder="Enter the code of your authenticator app"
+                          description="A recovery code can be used instead"
+                          autoComplete="one-time-code"
+                          autoFocus
+                          error={actionData.error}
+                        />
+                      )}
+                    </div>
+
+                    <Button
+                      size="LG"
+                      theme="primary"
+                      fullWidth
+                      type="submit"
+                      text="Log In"
+                      textAlign="center"
+                    />
 
-                  <Button
-                    size="LG"
-                    theme="primary"
-                    fullWidth
-                    type="submit"
-                    text="Log In"
-                    textAlign="center"
-                  />
-
-                  <div className="mt-4 flex justify-start text-xs text-slate-500 dark:text-slate-400">
-                    <ExtLink
-                      href="https://jetkvm.com/docs/networking/local-access#reset-password"
-                      className="hover:underline"
-                    >
-                      Forgot password?
-                    </ExtLink>
-                  </div>
-                </Form>
+                    <div className="mt-4 flex justify-start text-xs text-slate-500 dark:text-slate-400">
+                      <ExtLink
+                        href="https://jetkvm.com/docs/networking/local-access#reset-password"
+                        className="hover:underline"
+                      >
+                        Forgot password?
+                      </ExtLink>
+                    </div>
+                  </Form>
+                )}
               </Fieldset>
             </div>
           </div>
@@ -15,6 +15,9 @@ import api from "../api";
 
 export interface DeviceStatus {
   isSetup: boolean;
+  // The login methods enabled on the device
+  oidcLogin?: boolean;
+  passwordLogin?: boolean;
 }
 
 const loader = async () => {
@@ -26,9 +26,7 @@ func initUsbGadget() {
 	}()
 
 	gadget.SetOnKeyboardStateChange(func(state usbgadget.KeyboardState) {
-		if currentSession != nil {
-			writeJSONRPCEvent("keyboardLedState", state, currentSession)
-		}
+		broadcastJSONRPCEvent("keyboardLedState", state)
 	})
 
 	// open the keyboard hid file to listen for keyboard events
@@ -65,11 +63,7 @@ func rpcGetUSBState() (state string) {
 
 func triggerUSBStateUpdate() {
 	go func() {
-		if currentSession == nil {
-			usbLogger.Info().Msg("No active RPC session, skipping update state update")
-			return
-		}
-		writeJSONRPCEvent("usbState", usbState, currentSession)
+		broadcastJSONRPCEvent("usbState", usbState)
 	}()
 }
 
@@ -78,9 +72,12 @@ func checkUSBState() {
 	if newState == usbState {
 		return
 	}
+	oldState := usbState
 	usbState = newState
 
-	usbLogger.Info().Str("from", usbState).Str("to", newState).Msg("USB state changed")
+	usbLogger.Info().Str("from", oldState).Str("to", newState).Msg("USB state changed")
+	publishMQTTState("usb", newState)
+	recordEvent(EventUSBStateChanged, "", "USB "+newState, map[string]any{"from": oldState, "to": newState})
 	requestDisplayUpdate(true)
 	triggerUSBStateUpdate()
 }
@@ -17,8 +17,8 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"github.com/pion/webrtc/v4"
-	"github.com/psanford/httpreadat"
 
+	"github.com/jetkvm/kvm/internal/httpimage"
 	"github.com/jetkvm/kvm/resource"
 )
 
@@ -160,7 +160,18 @@ type VirtualMediaUrlInfo struct {
 }
 
 func rpcCheckMountUrl(url string) (*VirtualMediaUrlInfo, error) {
-	return nil, errors.New("not implemented")
+	size, err := probeHTTPImage(url)
+	if err != nil {
+		var unusableErr *httpimage.UnusableError
+		if !errors.As(err, &unusableErr) {
+			return nil, err
+		}
+
+		logger.Info().Str("url", url).Str("reason", unusableErr.Reason).Msg("mount url is not usable")
+		return &VirtualMediaUrlInfo{Usable: false, Reason: unusableErr.Reason}, nil
+	}
+
+	return &VirtualMediaUrlInfo{Usable: true, Size: size}, nil
 }
 
 type VirtualMediaSource string
@@ -198,6 +209,23 @@ func rpcGetVirtualMediaState() (*VirtualMediaState, error) {
 func rpcUnmountImage() error {
 	virtualMediaStateMutex.Lock()
 	defer virtualMediaStateMutex.Unlock()
+
+	return unmountImageLocked()
+}
+
+// unmountImageOfSession unmounts the image when it is served by the closed session,
+// the media mounted by the other sessions or from the device stays.
+func unmountImageOfSession(session *Session) error {
+	virtualMediaStateMutex.Lock()
+	defer virtualMediaStateMutex.Unlock()
+
+	if virtualMediaSession != session || !session.shouldUmountVirtualMedia {
+		return nil
+	}
+	return unmountImageLocked()
+}
+
+func unmountImageLocked() error {
 	err := setMassStorageImage("\n")
 	if err != nil {
 		logger.Warn().Err(err).Msg("Remove Mass Storage Image Error")
@@ -208,11 +236,22 @@ func rpcUnmountImage() error {
 		nbdDevice.Close()
 		nbdDevice = nil
 	}
+	if httpImage != nil {
+		if err := httpImage.Close(); err != nil {
+			logger.Warn().Err(err).Msg("failed to close http image cache")
+		}
+		httpImage = nil
+	}
+	if virtualMediaSession != nil {
+		virtualMediaSession.shouldUmountVirtualMedia = false
+		virtualMediaSession = nil
+	}
 	currentVirtualMediaState = nil
 	return nil
 }
 
-var httpRangeReader *httpreadat.RangeReader
+// httpImage is the backend of the image mounted over HTTP.
+var httpImage *httpimage.Backend
 
 func getInitialVirtualMediaState() (*VirtualMediaState, error) {
 	cdromEnabled, err := getMassStorageCDROMEnabled()
@@ -275,8 +314,7 @@ func rpcMountWithHTTP(url string, mode VirtualMediaMode) error {
 		virtualMediaStateMutex.Unlock()
 		return fmt.Errorf("another virtual media is already mounted")
 	}
-	httpRangeReader = httpreadat.New(url)
-	n, err := httpRangeReader.Size()
+	n, err := probeHTTPImage(url)
 	if err != nil {
 		virtualMediaStateMutex.Unlock()
 		return fmt.Errorf("failed to use http url: %w", err)
@@ -284,9 +322,12 @@ func rpcMountWithHTTP(url string, mode VirtualMediaMode) error {
 	logger.Info().Str("url", url).Int64("size", n).Msg("using remote url")
 
 	if err := setMassStorageMode(mode == CDROM); err != nil {
+		virtualMediaStateMutex.Unlock()
 		return fmt.Errorf("failed to set mass storage mode: %w", err)
 	}
 
+	httpImage = newHTTPImageBackend(url, n, config.HTTPImageCache, config.HTTPImageCacheSizeMB)
+
 	currentVirtualMediaState = &VirtualMediaState{
 		Source: HTTP,
 		Mode:   mode,
@@ -313,7 +354,17 @@ func rpcMountWithHTTP(url string, mode VirtualMediaMode) error {
 	return nil
 }
 
-func rpcMountWithWebRTC(filename string, size int64, mode VirtualMediaMode) error {
+// virtualMediaSession is the session serving the image mounted over WebRTC.
+var virtualMediaSession *Session
+
+func getVirtualMediaSession() *Session {
+	virtualMediaStateMutex.Lock()
+	defer virtualMediaStateMutex.Unlock()
+
+	return virtualMediaSession
+}
+
+func rpcMountWithWebRTC(session *Session, filename string, size int64, mode VirtualMediaMode) error {
 	virtualMediaStateMutex.Lock()
 	if currentVirtualMediaState != nil {
 		virtualMediaStateMutex.Unlock()
@@ -325,6 +376,8 @@ func rpcMountWithWebRTC(filename string, size int64, mode VirtualMediaMode) erro
 		Filename: filename,
 		Size:     size,
 	}
+	virtualMediaSession = session
+	session.shouldUmountVirtualMedia = true
 	virtualMediaStateMutex.Unlock()
 
 	if err := setMassStorageMode(mode == CDROM); err != nil {
@@ -2,6 +2,7 @@ package kvm
 
 import (
 	"encoding/json"
+	"sync"
 )
 
 // max frame size for 1080p video, specified in mpp venc setting
@@ -27,11 +28,22 @@ type VideoInputState struct {
 	FramePerSecond float64 `json:"fps"`
 }
 
-var lastVideoState VideoInputState
+var (
+	lastVideoState     VideoInputState
+	lastVideoStateLock sync.Mutex
+)
+
+// getVideoState returns the last video state reported by the native process.
+func getVideoState() VideoInputState {
+	lastVideoStateLock.Lock()
+	defer lastVideoStateLock.Unlock()
+
+	return lastVideoState
+}
 
 func triggerVideoStateUpdate() {
 	go func() {
-		writeJSONRPCEvent("videoInputState", lastVideoState, currentSession)
+		broadcastJSONRPCEvent("videoInputState", getVideoState())
 	}()
 }
 func HandleVideoStateMessage(event CtrlResponse) {
@@ -41,11 +53,28 @@ func HandleVideoStateMessage(event CtrlResponse) {
 		logger.Warn().Err(err).Msg("Error parsing video state json")
 		return
 	}
+	lastVideoStateLock.Lock()
+	previous := lastVideoState
 	lastVideoState = videoState
+	lastVideoStateLock.Unlock()
+
+	if videoState.Ready != previous.Ready {
+		if videoState.Ready {
+			recordEvent(EventVideoSignalRestored, "", "HDMI signal restored", map[string]any{
+				"width":  videoState.Width,
+				"height": videoState.Height,
+				"fps":    videoState.FramePerSecond,
+			})
+		} else {
+			recordEvent(EventVideoSignalLost, "", "HDMI signal lost", map[string]any{"error": videoState.Error})
+		}
+	}
+	publishMQTTState("video", videoState)
+	notifyBootKeyAssistVideo(videoState)
 	triggerVideoStateUpdate()
 	requestDisplayUpdate(true)
 }
 
 func rpcGetVideoState() (VideoInputState, error) {
-	return lastVideoState, nil
+	return getVideoState(), nil
 }
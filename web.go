@@ -43,7 +43,11 @@ type SetPasswordRequest struct {
 }
 
 type LoginRequest struct {
+	// Username is empty for the admin password set during the setup
+	Username string `json:"username,omitempty"`
 	Password string `json:"password"`
+	// Code is the TOTP or recovery code, required when two-factor authentication is enabled
+	Code string `json:"code,omitempty"`
 }
 
 type ChangePasswordRequest struct {
@@ -55,10 +59,15 @@ type LocalDevice struct {
 	AuthMode     *string `json:"authMode"`
 	DeviceID     string  `json:"deviceId"`
 	LoopbackOnly bool    `json:"loopbackOnly"`
+	// Role is the role of the logged in user
+	Role Role `json:"role"`
 }
 
 type DeviceStatus struct {
 	IsSetup bool `json:"isSetup"`
+	// OIDCLogin and PasswordLogin tell the login page which methods to offer
+	OIDCLogin     bool `json:"oidcLogin"`
+	PasswordLogin bool `json:"passwordLogin"`
 }
 
 type SetupRequest struct {
@@ -70,6 +79,11 @@ func setupRouter() *gin.Engine {
 	gin.SetMode(gin.ReleaseMode)
 	gin.DisableConsoleColor()
 	r := gin.Default()
+	// the device is reached directly, X-Forwarded-For would let any client pick the IP
+	// the login limiter, the audit log and the sessions see
+	if err := r.SetTrustedProxies(nil); err != nil {
+		logger.Warn().Err(err).Msg("failed to disable the trusted proxies")
+	}
 	r.Use(gin_logger.SetLogger(
 		gin_logger.WithLogger(func(*gin.Context, zerolog.Logger) zerolog.Logger {
 			return *ginLogger
@@ -93,6 +107,8 @@ func setupRouter() *gin.Engine {
 
 	r.StaticFS("/static", http.FS(staticFS))
 	r.POST("/auth/login-local", handleLogin)
+	r.GET("/auth/oidc/login", handleOIDCLogin)
+	r.GET(oidcCallbackPath, handleOIDCCallback)
 
 	// We use this to determine if the device is setup
 	r.GET("/device/status", handleDeviceStatus)
@@ -148,15 +164,19 @@ func setupRouter() *gin.Engine {
 		 */
 		protected.POST("/webrtc/session", handleWebRTCSession)
 		protected.GET("/webrtc/signaling/client", handleLocalWebRTCSignal)
-		protected.POST("/cloud/register", handleCloudRegister)
+		protected.GET("/rpc/ws", handleRPCWebSocket)
+		protected.POST("/cloud/register", requirePermission(PermissionAdmin), handleCloudRegister)
 		protected.GET("/cloud/state", handleCloudState)
 		protected.GET("/device", handleDevice)
 		protected.POST("/auth/logout", handleLogout)
 
-		protected.POST("/auth/password-local", handleCreatePassword)
-		protected.PUT("/auth/password-local", handleUpdatePassword)
-		protected.DELETE("/auth/local-password", handleDeletePassword)
-		protected.POST("/storage/upload", handleUploadHttp)
+		protected.POST("/auth/password-local", requirePermission(PermissionAdmin), handleCreatePassword)
+		protected.PUT("/auth/password-local", requirePermission(PermissionAdmin), handleUpdatePassword)
+		protected.DELETE("/auth/local-password", requirePermission(PermissionAdmin), handleDeletePassword)
+		protected.POST("/storage/upload", requirePermission(PermissionControl), handleUploadHttp)
+		protected.GET("/audit/log.jsonl", requirePermission(PermissionAdmin), handleAuditLogDownload)
+
+		setupAPIV1Routes(protected)
 	}
 
 	// Catch-all route for SPA
@@ -171,9 +191,6 @@ func setupRouter() *gin.Engine {
 	return r
 }
 
-// TODO: support multiple sessions?
-var currentSession *Session
-
 func handleWebRTCSession(c *gin.Context) {
 	var req WebRTCSessionRequest
 
@@ -182,7 +199,15 @@ func handleWebRTCSession(c *gin.Context) {
 		return
 	}
 
-	session, err := newSession(SessionConfig{})
+	if err := canAddSession(); err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+		return
+	}
+
+	session, err := newSession(SessionConfig{
+		Source:         c.ClientIP(),
+		LoginSessionID: getRequestLoginSessionID(c),
+	})
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err})
 		return
@@ -193,15 +218,11 @@ func handleWebRTCSession(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err})
 		return
 	}
-	if currentSession != nil {
-		writeJSONRPCEvent("otherSessionConnected", nil, currentSession)
-		peerConn := currentSession.peerConnection
-		go func() {
-			time.Sleep(1 * time.Second)
-			_ = peerConn.Close()
-		}()
+	if err := addSession(session); err != nil {
+		_ = session.peerConnection.Close()
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+		return
 	}
-	currentSession = session
 	c.JSON(http.StatusOK, gin.H{"sd": sd})
 }
 
@@ -251,7 +272,7 @@ func handleLocalWebRTCSignal(c *gin.Context) {
 		return
 	}
 
-	err = handleWebRTCSignalWsMessages(wsCon, false, source, connectionID, &scopedLogger)
+	err = handleWebRTCSignalWsMessages(wsCon, false, source, connectionID, getRequestLoginSessionID(c), &scopedLogger)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -263,6 +284,7 @@ func handleWebRTCSignalWsMessages(
 	isCloudConnection bool,
 	source string,
 	connectionID string,
+	loginSessionID string,
 	scopedLogger *zerolog.Logger,
 ) error {
 	runCtx, cancelRun := context.WithCancel(context.Background())
@@ -345,6 +367,9 @@ func handleWebRTCSignalWsMessages(
 		}()
 	}
 
+	// the session negotiated over this websocket connection
+	var session *Session
+
 	for {
 		typ, msg, err := wsCon.Read(runCtx)
 		if err != nil {
@@ -396,7 +421,7 @@ func handleWebRTCSignalWsMessages(
 
 			metricConnectionSessionRequestCount.WithLabelValues(sourceType, source).Inc()
 			metricConnectionLastSessionRequestTimestamp.WithLabelValues(sourceType, source).SetToCurrentTime()
-			err = handleSessionRequest(runCtx, wsCon, req, isCloudConnection, source, &l)
+			session, err = handleSessionRequest(runCtx, wsCon, req, isCloudConnection, source, loginSessionID, &l)
 			if err != nil {
 				l.Warn().Str("error", err.Error()).Msg("error starting new session")
 				continue
@@ -418,13 +443,13 @@ func handleWebRTCSignalWsMessages(
 
 			l.Info().Str("data", fmt.Sprintf("%v", candidate)).Msg("unmarshalled incoming ICE candidate")
 
-			if currentSession == nil {
-				l.Warn().Msg("no current session, skipping incoming ICE candidate")
+			if session == nil {
+				l.Warn().Msg("no session for this connection, skipping incoming ICE candidate")
 				continue
 			}
 
-			l.Info().Str("data", fmt.Sprintf("%v", candidate)).Msg("adding incoming ICE candidate to current session")
-			if err = currentSession.peerConnection.AddICECandidate(candidate); err != nil {
+			l.Info().Str("data", fmt.Sprintf("%v", candidate)).Msg("adding incoming ICE candidate to the session")
+			if err = session.peerConnection.AddICECandidate(candidate); err != nil {
 				l.Warn().Str("error", err.Error()).Msg("failed to add incoming ICE candidate to our peer connection")
 			}
 		}
@@ -437,6 +462,11 @@ func handleLogin(c *gin.Context) {
 		return
 	}
 
+	if !isPasswordLoginEnabled() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Password login is disabled, use single sign-on instead"})
+		return
+	}
+
 	var req LoginRequest
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -444,29 +474,76 @@ func handleLogin(c *gin.Context) {
 		return
 	}
 
-	err := bcrypt.CompareHashAndPassword([]byte(config.HashedPassword), []byte(req.Password))
-	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid password"})
+	release, throttled := abortIfLoginThrottled(c)
+	if throttled {
 		return
 	}
+	defer release()
 
-	config.LocalAuthToken = uuid.New().String()
+	// the admin password set during the setup logs in as admin
+	identity := loginIdentity{Role: RoleAdmin}
+	if req.Username != "" {
+		user, ok := authenticateLocalUser(req.Username, req.Password)
+		if !ok {
+			loginRateLimiter.recordFailure(c.ClientIP(), "login-local")
+			auditHTTP(c, "login", req.Username, "denied", "invalid username or password")
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid username or password"})
+			return
+		}
+		identity = loginIdentity{UserID: user.ID, Username: user.Username, Role: user.Role}
+	} else {
+		err := bcrypt.CompareHashAndPassword([]byte(config.HashedPassword), []byte(req.Password))
+		if err != nil {
+			loginRateLimiter.recordFailure(c.ClientIP(), "login-local")
+			auditHTTP(c, "login", "", "denied", "invalid password")
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid password"})
+			return
+		}
+	}
+
+	// the device password and each named user have their own second factor, the
+	// users that didn't enroll log in with their password only
+	totpEnabled := isTOTPEnabled()
+	if identity.UserID != "" {
+		totpEnabled = isUserTOTPEnabled(identity.UserID)
+	}
+	if totpEnabled {
+		if req.Code == "" {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Two-factor code required", "totpRequired": true})
+			return
+		}
 
-	// Set the cookie
-	c.SetCookie("authToken", config.LocalAuthToken, 7*24*60*60, "/", "", false, true)
+		if err := verifyTOTP(identity.UserID, req.Code); err != nil {
+			loginRateLimiter.recordFailure(c.ClientIP(), "login-local-totp")
+			auditHTTP(c, "login", identity.Username, "denied", "invalid two-factor code")
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid two-factor code", "totpRequired": true})
+			return
+		}
+	}
+	loginRateLimiter.recordSuccess(c.ClientIP())
 
-	c.JSON(http.StatusOK, gin.H{"message": "Login successful"})
+	if err := startLoginSession(c, identity); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create login session"})
+		return
+	}
+	auditHTTP(c, "login", identity.Username, "ok", "")
+
+	c.JSON(http.StatusOK, gin.H{"message": "Login successful", "role": identity.Role})
 }
 
 func handleLogout(c *gin.Context) {
-	config.LocalAuthToken = ""
-	if err := SaveConfig(); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save configuration"})
-		return
+	// only this browser is logged out, the other login sessions stay valid
+	if loginSessionID := getRequestLoginSessionID(c); loginSessionID != "" {
+		if err := loginSessions.revoke(loginSessionID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke login session"})
+			return
+		}
 	}
 
+	auditHTTP(c, "logout", "", "ok", "")
+
 	// Clear the auth cookie
-	c.SetCookie("authToken", "", -1, "/", "", false, true)
+	c.SetCookie(authTokenCookieName, "", -1, "/", "", false, true)
 	c.JSON(http.StatusOK, gin.H{"message": "Logout successful"})
 }
 
@@ -477,13 +554,35 @@ func protectedMiddleware() gin.HandlerFunc {
 			return
 		}
 
-		authToken, err := c.Cookie("authToken")
-		if err != nil || authToken != config.LocalAuthToken || authToken == "" {
+		if bearerToken, ok := getBearerToken(c); ok {
+			if _, valid := validateAPIToken(bearerToken); !valid {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid API token"})
+				c.Abort()
+				return
+			}
+			// the API tokens are created by an admin and act as one
+			c.Set(roleContextKey, RoleAdmin)
+
+			c.Next()
+			return
+		}
+
+		authToken, err := c.Cookie(authTokenCookieName)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			c.Abort()
+			return
+		}
+
+		loginSession, ok := loginSessions.validate(authToken, c)
+		if !ok {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
 			c.Abort()
 			return
 		}
 
+		c.Set(loginSessionContextKey, loginSession)
+		c.Set(roleContextKey, loginSession.role())
 		c.Next()
 	}
 }
@@ -513,6 +612,11 @@ func basicAuthProtectedMiddleware(requireDeveloperMode bool) gin.HandlerFunc {
 			return
 		}
 
+		if !isPasswordLoginEnabled() {
+			sendErrorJsonThenAbort(c, http.StatusForbidden, "Password authentication is disabled")
+			return
+		}
+
 		// calculate basic auth credentials
 		_, password, ok := c.Request.BasicAuth()
 		if !ok {
@@ -521,11 +625,21 @@ func basicAuthProtectedMiddleware(requireDeveloperMode bool) gin.HandlerFunc {
 			return
 		}
 
+		release, throttled := abortIfLoginThrottled(c)
+		if throttled {
+			return
+		}
+
 		err := bcrypt.CompareHashAndPassword([]byte(config.HashedPassword), []byte(password))
 		if err != nil {
+			loginRateLimiter.recordFailure(c.ClientIP(), "basic-auth")
+			release()
 			sendErrorJsonThenAbort(c, http.StatusUnauthorized, "Invalid password")
 			return
 		}
+		loginRateLimiter.recordSuccess(c.ClientIP())
+		// released before the API call, that may take a while
+		release()
 
 		c.Next()
 	}
@@ -552,6 +666,7 @@ func handleDevice(c *gin.Context) {
 		AuthMode:     &config.LocalAuthMode,
 		DeviceID:     GetDeviceID(),
 		LoopbackOnly: config.LocalLoopbackOnly,
+		Role:         getRequestRole(c),
 	}
 
 	c.JSON(http.StatusOK, response)
@@ -584,15 +699,17 @@ func handleCreatePassword(c *gin.Context) {
 	}
 
 	config.HashedPassword = string(hashedPassword)
-	config.LocalAuthToken = uuid.New().String()
 	config.LocalAuthMode = "password"
 	if err := SaveConfig(); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save configuration"})
 		return
 	}
+	auditHTTP(c, "createPassword", "", "ok", "")
 
-	// Set the cookie
-	c.SetCookie("authToken", config.LocalAuthToken, 7*24*60*60, "/", "", false, true)
+	if err := startLoginSession(c, loginIdentity{Role: RoleAdmin}); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create login session"})
+		return
+	}
 
 	c.JSON(http.StatusCreated, gin.H{"message": "Password set successfully"})
 }
@@ -603,13 +720,6 @@ func handleUpdatePassword(c *gin.Context) {
 		return
 	}
 
-	// We only allow users with password mode to change their password
-	// Users with noPassword mode are not allowed to change their password
-	if config.LocalAuthMode != "password" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Password mode is not enabled"})
-		return
-	}
-
 	var req ChangePasswordRequest
 	if err := c.ShouldBindJSON(&req); err != nil || req.OldPassword == "" || req.NewPassword == "" {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
@@ -617,6 +727,7 @@ func handleUpdatePassword(c *gin.Context) {
 	}
 
 	if err := bcrypt.CompareHashAndPassword([]byte(config.HashedPassword), []byte(req.OldPassword)); err != nil {
+		auditHTTP(c, "updatePassword", "", "denied", "incorrect old password")
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Incorrect old password"})
 		return
 	}
@@ -628,14 +739,18 @@ func handleUpdatePassword(c *gin.Context) {
 	}
 
 	config.HashedPassword = string(hashedPassword)
-	config.LocalAuthToken = uuid.New().String()
 	if err := SaveConfig(); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save configuration"})
 		return
 	}
 
-	// Set the cookie
-	c.SetCookie("authToken", config.LocalAuthToken, 7*24*60*60, "/", "", false, true)
+	auditHTTP(c, "updatePassword", "", "ok", "")
+
+	// log out every other browser, the current one stays logged in
+	if err := loginSessions.revokeAll(getRequestLoginSessionID(c)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke login sessions"})
+		return
+	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "Password updated successfully"})
 }
@@ -646,8 +761,9 @@ func handleDeletePassword(c *gin.Context) {
 		return
 	}
 
-	if config.LocalAuthMode != "password" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Password mode is not enabled"})
+	// also the password fallback of the "oidc" mode
+	if !isPasswordLoginEnabled() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Password login is not enabled"})
 		return
 	}
 
@@ -658,27 +774,40 @@ func handleDeletePassword(c *gin.Context) {
 	}
 
 	if err := bcrypt.CompareHashAndPassword([]byte(config.HashedPassword), []byte(req.Password)); err != nil {
+		auditHTTP(c, "deletePassword", "", "denied", "incorrect password")
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Incorrect password"})
 		return
 	}
 
-	// Disable password
+	// Disable password, the second factor goes with it
 	config.HashedPassword = ""
-	config.LocalAuthToken = ""
-	config.LocalAuthMode = "noPassword"
+	// the "oidc" mode stays, only its password fallback goes away
+	if config.LocalAuthMode == "password" {
+		config.LocalAuthMode = "noPassword"
+	}
+	totpLock.Lock()
+	disableTOTPLocked()
+	totpLock.Unlock()
 	if err := SaveConfig(); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save configuration"})
 		return
 	}
 
-	c.SetCookie("authToken", "", -1, "/", "", false, true)
+	auditHTTP(c, "deletePassword", "", "ok", "")
+
+	// login sessions are meaningless without a password
+	loginSessions.clear()
+
+	c.SetCookie(authTokenCookieName, "", -1, "/", "", false, true)
 
 	c.JSON(http.StatusOK, gin.H{"message": "Password disabled successfully"})
 }
 
 func handleDeviceStatus(c *gin.Context) {
 	response := DeviceStatus{
-		IsSetup: config.LocalAuthMode != "",
+		IsSetup:       config.LocalAuthMode != "",
+		OIDCLogin:     isOIDCLoginEnabled(),
+		PasswordLogin: isPasswordLoginEnabled(),
 	}
 
 	c.JSON(http.StatusOK, response)
@@ -744,14 +873,9 @@ func handleSetup(c *gin.Context) {
 		}
 
 		config.HashedPassword = string(hashedPassword)
-		config.LocalAuthToken = uuid.New().String()
-
-		// Set the cookie
-		c.SetCookie("authToken", config.LocalAuthToken, 7*24*60*60, "/", "", false, true)
 	} else {
 		// For noPassword mode, ensure the password field is empty
 		config.HashedPassword = ""
-		config.LocalAuthToken = ""
 	}
 
 	err := SaveConfig()
@@ -759,6 +883,14 @@ func handleSetup(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save config"})
 		return
 	}
+	auditHTTP(c, "setup", "", "ok", "")
+
+	if req.LocalAuthMode == "password" {
+		if err := startLoginSession(c, loginIdentity{Role: RoleAdmin}); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create login session"})
+			return
+		}
+	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "Device setup completed successfully"})
 }
@@ -0,0 +1,346 @@
+package kvm
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	maxWebhooks              = 16
+	defaultWebhookMaxRetries = 3
+	maxWebhookRetries        = 10
+
+	webhookRequestTimeout = 10 * time.Second
+	webhookInitialBackoff = 2 * time.Second
+	webhookMaxBackoff     = time.Minute
+
+	// maxWebhookDeliveries bounds the deliveries waiting for a retry, the events
+	// beyond it are dropped rather than piling up behind an unreachable endpoint
+	maxWebhookDeliveries = 32
+
+	// EventWebhookTest is only sent by testWebhook, it isn't kept in the history
+	EventWebhookTest EventType = "webhook_test"
+)
+
+const (
+	WebhookFormatJSON  = "json"
+	WebhookFormatSlack = "slack"
+)
+
+// Webhook is an URL the device POSTs the events to.
+type Webhook struct {
+	ID      string `json:"id"`
+	Name    string `json:"name"`
+	Enabled bool   `json:"enabled"`
+	URL     string `json:"url"`
+	// Format is "json" for the event as is, or "slack" for a Slack compatible {"text": ...} message
+	Format string `json:"format"`
+	// Secret signs the requests with HMAC-SHA256 in the X-JetKVM-Signature header, if set
+	Secret string `json:"secret"`
+	// Events are the event types sent to the webhook, empty means every event
+	Events     []EventType `json:"events"`
+	MaxRetries int         `json:"max_retries"`
+}
+
+// WebhookPayload is the body sent in the "json" format.
+type WebhookPayload struct {
+	Event     EventType      `json:"event"`
+	Seq       int64          `json:"seq,omitempty"`
+	Time      time.Time      `json:"time"`
+	DeviceID  string         `json:"deviceId"`
+	SessionID string         `json:"sessionId,omitempty"`
+	Message   string         `json:"message"`
+	Data      map[string]any `json:"data,omitempty"`
+}
+
+// errWebhookPermanent is returned for the responses that won't change on a retry.
+var errWebhookPermanent = errors.New("webhook rejected the request")
+
+var (
+	webhooksLock      = &sync.Mutex{}
+	webhookClient     = &http.Client{Timeout: webhookRequestTimeout}
+	webhookDeliveries atomic.Int32
+)
+
+func (w *Webhook) wants(eventType EventType) bool {
+	return len(w.Events) == 0 || slices.Contains(w.Events, eventType)
+}
+
+func validateWebhook(webhook *Webhook) error {
+	webhook.Name = strings.TrimSpace(webhook.Name)
+	webhook.URL = strings.TrimSpace(webhook.URL)
+
+	parsed, err := url.Parse(webhook.URL)
+	if err != nil || parsed.Host == "" || (parsed.Scheme != "http" && parsed.Scheme != "https") {
+		return errors.New("webhook URL must be an absolute http or https URL")
+	}
+
+	switch webhook.Format {
+	case "":
+		webhook.Format = WebhookFormatJSON
+	case WebhookFormatJSON, WebhookFormatSlack:
+	default:
+		return fmt.Errorf("invalid webhook format %q", webhook.Format)
+	}
+
+	if webhook.MaxRetries < 0 || webhook.MaxRetries > maxWebhookRetries {
+		return fmt.Errorf("max retries must be between 0 and %d", maxWebhookRetries)
+	}
+	return nil
+}
+
+// webhookBody encodes the event in the format of the webhook.
+func webhookBody(webhook *Webhook, payload *WebhookPayload) ([]byte, error) {
+	if webhook.Format == WebhookFormatSlack {
+		return json.Marshal(map[string]string{
+			"text": fmt.Sprintf("JetKVM %s: %s", payload.DeviceID, payload.Message),
+		})
+	}
+	return json.Marshal(payload)
+}
+
+// signWebhook returns the hex HMAC-SHA256 of the timestamp and the body, joined by a dot.
+func signWebhook(secret string, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// sendWebhook makes a single delivery attempt.
+func sendWebhook(ctx context.Context, webhook *Webhook, payload *WebhookPayload, deliveryID string) error {
+	body, err := webhookBody(webhook, payload)
+	if err != nil {
+		return fmt.Errorf("%w: failed to encode the payload: %v", errWebhookPermanent, err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("%w: %v", errWebhookPermanent, err)
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "JetKVM/"+builtAppVersion)
+	req.Header.Set("X-JetKVM-Event", string(payload.Event))
+	req.Header.Set("X-JetKVM-Delivery", deliveryID)
+	req.Header.Set("X-JetKVM-Timestamp", timestamp)
+	if webhook.Secret != "" {
+		req.Header.Set("X-JetKVM-Signature", "sha256="+signWebhook(webhook.Secret, timestamp, body))
+	}
+
+	resp, err := webhookClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return nil
+	case resp.StatusCode == http.StatusRequestTimeout || resp.StatusCode == http.StatusTooManyRequests:
+		return fmt.Errorf("webhook responded with status %d", resp.StatusCode)
+	case resp.StatusCode >= 400 && resp.StatusCode < 500:
+		return fmt.Errorf("%w with status %d", errWebhookPermanent, resp.StatusCode)
+	}
+	return fmt.Errorf("webhook responded with status %d", resp.StatusCode)
+}
+
+// deliverWebhook sends the event, retrying with an exponential backoff.
+func deliverWebhook(webhook Webhook, payload WebhookPayload) {
+	defer webhookDeliveries.Add(-1)
+
+	scopedLogger := webhookLogger.With().
+		Str("webhookId", webhook.ID).
+		Str("event", string(payload.Event)).
+		Logger()
+
+	ctx := appCtx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	deliveryID := uuid.New().String()
+	backoff := webhookInitialBackoff
+	for attempt := 0; ; attempt++ {
+		err := sendWebhook(ctx, &webhook, &payload, deliveryID)
+		if err == nil {
+			scopedLogger.Debug().Int("attempt", attempt+1).Msg("webhook delivered")
+			return
+		}
+
+		if errors.Is(err, errWebhookPermanent) || attempt >= webhook.MaxRetries {
+			scopedLogger.Warn().Err(err).Int("attempts", attempt+1).Msg("webhook delivery failed")
+			return
+		}
+
+		scopedLogger.Debug().Err(err).Dur("backoff", backoff).Msg("webhook delivery failed, retrying")
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return
+		}
+		backoff = min(backoff*2, webhookMaxBackoff)
+	}
+}
+
+// notifyWebhooks sends the event to the enabled webhooks that want it.
+func notifyWebhooks(event HistoryEvent) {
+	if config == nil {
+		return
+	}
+
+	webhooksLock.Lock()
+	targets := make([]Webhook, 0)
+	for _, webhook := range config.Webhooks {
+		if webhook.Enabled && webhook.wants(event.Type) {
+			targets = append(targets, webhook)
+		}
+	}
+	webhooksLock.Unlock()
+
+	if len(targets) == 0 {
+		return
+	}
+
+	payload := WebhookPayload{
+		Event:     event.Type,
+		Seq:       event.Seq,
+		Time:      event.Time,
+		DeviceID:  GetDeviceID(),
+		SessionID: event.SessionID,
+		Message:   event.Message,
+		Data:      event.Data,
+	}
+	for _, webhook := range targets {
+		if webhookDeliveries.Add(1) > maxWebhookDeliveries {
+			webhookDeliveries.Add(-1)
+			webhookLogger.Warn().Str("webhookId", webhook.ID).Str("event", string(event.Type)).Msg("too many pending webhook deliveries, dropping event")
+			continue
+		}
+		go deliverWebhook(webhook, payload)
+	}
+}
+
+func rpcGetWebhooks() ([]Webhook, error) {
+	webhooksLock.Lock()
+	defer webhooksLock.Unlock()
+
+	webhooks := make([]Webhook, 0, len(config.Webhooks))
+	for _, webhook := range config.Webhooks {
+		webhook.Secret = ""
+		webhooks = append(webhooks, webhook)
+	}
+	return webhooks, nil
+}
+
+func rpcCreateWebhook(webhook Webhook) (*Webhook, error) {
+	if err := validateWebhook(&webhook); err != nil {
+		return nil, err
+	}
+	webhook.ID = uuid.New().String()
+	if webhook.MaxRetries == 0 {
+		webhook.MaxRetries = defaultWebhookMaxRetries
+	}
+
+	webhooksLock.Lock()
+	if len(config.Webhooks) >= maxWebhooks {
+		webhooksLock.Unlock()
+		return nil, fmt.Errorf("too many webhooks (max %d)", maxWebhooks)
+	}
+	config.Webhooks = append(config.Webhooks, webhook)
+	webhooksLock.Unlock()
+
+	if err := SaveConfig(); err != nil {
+		return nil, fmt.Errorf("failed to save config: %w", err)
+	}
+
+	webhookLogger.Info().Str("id", webhook.ID).Str("name", webhook.Name).Msg("webhook created")
+
+	webhook.Secret = ""
+	return &webhook, nil
+}
+
+// rpcUpdateWebhook replaces the webhook, an empty secret keeps the current one.
+func rpcUpdateWebhook(id string, webhook Webhook) error {
+	if err := validateWebhook(&webhook); err != nil {
+		return err
+	}
+
+	webhooksLock.Lock()
+	idx := slices.IndexFunc(config.Webhooks, func(w Webhook) bool { return w.ID == id })
+	if idx == -1 {
+		webhooksLock.Unlock()
+		return fmt.Errorf("webhook %s not found", id)
+	}
+	webhook.ID = id
+	if webhook.Secret == "" {
+		webhook.Secret = config.Webhooks[idx].Secret
+	}
+	config.Webhooks[idx] = webhook
+	webhooksLock.Unlock()
+
+	if err := SaveConfig(); err != nil {
+		return fmt.Errorf("failed to save config: %w", err)
+	}
+
+	webhookLogger.Info().Str("id", id).Str("name", webhook.Name).Msg("webhook updated")
+	return nil
+}
+
+func rpcDeleteWebhook(id string) error {
+	webhooksLock.Lock()
+	idx := slices.IndexFunc(config.Webhooks, func(w Webhook) bool { return w.ID == id })
+	if idx == -1 {
+		webhooksLock.Unlock()
+		return fmt.Errorf("webhook %s not found", id)
+	}
+	config.Webhooks = slices.Delete(config.Webhooks, idx, idx+1)
+	webhooksLock.Unlock()
+
+	if err := SaveConfig(); err != nil {
+		return fmt.Errorf("failed to save config: %w", err)
+	}
+
+	webhookLogger.Info().Str("id", id).Msg("webhook deleted")
+	return nil
+}
+
+// rpcTestWebhook sends a test event once, without retrying, and returns the error if any.
+func rpcTestWebhook(ctx context.Context, id string) error {
+	webhooksLock.Lock()
+	idx := slices.IndexFunc(config.Webhooks, func(w Webhook) bool { return w.ID == id })
+	if idx == -1 {
+		webhooksLock.Unlock()
+		return fmt.Errorf("webhook %s not found", id)
+	}
+	webhook := config.Webhooks[idx]
+	webhooksLock.Unlock()
+
+	payload := WebhookPayload{
+		Event:    EventWebhookTest,
+		Time:     time.Now().UTC(),
+		DeviceID: GetDeviceID(),
+		Message:  "test event",
+	}
+	return sendWebhook(ctx, &webhook, &payload, uuid.New().String())
+}
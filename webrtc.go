@@ -4,8 +4,10 @@ import (
 	"context"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"net"
 	"strings"
+	"time"
 
 	"github.com/coder/websocket"
 	"github.com/coder/websocket/wsjson"
@@ -16,21 +18,33 @@ import (
 )
 
 type Session struct {
+	ID                       string
+	Source                   string
+	IsCloud                  bool
+	ConnectedAt              time.Time
+	LoginSessionID           string
+	UserID                   string
+	Role                     Role
 	peerConnection           *webrtc.PeerConnection
 	VideoTrack               *webrtc.TrackLocalStaticSample
 	ControlChannel           *webrtc.DataChannel
-	RPCChannel               *webrtc.DataChannel
+	RPCChannel               RPCTransport
 	HidChannel               *webrtc.DataChannel
 	DiskChannel              *webrtc.DataChannel
 	shouldUmountVirtualMedia bool
+	// closeWebSocket ends a JSON-RPC websocket session, which has no peer connection
+	closeWebSocket context.CancelFunc
 }
 
 type SessionConfig struct {
 	ICEServers []string
 	LocalIP    string
 	IsCloud    bool
-	ws         *websocket.Conn
-	Logger     *zerolog.Logger
+	Source     string
+	// LoginSessionID is the local login the session was opened with, if any
+	LoginSessionID string
+	ws             *websocket.Conn
+	Logger         *zerolog.Logger
 }
 
 func (s *Session) ExchangeOffer(offerStr string) (string, error) {
@@ -67,6 +81,34 @@ func (s *Session) ExchangeOffer(offerStr string) (string, error) {
 	return base64.StdEncoding.EncodeToString(localDescription), nil
 }
 
+// dataChannelPermission is what the session role needs to open the data channel,
+// the terminal is a root shell so it's restricted to admins.
+func dataChannelPermission(label string) Permission {
+	switch {
+	case label == "rpc":
+		return PermissionView
+	case label == "terminal":
+		return PermissionAdmin
+	case label == "disk", label == "serial", strings.HasPrefix(label, uploadIdPrefix):
+		return PermissionControl
+	}
+	return PermissionAdmin
+}
+
+// resolveSessionIdentity returns the role and the user of a new session. Sessions without
+// a login come from the noPassword mode or the cloud, they're admins.
+func resolveSessionIdentity(loginSessionID string) (Role, string, error) {
+	if loginSessionID == "" {
+		return RoleAdmin, "", nil
+	}
+
+	loginSession, ok := loginSessions.get(loginSessionID)
+	if !ok {
+		return "", "", errors.New("login session not found")
+	}
+	return loginSession.role(), loginSession.UserID, nil
+}
+
 func newSession(config SessionConfig) (*Session, error) {
 	webrtcSettingEngine := webrtc.SettingEngine{
 		LoggerFactory: logging.GetPionDefaultLoggerFactory(),
@@ -97,6 +139,11 @@ func newSession(config SessionConfig) (*Session, error) {
 		}
 	}
 
+	role, userID, err := resolveSessionIdentity(config.LoginSessionID)
+	if err != nil {
+		return nil, err
+	}
+
 	api := webrtc.NewAPI(webrtc.WithSettingEngine(webrtcSettingEngine))
 	peerConnection, err := api.NewPeerConnection(webrtc.Configuration{
 		ICEServers: []webrtc.ICEServer{iceServer},
@@ -104,19 +151,33 @@ func newSession(config SessionConfig) (*Session, error) {
 	if err != nil {
 		return nil, err
 	}
-	session := &Session{peerConnection: peerConnection}
+	session := &Session{
+		peerConnection: peerConnection,
+		Source:         config.Source,
+		IsCloud:        config.IsCloud,
+		LoginSessionID: config.LoginSessionID,
+		UserID:         userID,
+		Role:           role,
+	}
 
 	peerConnection.OnDataChannel(func(d *webrtc.DataChannel) {
 		scopedLogger.Info().Str("label", d.Label()).Uint16("id", *d.ID()).Msg("New DataChannel")
+		if permission := dataChannelPermission(d.Label()); !session.Role.allows(permission) {
+			scopedLogger.Warn().Str("label", d.Label()).Str("role", string(session.Role)).Msg("data channel not allowed for the session role, closing it")
+			_ = d.Close()
+			return
+		}
+
 		switch d.Label() {
 		case "rpc":
 			session.RPCChannel = d
 			d.OnMessage(func(msg webrtc.DataChannelMessage) {
-				go onRPCMessage(msg, session)
+				go handleRPCRequest(msg.Data, session)
 			})
 			triggerOTAStateUpdate()
 			triggerVideoStateUpdate()
 			triggerUSBStateUpdate()
+			go writeJSONRPCEvent("sessionState", getSessionState(session), session)
 		case "disk":
 			session.DiskChannel = d
 			d.OnMessage(onDiskMessage)
@@ -169,9 +230,9 @@ func newSession(config SessionConfig) (*Session, error) {
 		if connectionState == webrtc.ICEConnectionStateConnected {
 			if !isConnected {
 				isConnected = true
-				actionSessions++
+				count := addActionSessions(1)
 				onActiveSessionsChanged()
-				if actionSessions == 1 {
+				if count == 1 {
 					onFirstSessionConnected()
 				}
 			}
@@ -179,22 +240,21 @@ func newSession(config SessionConfig) (*Session, error) {
 		//state changes on closing browser tab disconnected->failed, we need to manually close it
 		if connectionState == webrtc.ICEConnectionStateFailed {
 			scopedLogger.Debug().Msg("ICE Connection State is failed, closing peerConnection")
+			recordEvent(EventICEFailed, session.ID, "ICE connection failed", map[string]any{"source": session.Source})
 			_ = peerConnection.Close()
 		}
 		if connectionState == webrtc.ICEConnectionStateClosed {
 			scopedLogger.Debug().Msg("ICE Connection State is closed, unmounting virtual media")
-			if session == currentSession {
-				currentSession = nil
-			}
-			if session.shouldUmountVirtualMedia {
-				err := rpcUnmountImage()
+			removeSession(session)
+			// only the media served by this session goes away with it
+			if err := unmountImageOfSession(session); err != nil {
 				scopedLogger.Warn().Err(err).Msg("unmount image failed on connection close")
 			}
 			if isConnected {
 				isConnected = false
-				actionSessions--
+				count := addActionSessions(-1)
 				onActiveSessionsChanged()
-				if actionSessions == 0 {
+				if count == 0 {
 					onLastSessionDisconnected()
 				}
 			}
@@ -203,8 +263,25 @@ func newSession(config SessionConfig) (*Session, error) {
 	return session, nil
 }
 
+// actionSessions is the number of connected WebRTC sessions, guarded by sessionsLock
+// as the ICE callbacks of the sessions run concurrently.
 var actionSessions = 0
 
+func addActionSessions(delta int) int {
+	sessionsLock.Lock()
+	defer sessionsLock.Unlock()
+
+	actionSessions += delta
+	return actionSessions
+}
+
+func getActionSessions() int {
+	sessionsLock.RLock()
+	defer sessionsLock.RUnlock()
+
+	return actionSessions
+}
+
 func onActiveSessionsChanged() {
 	requestDisplayUpdate(true)
 }